@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"math/rand"
+	"math/rand/v2"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -15,12 +19,11 @@ import (
 	"hadith-bot/internal/config"
 	"hadith-bot/internal/image"
 	"hadith-bot/internal/logger"
+	"hadith-bot/internal/search"
 	"hadith-bot/internal/services"
 )
 
 func main() {
-	rand.Seed(time.Now().UnixNano())
-
 	// Load .env file if exists
 	godotenv.Load()
 
@@ -71,7 +74,35 @@ func main() {
 		dataDir = ""
 	}
 
-	hadithService := services.NewHadithService(dataDir, cfg.APIURL, cfg.APIKey, cfg.APITimeout, log)
+	hadithService, err := services.NewHadithService(dataDir, cfg.StrictDataLoad, cfg.APIURL, cfg.APIKey, cfg.APITimeout, log)
+	if err != nil {
+		log.Fatal("Failed to load hadith data: %v", err)
+	}
+	if cfg.SynonymsFile != "" {
+		entries, err := search.LoadSynonymsFile(cfg.SynonymsFile)
+		if err != nil {
+			log.Fatal("Failed to load synonyms: %v", err)
+		}
+		synonyms := search.DefaultSynonyms()
+		for _, e := range entries {
+			synonyms.Add(e)
+		}
+		hadithService.SetSynonyms(synonyms)
+		log.Info("Loaded %d search synonym entries from %s", len(entries), cfg.SynonymsFile)
+	}
+	if cfg.RandomSeed != 0 {
+		hadithService.SetRandom(rand.New(rand.NewPCG(cfg.RandomSeed, 0)))
+		log.Info("Random hadiths use seed %d", cfg.RandomSeed)
+	}
+	if len(cfg.CollectionWeights) > 0 {
+		hadithService.SetCollectionWeights(cfg.CollectionWeights)
+		log.Info("Random hadith collection weights: %v", cfg.CollectionWeights)
+	}
+	todayLoc, err := time.LoadLocation(cfg.TodayTimezone)
+	if err != nil {
+		log.Fatal("Invalid TODAY_TIMEZONE %q: %v", cfg.TodayTimezone, err)
+	}
+	hadithService.SetTodayLocation(todayLoc)
 	log.Info("Hadith service initialized")
 
 	// Create image generator
@@ -93,23 +124,49 @@ func main() {
 		stateManager,
 		cfg.ImageCacheChannelID,
 		cfg.AdminUserID,
+		cfg.RandomHistorySize,
+		cfg.UpdateWorkers,
 	)
 
 	log.Info("Bot is ready to handle commands")
 
+	// Stop on SIGINT or SIGTERM, letting the work in flight finish
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	// Start scheduler for random hadiths
-	handler.StartScheduler()
+	handler.StartScheduler(ctx)
 	log.Info("Scheduler started")
 
-	// Handle graceful shutdown
-	go func() {
-		stop := make(chan os.Signal, 1)
-		signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
-		<-stop
-		log.Info("Shutting down bot...")
-		os.Exit(0)
-	}()
-
-	// Start bot update loop (this replaces bot.Start() and handler.HandleCommands())
-	handler.StartListening()
+	var webhook *botpkg.Webhook
+	if cfg.BotMode == config.ModeWebhook {
+		webhook, err = handler.NewWebhook(net.JoinHostPort(cfg.ServerHost, cfg.ServerPort), cfg.WebhookURL, cfg.WebhookSecret)
+		if err != nil {
+			log.Fatal("Failed to set up webhook: %v", err)
+		}
+		go func() {
+			if err := webhook.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Error("Webhook server failed: %v", err)
+				stop()
+			}
+		}()
+		<-ctx.Done()
+	} else {
+		// Start bot update loop (this replaces bot.Start() and handler.HandleCommands())
+		handler.StartListening(ctx)
+	}
+	stop() // a second signal kills the bot at once
+
+	log.Info("Shutting down bot...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if webhook != nil {
+		if err := webhook.Close(shutdownCtx); err != nil {
+			log.Error("Failed to stop webhook server: %v", err)
+		}
+	}
+	if err := handler.Shutdown(shutdownCtx); err != nil {
+		log.Error("Shutdown incomplete: %v", err)
+	}
+	log.Info("Bot stopped")
 }
@@ -0,0 +1,140 @@
+// Command hadithctl checks hadith data files offline, using the same loader as
+// the bot but without needing a Telegram token.
+//
+// Usage:
+//
+//	hadithctl validate [-data dir] [-strict] [-examples n]
+//	hadithctl stats [-data dir]
+//
+// validate exits with status 1 when any file fails to load (or, with -strict,
+// when any issue is found) so it can gate data updates.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"hadith-bot/internal/data"
+	"hadith-bot/internal/models"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+
+	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dataDir := fs.String("data", defaultDataDir(), "directory holding the collection JSON files")
+	strict := fs.Bool("strict", false, "validate: fail on any issue, not only on file errors")
+	examples := fs.Int("examples", 5, "validate: issues to list per file")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	d, report, err := data.LoadHadithData(*dataDir)
+
+	switch args[0] {
+	case "validate":
+		fmt.Fprint(stdout, report.Summary(*examples))
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
+		}
+		if report.HasErrors() {
+			fmt.Fprintf(stderr, "FAIL: %d file(s) failed to load\n", report.ErrorCount())
+			return 1
+		}
+		if *strict && report.IssueCount() > 0 {
+			fmt.Fprintf(stderr, "FAIL: %d issue(s) found\n", report.IssueCount())
+			return 1
+		}
+		fmt.Fprintln(stdout, "OK")
+		return 0
+	case "stats":
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
+		}
+		printStats(stdout, d, report)
+		return 0
+	default:
+		usage(stderr)
+		return 2
+	}
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: hadithctl <validate|stats> [-data dir] [-strict] [-examples n]")
+}
+
+// defaultDataDir mirrors the lookup the bot does when started from the
+// telegram-bot directory or from cmd/bot
+func defaultDataDir() string {
+	for _, dir := range []string{"../src/data", "../../src/data", "./data"} {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+	}
+	return "./data"
+}
+
+func printStats(w io.Writer, d *models.CollectionData, report *data.LoadReport) {
+	issues := make(map[string]*data.FileReport)
+	for i := range report.Files {
+		issues[report.Files[i].Collection] = &report.Files[i]
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "COLLECTION\tBOOKS\tHADITHS\tEMPTY CHAPTERS\tUNKNOWN CHAPTER\tNO ENGLISH\tNO ARABIC\tENCODING")
+	for _, c := range d.Collections {
+		f := issues[c.Name]
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\n", c.Name, c.Books, c.Hadiths,
+			f.Count(data.IssueEmptyChapter), f.Count(data.IssueOrphanChapter),
+			f.Count(data.IssueEmptyEnglish), f.Count(data.IssueEmptyArabic), f.Count(data.IssueEncoding))
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Grades:")
+	for _, c := range d.Collections {
+		grades := make(map[string]int)
+		for _, h := range d.Hadiths[c.Name] {
+			grade := h.Grade
+			if grade == "" {
+				grade = "(ungraded)"
+			}
+			grades[grade]++
+		}
+
+		names := make([]string, 0, len(grades))
+		for g := range grades {
+			names = append(names, g)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			if grades[names[i]] != grades[names[j]] {
+				return grades[names[i]] > grades[names[j]]
+			}
+			return names[i] < names[j]
+		})
+
+		fmt.Fprintf(w, "  %s:", c.Name)
+		for _, g := range names {
+			fmt.Fprintf(w, " %s=%d", g, grades[g])
+		}
+		fmt.Fprintln(w)
+	}
+
+	if n := report.ErrorCount(); n > 0 {
+		fmt.Fprintf(w, "\n%d file(s) failed to load; run `hadithctl validate` for details\n", n)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testCollectionJSON = `{
+	"metadata": {"id": 1, "english": {"title": "Alpha Collection", "author": "Some Imam"}},
+	"chapters": [{"id": 1, "arabic": "كتاب", "english": "Book One"}],
+	"hadiths": [
+		{"idInBook": 1, "chapterId": 1, "arabic": "نص", "english": {"narrator": "Narrated A:", "text": "Text one"}, "grade": "Sahih"},
+		{"idInBook": 2, "chapterId": 1, "arabic": "نص", "english": {"narrator": "Narrated B:", "text": "Text two"}}
+	]
+}`
+
+// Loads, but with a hadith in a chapter the file does not list
+const testIssueJSON = `{
+	"chapters": [{"id": 1, "arabic": "كتاب", "english": "Book One"}],
+	"hadiths": [
+		{"idInBook": 1, "chapterId": 9, "arabic": "نص", "english": {"narrator": "", "text": "Text"}}
+	]
+}`
+
+func writeDataDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRun(t *testing.T) {
+	good := writeDataDir(t, map[string]string{"alpha.json": testCollectionJSON})
+	broken := writeDataDir(t, map[string]string{"alpha.json": testCollectionJSON, "broken.json": "404: Not Found"})
+	issues := writeDataDir(t, map[string]string{"alpha.json": testCollectionJSON, "beta.json": testIssueJSON})
+	empty := writeDataDir(t, map[string]string{"broken.json": "404: Not Found"})
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout []string
+		wantStderr []string
+	}{
+		{"no command", nil, 2, nil, []string{"usage:"}},
+		{"unknown command", []string{"load", "-data", good}, 2, nil, []string{"usage:"}},
+		{"bad flag", []string{"validate", "-verbose"}, 2, nil, []string{"-verbose"}},
+		{"validate ok", []string{"validate", "-data", good}, 0, []string{"alpha", "OK"}, nil},
+		{"validate broken file", []string{"validate", "-data", broken}, 1, []string{"broken.json"}, []string{"FAIL: 1 file(s) failed to load"}},
+		{"validate issues", []string{"validate", "-data", issues}, 0, []string{"OK"}, nil},
+		{"validate issues strict", []string{"validate", "-data", issues, "-strict"}, 1, nil, []string{"issue(s) found"}},
+		{"validate nothing loads", []string{"validate", "-data", empty}, 1, nil, []string{"error:"}},
+		{"validate missing dir", []string{"validate", "-data", missing}, 1, nil, []string{"error:"}},
+		{"stats", []string{"stats", "-data", good}, 0, []string{"COLLECTION", "alpha", "Sahih=1", "(ungraded)=1"}, nil},
+		{"stats broken file", []string{"stats", "-data", broken}, 0, []string{"alpha", "1 file(s) failed to load"}, nil},
+		{"stats missing dir", []string{"stats", "-data", missing}, 1, nil, []string{"error:"}},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(tt.args, &stdout, &stderr)
+		if code != tt.wantCode {
+			t.Errorf("%s: exit code %d; want %d\nstdout:\n%s\nstderr:\n%s", tt.name, code, tt.wantCode, stdout.String(), stderr.String())
+		}
+		for _, want := range tt.wantStdout {
+			if !strings.Contains(stdout.String(), want) {
+				t.Errorf("%s: stdout lacks %q:\n%s", tt.name, want, stdout.String())
+			}
+		}
+		for _, want := range tt.wantStderr {
+			if !strings.Contains(stderr.String(), want) {
+				t.Errorf("%s: stderr lacks %q:\n%s", tt.name, want, stderr.String())
+			}
+		}
+	}
+}
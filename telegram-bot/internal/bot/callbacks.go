@@ -0,0 +1,252 @@
+package bot
+
+import (
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// callbackParam is the type of one argument in a button's callback data
+type callbackParam int
+
+const (
+	paramInt callbackParam = iota
+	paramString
+)
+
+// callbackRoute handles one kind of button. Its callback data is the route's
+// key and then its params, joined with colons, such as "books:bukhari:2" for
+// the key "books" and the params string and int.
+type callbackRoute struct {
+	params   []callbackParam
+	optional int // how many of the last params may be left out
+	handle   func(cb *callback)
+
+	run HandlerFunc // handle within the route's middleware, set by Router.Callback
+}
+
+// callback is a button press matched to its route, with its arguments
+// checked against the route's params
+type callback struct {
+	query       *tgbotapi.CallbackQuery
+	chatID      int64 // 0 for a button on an inline message
+	msgID       int
+	inlineMsgID string
+	args        []string
+	answered    bool
+	client      TelegramClient
+}
+
+// Int returns the i-th argument, which the route declares an int, or 0 when
+// it was optional and left out
+func (cb *callback) Int(i int) int {
+	if i >= len(cb.args) {
+		return 0
+	}
+	n, _ := strconv.Atoi(cb.args[i])
+	return n
+}
+
+// String returns the i-th argument, or "" when it was optional and left out
+func (cb *callback) String(i int) string {
+	if i >= len(cb.args) {
+		return ""
+	}
+	return cb.args[i]
+}
+
+// callbackRouter finds the route for a button's callback data. A key may be
+// one part, such as "books", or two, such as "sched:menu"; the longer one
+// wins.
+type callbackRouter map[string]callbackRoute
+
+// match returns the key and route for a callback query and the callback to
+// pass to it, or false when no route takes its data
+func (r callbackRouter) match(c *tgbotapi.CallbackQuery) (string, callbackRoute, *callback, bool) {
+	parts := strings.Split(c.Data, ":")
+
+	key, args := parts[0], parts[1:]
+	route, ok := callbackRoute{}, false
+	if len(parts) > 1 {
+		if route, ok = r[parts[0]+":"+parts[1]]; ok {
+			key, args = parts[0]+":"+parts[1], parts[2:]
+		}
+	}
+	if !ok {
+		if route, ok = r[key]; !ok {
+			return "", callbackRoute{}, nil, false
+		}
+	}
+
+	if len(args) > len(route.params) || len(args) < len(route.params)-route.optional {
+		return "", callbackRoute{}, nil, false
+	}
+	for i, arg := range args {
+		if route.params[i] == paramInt {
+			if _, err := strconv.Atoi(arg); err != nil {
+				return "", callbackRoute{}, nil, false
+			}
+		}
+	}
+
+	cb := &callback{query: c, inlineMsgID: c.InlineMessageID, args: args}
+	if c.Message != nil {
+		cb.chatID = c.Message.Chat.ID
+		cb.msgID = c.Message.MessageID
+	}
+	return key, route, cb, true
+}
+
+// answer answers the button press with a toast, or nothing when text is
+// empty. Only the first answer reaches Telegram.
+func (cb *callback) answer(text string) {
+	if cb.answered {
+		return
+	}
+	cb.answered = true
+	cb.client.Request(tgbotapi.NewCallback(cb.query.ID, text))
+}
+
+// alert answers the button press with an alert the user must dismiss
+func (cb *callback) alert(text string) {
+	if cb.answered {
+		return
+	}
+	cb.answered = true
+	cb.client.Request(tgbotapi.NewCallbackWithAlert(cb.query.ID, text))
+}
+
+// registerCallbacks routes every button the bot sends, except those of the
+// schedule menu
+func (h *Handler) registerCallbacks(r *Router) {
+	for key, route := range map[string]callbackRoute{
+		"collections": {
+			params: []callbackParam{paramInt},
+			handle: func(cb *callback) {
+				h.sendCollectionsMenu(cb.chatID, cb.msgID, cb.inlineMsgID, h.hadithService.GetCollections(), cb.Int(0))
+			},
+		},
+		"books": {
+			params: []callbackParam{paramString, paramInt},
+			handle: func(cb *callback) {
+				col := cb.String(0)
+				h.sendBooksMenu(cb.chatID, cb.msgID, cb.inlineMsgID, col, h.hadithService.GetBooks(col), cb.Int(1))
+			},
+		},
+		"hadiths": {
+			params: []callbackParam{paramString, paramInt, paramInt},
+			handle: func(cb *callback) {
+				col, bookNum := cb.String(0), cb.Int(1)
+				res := h.hadithService.GetHadiths(col, bookNum, cb.Int(2), 10)
+				h.sendHadithsMenu(cb.chatID, cb.msgID, cb.inlineMsgID, col, bookNum, res)
+			},
+		},
+		"narrators": {
+			params: []callbackParam{paramInt},
+			handle: func(cb *callback) {
+				h.sendNarratorsMenu(cb.chatID, cb.msgID, cb.inlineMsgID, cb.Int(0))
+			},
+		},
+		"narrator": {
+			params: []callbackParam{paramInt, paramInt},
+			handle: func(cb *callback) {
+				h.sendNarratorHadiths(cb.chatID, cb.msgID, cb.inlineMsgID, cb.Int(0), cb.Int(1))
+			},
+		},
+		"topics": {
+			params: []callbackParam{paramInt},
+			handle: func(cb *callback) {
+				h.sendTopicsMenu(cb.chatID, cb.msgID, cb.inlineMsgID, cb.Int(0))
+			},
+		},
+		"topic": {
+			params: []callbackParam{paramString, paramInt},
+			handle: func(cb *callback) {
+				h.sendTopicHadiths(cb.chatID, cb.msgID, cb.inlineMsgID, cb.String(0), cb.Int(1))
+			},
+		},
+		"topic_random": {
+			params: []callbackParam{paramString},
+			handle: func(cb *callback) {
+				h.sendRandomInTopic(cb.chatID, cb.msgID, cb.inlineMsgID, cb.String(0))
+			},
+		},
+		// hadith_detail:<collection>:<book>:<list page>:<index on the page>
+		"hadith_detail": {
+			params: []callbackParam{paramString, paramInt, paramInt, paramInt},
+			handle: func(cb *callback) {
+				h.sendHadithDetailPaged(cb.chatID, cb.msgID, cb.inlineMsgID, cb.String(0), cb.Int(1), cb.Int(2), cb.Int(3), 0)
+			},
+		},
+		"hadith_search": {
+			params: []callbackParam{paramString, paramInt},
+			handle: func(cb *callback) {
+				h.sendSearchHadithPaged(cb.chatID, cb.msgID, cb.inlineMsgID, cb.String(0), cb.Int(1), 0)
+			},
+		},
+		// The parts of a long hadith: hadith_page:d is hadith_detail's
+		// arguments and then the part; hadith_page:s and hadith_page:r are
+		// the collection, hadith number and part
+		"hadith_page:d": {
+			params: []callbackParam{paramString, paramInt, paramInt, paramInt, paramInt},
+			handle: func(cb *callback) {
+				h.sendHadithDetailPaged(cb.chatID, cb.msgID, cb.inlineMsgID, cb.String(0), cb.Int(1), cb.Int(2), cb.Int(3), cb.Int(4))
+			},
+		},
+		"hadith_page:s": {
+			params: []callbackParam{paramString, paramInt, paramInt},
+			handle: func(cb *callback) {
+				h.sendSearchHadithPaged(cb.chatID, cb.msgID, cb.inlineMsgID, cb.String(0), cb.Int(1), cb.Int(2))
+			},
+		},
+		"hadith_page:r": {
+			params: []callbackParam{paramString, paramInt, paramInt},
+			handle: func(cb *callback) {
+				h.sendRandomHadithPaged(cb.chatID, cb.msgID, cb.inlineMsgID, cb.String(0), cb.Int(1), cb.Int(2))
+			},
+		},
+		"hadith_image": {
+			params: []callbackParam{paramString, paramInt},
+			handle: h.handleHadithImageCallback,
+		},
+		"similar": {
+			params: []callbackParam{paramString, paramInt},
+			handle: func(cb *callback) {
+				h.sendSimilarHadiths(cb.chatID, cb.msgID, cb.inlineMsgID, cb.String(0), cb.Int(1))
+			},
+		},
+		"random": {
+			handle: h.handleRandomCallback,
+		},
+		// s:<session>[:<page>] shows a page of a search session's results,
+		// the page last shown when none is given
+		"s": {
+			params:   []callbackParam{paramString, paramInt},
+			optional: 1,
+			handle: func(cb *callback) {
+				h.showSearchSession(cb, cb.String(0), cb.Int(1))
+			},
+		},
+		// sf:<session>:<choice>:<index> narrows a search session to one of
+		// the choices its buttons offer; see choiceCollection
+		"sf": {
+			params: []callbackParam{paramString, paramString, paramInt},
+			handle: func(cb *callback) {
+				h.chooseSearchSession(cb, cb.String(0), cb.String(1), cb.Int(2))
+			},
+		},
+		"search": {
+			handle: func(cb *callback) {
+				h.sendMessage(cb.chatID, "🔎 Send <b>/search</b> followed by a keyword. Example: <b>/search prayer</b>")
+			},
+		},
+		"help": {
+			handle: func(cb *callback) {
+				h.sendMessage(cb.chatID, "Use <b>/help</b> to view all commands and examples.")
+			},
+		},
+	} {
+		r.Callback(key, route)
+	}
+}
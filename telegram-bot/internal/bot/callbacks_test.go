@@ -0,0 +1,98 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// testCallbackRouter returns the bot's callback routes
+func testCallbackRouter() callbackRouter {
+	h := &Handler{}
+	r := NewRouter(nil, nil)
+	h.registerCallbacks(r)
+	h.registerScheduleCallbacks(r)
+	return r.callbacks
+}
+
+func TestCallbackRouterMatch(t *testing.T) {
+	routes := testCallbackRouter()
+	tests := []struct {
+		data string
+		ok   bool
+		args []string
+	}{
+		{"collections:2", true, []string{"2"}},
+		{"random", true, []string{}},
+		{"hadiths:bukhari:3:1", true, []string{"bukhari", "3", "1"}},
+		{"hadith_page:d:bukhari:3:1:4:2", true, []string{"bukhari", "3", "1", "4", "2"}},
+		{"hadith_page:r:muslim:8:1", true, []string{"muslim", "8", "1"}},
+		{"sched:menu:3", true, []string{"3"}},
+		{"sched:topic:3:", true, []string{"3", ""}},
+		{"s:AbC-d_9x", true, []string{"AbC-d_9x"}},
+		{"s:AbC-d_9x:2", true, []string{"AbC-d_9x", "2"}},
+		{"sf:AbC-d_9x:c:2", true, []string{"AbC-d_9x", "c", "2"}},
+
+		{"collections", false, nil},           // missing page
+		{"collections:x", false, nil},         // page is not a number
+		{"hadiths:bukhari:3", false, nil},     // missing page
+		{"books:bukhari:1:extra", false, nil}, // too many parts
+		{"hadith_page:q:bukhari:3:1", false, nil},
+		{"search_next:prayer:2", false, nil}, // the old search buttons
+		{"sched:menu", false, nil},
+		{"sf:AbC-d_9x:Sahih al-Bukhari", false, nil}, // facets go by index
+		{"", false, nil},
+	}
+
+	for _, tt := range tests {
+		_, _, cb, ok := routes.match(&tgbotapi.CallbackQuery{ID: "1", Data: tt.data})
+		if ok != tt.ok {
+			t.Errorf("match(%q) ok = %v; want %v", tt.data, ok, tt.ok)
+			continue
+		}
+		if ok && strings.Join(cb.args, "|") != strings.Join(tt.args, "|") {
+			t.Errorf("match(%q) args = %q; want %q", tt.data, cb.args, tt.args)
+		}
+	}
+}
+
+func TestCallbackArgs(t *testing.T) {
+	key, _, cb, ok := testCallbackRouter().match(&tgbotapi.CallbackQuery{
+		ID:      "1",
+		Data:    "s:AbC-d_9x",
+		Message: &tgbotapi.Message{MessageID: 7, Chat: &tgbotapi.Chat{ID: 42}},
+	})
+	if !ok || key != "s" {
+		t.Fatalf("search session button routed to %q, %v", key, ok)
+	}
+	if cb.String(0) != "AbC-d_9x" || cb.Int(1) != 0 || cb.String(5) != "" {
+		t.Errorf("args = %q, %d, %q", cb.String(0), cb.Int(1), cb.String(5))
+	}
+	if cb.chatID != 42 || cb.msgID != 7 {
+		t.Errorf("callback on chat %d message %d; want 42 and 7", cb.chatID, cb.msgID)
+	}
+}
+
+// TestCallbackDataFits checks that every button's data fits Telegram's limit
+// with the longest names the data loader accepts and four-digit numbers.
+// Collection names are at most 24 bytes; topic IDs, at most 32, only go in
+// sched:topic beside one number.
+func TestCallbackDataFits(t *testing.T) {
+	for key, route := range testCallbackRouter() {
+		parts := []string{key}
+		for _, p := range route.params {
+			if p == paramString {
+				parts = append(parts, strings.Repeat("x", 24))
+			} else {
+				parts = append(parts, "9999")
+			}
+		}
+		if data := strings.Join(parts, ":"); len(data) > callbackDataMaxBytes {
+			t.Errorf("%s buttons carry up to %d bytes: %q", key, len(data), data)
+		}
+	}
+	if data := "sched:topic:9999:" + strings.Repeat("x", 32); len(data) > callbackDataMaxBytes {
+		t.Errorf("sched:topic buttons carry up to %d bytes", len(data))
+	}
+}
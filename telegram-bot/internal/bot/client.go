@@ -0,0 +1,31 @@
+package bot
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// TelegramClient is the part of the Bot API the handlers and polling call.
+// A *tgbotapi.BotAPI is one; tests pass a fake, so handlers run without a
+// live bot. The webhook server still needs the real BotAPI.
+type TelegramClient interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+	GetChatMember(config tgbotapi.GetChatMemberConfig) (tgbotapi.ChatMember, error)
+	GetFileDirectURL(fileID string) (string, error)
+	GetUpdates(config tgbotapi.UpdateConfig) ([]tgbotapi.Update, error)
+}
+
+// isGroupAdmin reports whether a user may manage a chat: anyone in a private
+// chat, only administrators in a group
+func isGroupAdmin(client TelegramClient, chat *tgbotapi.Chat, userID int64) bool {
+	if !chat.IsGroup() && !chat.IsSuperGroup() {
+		return true
+	}
+	member, err := client.GetChatMember(tgbotapi.GetChatMemberConfig{
+		ChatConfigWithUser: tgbotapi.ChatConfigWithUser{
+			ChatID: chat.ID,
+			UserID: userID,
+		},
+	})
+	return err == nil && (member.IsCreator() || member.IsAdministrator())
+}
@@ -0,0 +1,135 @@
+package bot
+
+import (
+	"runtime/debug"
+	"sync"
+
+	"hadith-bot/internal/logger"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// updatesQueuedPerWorker bounds the updates waiting for a worker; past it
+// Dispatch blocks, which slows polling or the webhook down instead of
+// queueing without limit
+const updatesQueuedPerWorker = 32
+
+// Dispatcher handles updates on a bounded pool of workers. Updates of the
+// same chat run one at a time, in the order they arrived, while different
+// chats run in parallel, so a slow image render holds up only its own chat.
+type Dispatcher struct {
+	handle func(tgbotapi.Update)
+	log    *logger.Logger
+
+	slots chan struct{} // one per update queued or running
+	ready chan int64    // chats with updates queued and no worker on them
+
+	mu      sync.Mutex
+	pending map[int64][]tgbotapi.Update // a chat is here while it is ready or on a worker
+	closed  bool
+
+	wg sync.WaitGroup
+}
+
+// NewDispatcher starts workers goroutines that pass updates to handle
+func NewDispatcher(workers int, handle func(tgbotapi.Update), log *logger.Logger) *Dispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+	d := &Dispatcher{
+		handle:  handle,
+		log:     log,
+		slots:   make(chan struct{}, workers*updatesQueuedPerWorker),
+		ready:   make(chan int64, workers*updatesQueuedPerWorker),
+		pending: make(map[int64][]tgbotapi.Update),
+	}
+	d.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go d.work()
+	}
+	return d
+}
+
+// Dispatch queues an update behind the earlier ones of its chat. It blocks
+// while the queue is full, and returns false once the dispatcher is closed.
+func (d *Dispatcher) Dispatch(update tgbotapi.Update) bool {
+	d.slots <- struct{}{}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		<-d.slots
+		return false
+	}
+
+	key := updateChatKey(update)
+	queued, scheduled := d.pending[key]
+	d.pending[key] = append(queued, update)
+	if !scheduled {
+		// Every chat in ready holds a slot, so this never blocks
+		d.ready <- key
+	}
+	return true
+}
+
+// Close stops taking updates and waits for the queued ones to be handled
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.ready)
+	}
+	d.mu.Unlock()
+	d.wg.Wait()
+}
+
+// work handles the updates of one ready chat at a time, until the chat has
+// none left
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+	for key := range d.ready {
+		for {
+			d.mu.Lock()
+			queued := d.pending[key]
+			if len(queued) == 0 {
+				delete(d.pending, key)
+				d.mu.Unlock()
+				break
+			}
+			update := queued[0]
+			d.pending[key] = queued[1:]
+			d.mu.Unlock()
+
+			d.run(update)
+			<-d.slots
+		}
+	}
+}
+
+// run handles one update, recovering from a panic so that one bad update
+// does not take the bot down
+func (d *Dispatcher) run(update tgbotapi.Update) {
+	defer func() {
+		if r := recover(); r != nil {
+			d.log.Error("Panic handling update %d: %v\n%s", update.UpdateID, r, debug.Stack())
+		}
+	}()
+	d.handle(update)
+}
+
+// updateChatKey returns the chat whose updates must stay in order with an
+// update's: its message's chat, or the user for inline queries and
+// callbacks from inline messages
+func updateChatKey(update tgbotapi.Update) int64 {
+	switch {
+	case update.Message != nil && update.Message.Chat != nil:
+		return update.Message.Chat.ID
+	case update.CallbackQuery != nil && update.CallbackQuery.Message != nil && update.CallbackQuery.Message.Chat != nil:
+		return update.CallbackQuery.Message.Chat.ID
+	case update.CallbackQuery != nil && update.CallbackQuery.From != nil:
+		return update.CallbackQuery.From.ID
+	case update.InlineQuery != nil && update.InlineQuery.From != nil:
+		return update.InlineQuery.From.ID
+	}
+	return 0
+}
@@ -0,0 +1,114 @@
+package bot
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"hadith-bot/internal/logger"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func chatUpdate(updateID int, chatID int64) tgbotapi.Update {
+	return tgbotapi.Update{UpdateID: updateID, Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: chatID}}}
+}
+
+func TestDispatcherKeepsChatOrder(t *testing.T) {
+	var mu sync.Mutex
+	handled := make(map[int64][]int)
+	d := NewDispatcher(4, func(u tgbotapi.Update) {
+		// Early updates take longest, so any reordering would show
+		time.Sleep(time.Duration(20-u.UpdateID%20) * 100 * time.Microsecond)
+		mu.Lock()
+		handled[u.Message.Chat.ID] = append(handled[u.Message.Chat.ID], u.UpdateID)
+		mu.Unlock()
+	}, logger.New(io.Discard, logger.ErrorLevel, false))
+
+	for i := 0; i < 200; i++ {
+		d.Dispatch(chatUpdate(i, int64(i%5)))
+	}
+	d.Close()
+
+	for chat := int64(0); chat < 5; chat++ {
+		ids := handled[chat]
+		if len(ids) != 40 {
+			t.Errorf("chat %d: handled %d updates; want 40", chat, len(ids))
+		}
+		for i := 1; i < len(ids); i++ {
+			if ids[i] < ids[i-1] {
+				t.Errorf("chat %d: update %d handled after %d", chat, ids[i], ids[i-1])
+				break
+			}
+		}
+	}
+}
+
+func TestDispatcherRunsChatsInParallel(t *testing.T) {
+	release := make(chan struct{})
+	done := make(chan int64, 2)
+	d := NewDispatcher(2, func(u tgbotapi.Update) {
+		if u.Message.Chat.ID == 1 {
+			<-release // a slow image render
+		}
+		done <- u.Message.Chat.ID
+	}, logger.New(io.Discard, logger.ErrorLevel, false))
+
+	d.Dispatch(chatUpdate(1, 1))
+	d.Dispatch(chatUpdate(2, 2))
+	select {
+	case chat := <-done:
+		if chat != 2 {
+			t.Errorf("chat %d finished first; want 2", chat)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("a slow chat blocked another")
+	}
+	close(release)
+	d.Close()
+}
+
+func TestDispatcherRecoversFromPanics(t *testing.T) {
+	var mu sync.Mutex
+	var handled []int
+	d := NewDispatcher(1, func(u tgbotapi.Update) {
+		if u.UpdateID == 1 {
+			panic("bad callback")
+		}
+		mu.Lock()
+		handled = append(handled, u.UpdateID)
+		mu.Unlock()
+	}, logger.New(io.Discard, logger.ErrorLevel, false))
+
+	for i := 1; i <= 3; i++ {
+		d.Dispatch(chatUpdate(i, 7))
+	}
+	d.Close()
+
+	if len(handled) != 2 || handled[0] != 2 || handled[1] != 3 {
+		t.Errorf("handled %v after a panic; want [2 3]", handled)
+	}
+	if d.Dispatch(chatUpdate(4, 7)) {
+		t.Error("Dispatch accepted an update after Close")
+	}
+}
+
+func TestUpdateChatKey(t *testing.T) {
+	user := &tgbotapi.User{ID: 42}
+	tests := []struct {
+		update   tgbotapi.Update
+		expected int64
+	}{
+		{chatUpdate(1, -100), -100},
+		{tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{From: user, Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: -100}}}}, -100},
+		{tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{From: user, InlineMessageID: "x"}}, 42},
+		{tgbotapi.Update{InlineQuery: &tgbotapi.InlineQuery{From: user}}, 42},
+		{tgbotapi.Update{}, 0},
+	}
+	for i, tt := range tests {
+		if got := updateChatKey(tt.update); got != tt.expected {
+			t.Errorf("case %d: key %d; want %d", i, got, tt.expected)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package bot
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"html"
 	"io"
@@ -9,6 +11,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"hadith-bot/internal/image"
@@ -21,8 +24,27 @@ import (
 
 const telegramMessageMaxRunes = 3800
 
+// searchResultsPerPage is used for the first page and for Prev/Next, so pages
+// line up; each result is listed with its snippet
+const searchResultsPerPage = 5
+
+// similarHadithsLimit is how many hadiths the Similar button lists
+const similarHadithsLimit = 5
+
+// Facet buttons under search results: at most maxFacetButtons per field,
+// facetButtonsPerRow to a row
+const (
+	maxFacetButtons    = 6
+	facetButtonsPerRow = 2
+)
+
+// callbackDataMaxBytes is Telegram's limit on a button's callback data
+const callbackDataMaxBytes = 64
+
 type Handler struct {
-	bot                 *tgbotapi.BotAPI
+	bot                 TelegramClient
+	api                 *tgbotapi.BotAPI // receives webhook updates, for NewWebhook
+	botUserName         string
 	hadithService       *services.HadithService
 	log                 *logger.Logger
 	rateLimiter         *RateLimiter
@@ -30,11 +52,20 @@ type Handler struct {
 	state               *StateManager
 	imageCacheChannelID int64
 	adminUserID         int64
+	randomHistorySize   int // random hadiths remembered per chat so they are not repeated
+	scheduleWake        chan struct{}
+	dispatcher          *Dispatcher
+	sessions            *SessionStore // searches whose results are on screen
+	router              *Router
+	metrics             *Metrics
+	scheduler           sync.WaitGroup // the scheduler goroutine, for Shutdown
 }
 
-func NewHandler(bot *tgbotapi.BotAPI, hadithService *services.HadithService, log *logger.Logger, rateLimitRequests int, rateLimitWindow time.Duration, imageGenerator *image.Generator, state *StateManager, imageCacheChannelID int64, adminUserID int64) *Handler {
-	return &Handler{
+func NewHandler(bot *tgbotapi.BotAPI, hadithService *services.HadithService, log *logger.Logger, rateLimitRequests int, rateLimitWindow time.Duration, imageGenerator *image.Generator, state *StateManager, imageCacheChannelID int64, adminUserID int64, randomHistorySize int, updateWorkers int) *Handler {
+	h := &Handler{
 		bot:                 bot,
+		api:                 bot,
+		botUserName:         bot.Self.UserName,
 		hadithService:       hadithService,
 		log:                 log,
 		rateLimiter:         NewRateLimiter(rateLimitRequests, rateLimitWindow),
@@ -42,131 +73,363 @@ func NewHandler(bot *tgbotapi.BotAPI, hadithService *services.HadithService, log
 		state:               state,
 		imageCacheChannelID: imageCacheChannelID,
 		adminUserID:         adminUserID,
-	}
+		randomHistorySize:   randomHistorySize,
+		scheduleWake:        make(chan struct{}, 1),
+		sessions:            NewSessionStore(callbackSessionTTL, maxCallbackSessions),
+		metrics:             NewMetrics(),
+	}
+	h.router = h.newRouter()
+	h.dispatcher = NewDispatcher(updateWorkers, h.HandleUpdate, log)
+	return h
 }
 
-func (h *Handler) StartScheduler() {
-	ticker := time.NewTicker(1 * time.Minute)
+// maxSchedulerSleep bounds how long the scheduler sleeps, so a wall clock
+// that jumps, as after the host is suspended, is noticed within the hour
+const maxSchedulerSleep = time.Hour
+
+// StartScheduler sends the chats' scheduled hadiths until ctx is done. It
+// sleeps until the next slot is due, or until the slots change.
+func (h *Handler) StartScheduler(ctx context.Context) {
+	h.scheduler.Add(1)
 	go func() {
-		for range ticker.C {
-			h.processSchedules()
+		defer h.scheduler.Done()
+		for ctx.Err() == nil {
+			timer := time.NewTimer(h.processSchedules(ctx, time.Now()))
+			select {
+			case <-timer.C:
+			case <-h.scheduleWake:
+				timer.Stop()
+			case <-ctx.Done():
+				timer.Stop()
+			}
 		}
 	}()
 }
 
-func (h *Handler) processSchedules() {
-	states := h.state.GetAll()
-	now := time.Now()
+// wakeScheduler makes the scheduler look at the slots again after a chat
+// changes them
+func (h *Handler) wakeScheduler() {
+	select {
+	case h.scheduleWake <- struct{}{}:
+	default:
+	}
+}
 
-	for chatID, chatState := range states {
-		if chatState.ScheduleInterval > 0 && now.Sub(chatState.LastSentAt) >= chatState.ScheduleInterval {
-			// Update the time first to prevent double-sending if this takes a while
-			chatState.LastSentAt = now
-			h.state.SetChatState(chatID, chatState)
+// processSchedules sends a hadith to each chat with a slot due at now and
+// returns how long until the next slot is due. It stops early when ctx is
+// done; the slots it did not reach stay due for the next start.
+func (h *Handler) processSchedules(ctx context.Context, now time.Time) time.Duration {
+	wait := maxSchedulerSleep
 
-			// Generate and send random hadith image
-			res := h.hadithService.GetRandomHadith()
-			if res.Hadith == nil || res.Collection == nil {
-				continue // skip if we fail to fetch a random hadith
+	for chatID, chatState := range h.state.GetAll() {
+		for _, slot := range chatState.Schedules {
+			if ctx.Err() != nil {
+				return wait
 			}
-
-			book := h.hadithService.GetBook(res.Collection.Name, res.Hadith.ChapterID)
-			title := "Hadith"
-			if book != nil {
-				title = book.Title
-				if idx := strings.Index(title, ". "); idx != -1 {
-					title = title[idx+2:]
-				}
+			next := slot.Next()
+			if next.IsZero() {
+				continue
 			}
-
-			ref := fmt.Sprintf("[%s: %d]", services.GetCollectionDisplayName(res.Collection.Name), res.Hadith.HadithNumber)
-
-			imgBytes, err := h.imageGenerator.GenerateHadithImage(title, res.Hadith.Narrator, res.Hadith.Arabic, res.Hadith.English, ref, chatState.UseCustomBg, chatState.UseClassicArabic)
-			if err != nil {
-				h.log.Error("Failed to generate scheduled image for %d: %v", chatID, err)
+			if next.After(now) {
+				wait = min(wait, next.Sub(now))
 				continue
 			}
 
-			photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{
-				Name:  "hadith.png",
-				Bytes: imgBytes,
-			})
-			_, err = h.bot.Send(photo)
+			// Update the time first to prevent double-sending if this takes a while
+			if err := h.state.MarkScheduleSent(chatID, slot.ID, now); err != nil {
+				h.log.Error("Failed to save schedule #%d of %d: %v", slot.ID, chatID, err)
+			}
 
-			// If sending the photo fails (e.g., media disabled in group), fallback to text mode
-			if err != nil {
-				h.log.Error("Failed to send scheduled image for %d (falling back to text): %v", chatID, err)
-				h.sendRandomHadithPaged(chatID, 0, "", res.Collection.Name, res.Hadith.HadithNumber, 0)
+			// Interval slots catch up after a restart, as they always have;
+			// wall-clock slots only within scheduleGrace
+			if slot.Every > 0 || now.Sub(next) <= scheduleGrace {
+				h.sendScheduledHadith(chatID, slot)
+			} else {
+				h.log.Info("Skipping schedule #%d of %d, missed at %v", slot.ID, chatID, next)
+			}
+
+			slot.LastSentAt = now
+			if next := slot.Next(); !next.IsZero() {
+				wait = min(wait, next.Sub(now))
 			}
 		}
 	}
+	return wait
 }
 
-func (h *Handler) StartListening() {
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
-	updates := h.bot.GetUpdatesChan(u)
+// sendScheduledHadith posts a random hadith to a chat with a slot's content:
+// from the hadiths it draws from, as an image, text or both, in its
+// languages
+func (h *Handler) sendScheduledHadith(chatID int64, slot Schedule) {
+	content := slot.Content
+	res, ok := h.pickRandomHadith(chatID, content.Filter())
+	if !ok && (len(content.Collections) > 0 || content.Topic != "") {
+		// The data may have changed under the slot's filter since it was set
+		h.log.Warn("Schedule #%d of %d matches no hadiths, picking from all", slot.ID, chatID)
+		res, ok = h.pickRandomHadith(chatID, models.SearchFilter{})
+	}
+	if !ok {
+		return // skip if we fail to fetch a random hadith
+	}
+
+	if content.Format == FormatText {
+		h.sendScheduledText(chatID, res, content.Language)
+		return
+	}
 
-	for update := range updates {
-		if update.Message != nil {
-			h.handleIncomingMessage(update.Message)
-		} else if update.CallbackQuery != nil {
-			h.handleCallback(update.CallbackQuery)
-		} else if update.InlineQuery != nil {
-			h.handleInlineQuery(update.InlineQuery)
+	book := h.hadithService.GetBook(res.Collection.Name, res.Hadith.ChapterID)
+	title := "Hadith"
+	if book != nil {
+		title = book.Title
+		if idx := strings.Index(title, ". "); idx != -1 {
+			title = title[idx+2:]
 		}
 	}
-}
 
-func (h *Handler) handleIncomingMessage(m *tgbotapi.Message) {
-	if !h.rateLimiter.Allow(m.From.ID) {
-		h.sendMessage(m.Chat.ID, "⏳ Please wait a moment before sending another command.")
+	ref := fmt.Sprintf("[%s: %d]", h.hadithService.GetCollectionDisplayName(res.Collection.Name), res.Hadith.HadithNumber)
+
+	arabic, english := res.Hadith.Arabic, res.Hadith.English
+	switch content.Language {
+	case LanguageArabic:
+		english = ""
+	case LanguageEnglish:
+		arabic = ""
+	}
+
+	imgBytes, err := h.imageGenerator.GenerateHadithImage(title, res.Hadith.Narrator, arabic, english, ref, content.UseCustomBg, content.UseClassicArabic)
+	if err == nil {
+		photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{
+			Name:  "hadith.png",
+			Bytes: imgBytes,
+		})
+		_, err = h.bot.Send(photo)
+	}
+
+	// If the image fails (e.g., media disabled in group), fallback to text mode
+	if err != nil {
+		h.log.Error("Failed to send scheduled image for %d (falling back to text): %v", chatID, err)
+		h.sendScheduledText(chatID, res, content.Language)
 		return
 	}
+	if content.Format == FormatBoth {
+		h.sendScheduledText(chatID, res, content.Language)
+	}
+}
+
+// sendScheduledText posts a hadith as text in a language, in as many
+// messages as it takes, with the random hadith buttons under the last
+func (h *Handler) sendScheduledText(chatID int64, res models.RandomHadithResult, language string) {
+	hadith := *res.Hadith
+	switch language {
+	case LanguageArabic:
+		hadith.English = ""
+	case LanguageEnglish:
+		hadith.Arabic = ""
+	}
+	txt := h.formatHadithDisplay(&hadith, res.Collection, res.Book)
+	for strings.Contains(txt, "\n\n\n") {
+		txt = strings.ReplaceAll(txt, "\n\n\n", "\n\n")
+	}
+
+	pages := splitTelegramMessage(txt, telegramMessageMaxRunes)
+	if len(pages) == 0 {
+		pages = []string{txt}
+	}
+	for _, page := range pages[:len(pages)-1] {
+		h.sendMessage(chatID, page)
+	}
 
-	if m.Photo != nil && len(m.Photo) > 0 {
-		if strings.HasPrefix(m.Caption, "/addbg") {
-			h.handleAddBackground(m)
+	colName, hadithNum := res.Collection.Name, res.Hadith.HadithNumber
+	shareURL := fmt.Sprintf("https://t.me/%s?start=hadith_%s_%d", h.botUserName, colName, hadithNum)
+	h.sendMessageWithKeyboard(chatID, pages[len(pages)-1], tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🎲 Another Random", "random"),
+		tgbotapi.NewInlineKeyboardButtonData("🎨 Image", fmt.Sprintf("hadith_image:%s:%d", colName, hadithNum)),
+		tgbotapi.NewInlineKeyboardButtonURL("📤 Share", shareURL),
+	)))
+}
+
+// StartListening long-polls for updates until ctx is done. Telegram refuses
+// to poll while a webhook is registered, so one left from webhook mode is
+// removed first.
+//
+// Each poll confirms the updates before its offset, so the offset only moves
+// past updates handed to the dispatcher: whatever Telegram sent but was not
+// dispatched when ctx is done is sent again after a restart.
+func (h *Handler) StartListening(ctx context.Context) {
+	if _, err := h.bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		h.log.Error("Failed to remove webhook: %v", err)
+	}
+
+	type poll struct {
+		updates []tgbotapi.Update
+		err     error
+	}
+	// A poll in flight when ctx is done is abandoned; its updates are
+	// past the offset, so they are not lost
+	polled := make(chan poll, 1)
+
+	offset := 0
+	for {
+		u := tgbotapi.NewUpdate(offset)
+		u.Timeout = 60
+		go func() {
+			updates, err := h.bot.GetUpdates(u)
+			polled <- poll{updates, err}
+		}()
+
+		var p poll
+		select {
+		case p = <-polled:
+		case <-ctx.Done():
+			// A batch that arrived as ctx was done is still handled
+			select {
+			case p = <-polled:
+				if p.err == nil {
+					offset = h.dispatchPolled(p.updates, offset)
+				}
+			default:
+			}
+			h.confirmUpdates(offset)
 			return
 		}
+
+		if p.err != nil {
+			h.log.Warn("Failed to get updates, retrying in 3 seconds: %v", p.err)
+			select {
+			case <-time.After(3 * time.Second):
+			case <-ctx.Done():
+				h.confirmUpdates(offset)
+				return
+			}
+			continue
+		}
+		offset = h.dispatchPolled(p.updates, offset)
 	}
+}
 
-	if m.IsCommand() {
-		switch m.Command() {
-		case "start":
-			h.handleStart(m)
-		case "help":
-			h.handleHelp(m)
-		case "random":
-			h.handleRandom(m)
-		case "search":
-			h.handleSearch(m)
-		case "collections":
-			h.handleCollections(m)
-		case "togglebackgrounds":
-			h.handleToggleBackgrounds(m)
-		case "togglearabic":
-			h.handleToggleArabic(m)
-		case "schedule":
-			h.handleSchedule(m)
-		case "addbg":
-			// If they just typed /addbg without a photo
-			h.sendMessage(m.Chat.ID, "🖼️ Please send a photo and include `/addbg` in the caption to add a new background.")
+// dispatchPolled dispatches a batch of polled updates and returns the offset
+// past the last one dispatched
+func (h *Handler) dispatchPolled(updates []tgbotapi.Update, offset int) int {
+	for _, update := range updates {
+		if update.UpdateID < offset {
+			continue
 		}
+		if !h.dispatcher.Dispatch(update) {
+			break
+		}
+		offset = update.UpdateID + 1
 	}
+	return offset
+}
+
+// confirmUpdates tells Telegram the updates before offset were received.
+// Polling only confirms a batch with the next request, so without this the
+// last batch would be handled again after a restart.
+func (h *Handler) confirmUpdates(offset int) {
+	if offset == 0 {
+		return
+	}
+	u := tgbotapi.NewUpdate(offset)
+	u.Limit = 1
+	if _, err := h.bot.GetUpdates(u); err != nil {
+		h.log.Warn("Failed to confirm updates before %d: %v", offset, err)
+	}
+}
+
+// Shutdown stops taking updates, waits for the ones queued and the
+// scheduler's sends to finish, and saves the state. It gives up waiting
+// when ctx is done, saving the state as it is. StartScheduler and
+// StartListening must have been stopped through their context first.
+func (h *Handler) Shutdown(ctx context.Context) error {
+	drained := make(chan struct{})
+	go func() {
+		h.dispatcher.Close()
+		h.scheduler.Wait()
+		close(drained)
+	}()
+
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = fmt.Errorf("waiting for updates and scheduled sends: %w", ctx.Err())
+	}
+
+	if saveErr := h.state.Save(); saveErr != nil {
+		return errors.Join(err, fmt.Errorf("saving state: %w", saveErr))
+	}
+	return err
+}
+
+// HandleUpdate handles one update, however it was received
+func (h *Handler) HandleUpdate(update tgbotapi.Update) {
+	h.router.Route(update)
+}
+
+// newRouter registers the bot's commands, buttons and inline queries, behind
+// the middleware every request goes through
+func (h *Handler) newRouter() *Router {
+	r := NewRouter(h.bot, h.log)
+	r.Use(h.metrics.Middleware(), Logging(h.log), Recover(h.log), RateLimit(h.rateLimiter))
+
+	r.Command("start", onMessage(h.handleStart))
+	r.Command("help", onMessage(h.handleHelp))
+	r.Command("random", onMessage(h.handleRandom))
+	r.Command("search", onMessage(h.handleSearch))
+	r.Command("hadith", onMessage(h.handleHadith))
+	r.Command("collections", onMessage(h.handleCollections))
+	r.Command("narrators", func(req *Request) { h.sendNarratorsMenu(req.ChatID(), 0, "", 1) })
+	r.Command("topics", func(req *Request) { h.sendTopicsMenu(req.ChatID(), 0, "", 1) })
+	r.Command("today", onMessage(h.handleToday))
+	r.Command("togglebackgrounds", onMessage(h.handleToggleBackgrounds))
+	r.Command("togglearabic", onMessage(h.handleToggleArabic))
+	r.Command("schedule", onMessage(h.handleSchedule), GroupAdminOnly(h.bot, "⚠️ Only group administrators can change the schedule."))
+	r.Command("datareport", onMessage(h.handleDataReport), RequireAdmin(h.adminUserID, "⚠️ You do not have permission to view the data report."))
+	r.Command("stats", h.handleStats, RequireAdmin(h.adminUserID, "⚠️ You do not have permission to view the bot's statistics."))
+	r.Command("addbg", onMessage(h.handleAddBackground), AdminOnly(h.adminUserID, "⚠️ You do not have permission to add new backgrounds."))
+	r.Message(onMessage(h.handleReferenceMessage))
+
+	h.registerCallbacks(r)
+	h.registerScheduleCallbacks(r)
+
+	r.Inline("", h.handleInlineBrowse)
+	r.Inline("collections", h.handleInlineBrowse)
+	r.Inline("random", h.handleInlineRandom)
+	r.Inline("image-search", h.handleInlineImageSearch)
+	r.Inline("search", h.handleInlineSearch)
+	r.Inline("today", h.handleInlineToday)
+	r.Inline("ref", h.handleInlineRef)
+	return r
+}
+
+// onMessage adapts a handler of a command's message to the router
+func onMessage(handle func(m *tgbotapi.Message)) HandlerFunc {
+	return func(req *Request) { handle(req.Message) }
+}
+
+// handleReferenceMessage opens the hadith a plain private message refers to,
+// such as "Muslim 2564"
+func (h *Handler) handleReferenceMessage(m *tgbotapi.Message) {
+	if !m.Chat.IsPrivate() || m.Text == "" {
+		return
+	}
+	if ref, ok := h.hadithService.ParseReference(m.Text); ok {
+		h.sendReference(m.Chat.ID, ref)
+	}
+}
+
+// handleStats shows the administrator how often each command, button and
+// inline query was used
+func (h *Handler) handleStats(req *Request) {
+	h.sendMessage(req.ChatID(), fmt.Sprintf("📊 <b>Requests</b>\n\n<pre>%s</pre>", html.EscapeString(h.metrics.Summary())))
 }
 
 // --- COMMAND HANDLERS ---
 
 func (h *Handler) handleStart(m *tgbotapi.Message) {
-	chatState := h.state.GetChatState(m.Chat.ID)
-	if chatState == nil {
-		chatState = &ChatState{
-			ScheduleInterval: 6 * time.Hour,
-			LastSentAt:       time.Now(),
-		}
-		h.state.SetChatState(m.Chat.ID, chatState)
-	}
+	h.state.Create(m.Chat.ID, &ChatState{
+		Schedules: []Schedule{{ID: 1, Every: 6 * time.Hour, LastSentAt: time.Now()}},
+	})
 
 	args := m.CommandArguments()
 	if strings.HasPrefix(args, "hadith_") {
@@ -204,111 +467,303 @@ func (h *Handler) handleHelp(m *tgbotapi.Message) {
 <b>Commands</b>
 • <b>/start</b> — Open the main menu
 • <b>/collections</b> — Browse hadith collections
+• <b>/narrators</b> — Browse narrators and their hadiths
+• <b>/topics</b> — Browse hadiths by topic
 • <b>/search &lt;keyword&gt;</b> — Search hadith text
-• <b>/random</b> — Get a random hadith
+• <b>/hadith &lt;reference&gt;</b> — Open a hadith, e.g. <b>/hadith bukhari 1</b>
+• <b>/random</b> — Get a random hadith, or <b>/random &lt;topic&gt;</b> for one on a topic
+• <b>/today</b> — The hadith of the day, the same for everyone
 • <b>/togglebackgrounds</b> — Toggle custom image backgrounds for generated images
 • <b>/togglearabic</b> — Toggle classic Arabic font for generated images
 • <b>/help</b> — Show this help message
 • <b>/addbg</b> — Add a new custom background (send a photo with '/addbg' as the caption)
+• <b>/datareport</b> — Show which data files loaded and any problems found (admin)
+• <b>/stats</b> — Show how often each command, button and inline query was used (admin)
 
 💡 <b>Examples</b>
 • <b>/search prayer</b>
-• <b>/search patience</b>`
+• <b>/search patience</b>
+• <b>/hadith muslim 2564</b>, <b>/hadith bukhari 2:15</b>, <b>/hadith nasai 100-105</b>
+• In a private chat, just send a reference like <b>Muslim 2564</b>
+
+🔎 <b>Search syntax</b>
+• <b>"exact phrase"</b> — words next to each other
+• <b>prayer OR fasting</b> — either word (words are ANDed by default)
+• <b>-wine</b> — exclude a word or "phrase"
+• <b>narrator:"Abu Huraira"</b> — search the narrator only
+• <b>collection:bukhari</b>, <b>grade:sahih</b>, <b>book:2</b>, <b>book:2-5</b> or <b>topic:prayer</b> — filters`
 	h.sendMessage(m.Chat.ID, helpText)
 }
 
 func (h *Handler) handleRandom(m *tgbotapi.Message) {
-	res := h.hadithService.GetRandomHadith()
-	if res.Hadith == nil || res.Collection == nil {
+	if args := strings.TrimSpace(m.CommandArguments()); args != "" {
+		topic, ok := h.hadithService.ResolveTopic(args)
+		if !ok {
+			h.sendMessage(m.Chat.ID, fmt.Sprintf("⚠️ Unknown topic %q. Send <b>/topics</b> to see them all.", html.EscapeString(args)))
+			return
+		}
+		h.sendRandomInTopic(m.Chat.ID, 0, "", topic.ID)
+		return
+	}
+
+	res, ok := h.pickRandomHadith(m.Chat.ID, models.SearchFilter{})
+	if !ok {
 		h.sendMessage(m.Chat.ID, "⚠️ Could not fetch a hadith right now. Please try again.")
 		return
 	}
 	h.sendRandomHadithPaged(m.Chat.ID, 0, "", res.Collection.Name, res.Hadith.HadithNumber, 0)
 }
 
+func (h *Handler) handleToday(m *tgbotapi.Message) {
+	daily, ok := h.hadithService.HadithOfTheDay(time.Now())
+	if !ok {
+		h.sendMessage(m.Chat.ID, "⚠️ There is no hadith of the day right now. Please try again later.")
+		return
+	}
+	h.sendHadithView(m.Chat.ID, 0, "", daily.Collection.Name, daily.Hadith.HadithNumber, 0, todayHeading(daily))
+}
+
+// todayHeading titles the hadith of the day with its date
+func todayHeading(daily models.DailyHadith) string {
+	return fmt.Sprintf("📅 <b>Hadith of the Day</b> — %s", todayDate(daily))
+}
+
+// todayDate writes the hadith of the day's date as "17 October 2026"
+func todayDate(daily models.DailyHadith) string {
+	date, err := time.Parse("2006-01-02", daily.Date)
+	if err != nil {
+		return daily.Date
+	}
+	return date.Format("2 January 2006")
+}
+
 func (h *Handler) handleSearch(m *tgbotapi.Message) {
 	args := m.CommandArguments()
 	if args == "" {
 		h.sendMessage(m.Chat.ID, "🔎 Please provide a keyword. Example: <b>/search prayer</b>")
 		return
 	}
-	results := h.hadithService.SearchHadiths(args, 1, 10)
+	results, err := h.hadithService.SearchHadiths(args, 1, searchResultsPerPage)
+	if err != nil {
+		h.sendMessage(m.Chat.ID, searchErrorText(err))
+		return
+	}
+	session := SearchSession{Query: args, Page: results.Page, Origin: m.Chat.ID}
+	session.offer(results)
 	if len(results.Hadiths) == 0 {
-		h.sendMessage(m.Chat.ID, "No results found for your search. Try a different keyword.")
+		id := ""
+		if results.Suggestion != "" {
+			id = h.sessions.Put(session)
+		}
+		h.sendNoSearchResults(m.Chat.ID, 0, "", id, results)
 		return
 	}
-	h.sendSearchResults(m.Chat.ID, 0, "", args, results)
+	h.sendSearchResults(m.Chat.ID, 0, "", h.sessions.Put(session), session, results)
+}
+
+func (h *Handler) handleHadith(m *tgbotapi.Message) {
+	args := strings.TrimSpace(m.CommandArguments())
+	ref, ok := h.hadithService.ParseReference(args)
+	if !ok {
+		h.sendMessage(m.Chat.ID, "📖 Please give a reference. Examples: <b>/hadith bukhari 1</b>, <b>/hadith muslim:2564</b>, <b>/hadith bukhari 2:15</b> or <b>/hadith nasai 100-105</b>")
+		return
+	}
+	h.sendReference(m.Chat.ID, ref)
+}
+
+// sendReference opens a single referenced hadith directly, or lists a range
+// of hadiths as buttons
+func (h *Handler) sendReference(chatID int64, ref services.Reference) {
+	hadiths := h.hadithService.LookupReference(ref)
+	if len(hadiths) == 0 {
+		h.sendMessage(chatID, fmt.Sprintf("⚠️ Could not find that hadith in %s.", html.EscapeString(h.hadithService.GetCollectionDisplayName(ref.Collection))))
+		return
+	}
+	if len(hadiths) == 1 {
+		h.sendSearchHadithPaged(chatID, 0, "", ref.Collection, hadiths[0].HadithNumber, 0)
+		return
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, hd := range hadiths {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("📜 Hadith #%d", hd.HadithNumber), fmt.Sprintf("hadith_search:%s:%d", ref.Collection, hd.HadithNumber))))
+	}
+
+	title := fmt.Sprintf("📖 <b>%s %d–%d</b>", html.EscapeString(h.hadithService.GetCollectionDisplayName(ref.Collection)), ref.From, ref.To)
+	if ref.Book > 0 {
+		title = fmt.Sprintf("📖 <b>%s, Book %d: %d–%d</b>", html.EscapeString(h.hadithService.GetCollectionDisplayName(ref.Collection)), ref.Book, ref.From, ref.To)
+	}
+	h.sendMessageWithKeyboard(chatID, title, tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+// searchErrorText turns a query parse error into a reply with a syntax hint
+func searchErrorText(err error) string {
+	return fmt.Sprintf("⚠️ %s\n\n💡 Try <b>/search prayer</b>, <b>/search \"fear Allah\" -wine</b> or <b>/search fasting collection:bukhari</b>.", html.EscapeString(err.Error()))
 }
 
 func (h *Handler) handleCollections(m *tgbotapi.Message) {
 	h.sendCollectionsMenu(m.Chat.ID, 0, "", h.hadithService.GetCollections(), 1)
 }
 
+// handleSchedule lists, adds and removes a chat's scheduled times. The
+// router lets only group administrators through.
 func (h *Handler) handleSchedule(m *tgbotapi.Message) {
-	if m.Chat.IsGroup() || m.Chat.IsSuperGroup() {
-		member, err := h.bot.GetChatMember(tgbotapi.GetChatMemberConfig{
-			ChatConfigWithUser: tgbotapi.ChatConfigWithUser{
-				ChatID: m.Chat.ID,
-				UserID: m.From.ID,
-			},
-		})
+	args := strings.TrimSpace(m.CommandArguments())
 
-		if err != nil || (!member.IsCreator() && !member.IsAdministrator()) {
-			h.sendMessage(m.Chat.ID, "⚠️ Only group administrators can change the schedule.")
-			return
-		}
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		h.sendScheduleList(m.Chat.ID, 0)
+		return
 	}
 
-	args := strings.TrimSpace(m.CommandArguments())
-	chatState := h.state.GetChatState(m.Chat.ID)
-	if chatState == nil {
-		chatState = &ChatState{
-			LastSentAt: time.Now(),
+	switch rest := strings.TrimSpace(args[len(fields[0]):]); strings.ToLower(fields[0]) {
+	case "list":
+		h.sendScheduleList(m.Chat.ID, 0)
+	case "off":
+		h.state.Update(m.Chat.ID, func(s *ChatState) { s.Schedules = nil })
+		h.wakeScheduler()
+		h.sendMessage(m.Chat.ID, "✅ Automatic scheduled messages have been turned <b>OFF</b>.")
+	case "remove", "delete":
+		h.removeSchedule(m.Chat.ID, rest)
+	case "add":
+		h.addSchedule(m.Chat.ID, rest)
+	default:
+		h.addSchedule(m.Chat.ID, args)
+	}
+}
+
+// scheduleUsage explains the /schedule subcommands
+const scheduleUsage = `Use <code>/schedule add daily 06:30 Asia/Karachi</code>, <code>/schedule add mon,thu 20:00</code> or <code>/schedule add every 6h</code> to add a time, <code>/schedule remove &lt;id&gt;</code> to remove one, and <code>/schedule off</code> to remove them all. Tap a time's ⚙️ button to choose what it posts.`
+
+// sendScheduleList lists a chat's slots with when each fires next, and a
+// button per slot for its settings
+func (h *Handler) sendScheduleList(chatID int64, msgID int) {
+	chatState := h.state.GetChatState(chatID)
+	if chatState == nil || len(chatState.Schedules) == 0 {
+		h.editOrSendMessage(chatID, msgID, "", "🕒 There is currently no active schedule.\n\n"+scheduleUsage, tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}})
+		return
+	}
+
+	var sb strings.Builder
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	sb.WriteString("🕒 <b>Scheduled hadiths</b>\n\n")
+	for _, slot := range chatState.Schedules {
+		sb.WriteString(fmt.Sprintf("<b>#%d</b> %s — next %s\n", slot.ID, html.EscapeString(slot.String()), formatNextFire(slot, chatState.Timezone)))
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("⚙️ #%d", slot.ID), fmt.Sprintf("sched:menu:%d", slot.ID)))
+		if len(row) == 3 {
+			rows = append(rows, row)
+			row = nil
 		}
 	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	sb.WriteString("\n" + scheduleUsage)
+	h.editOrSendMessage(chatID, msgID, "", sb.String(), tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
 
-	if args == "" {
-		if chatState.ScheduleInterval > 0 {
-			h.sendMessage(m.Chat.ID, fmt.Sprintf("🕒 Current schedule is set to **%v**.\n\nUse `/schedule off` to disable, or `/schedule <duration>` to change (e.g., `2h`, `12h`).", chatState.ScheduleInterval))
-		} else {
-			h.sendMessage(m.Chat.ID, "🕒 There is currently no active schedule.\n\nUse `/schedule <duration>` to enable (e.g., `2h`, `6h`, `12h`).")
+// formatNextFire writes when a slot fires next in its timezone; interval
+// slots use the chat's
+func formatNextFire(slot Schedule, chatTimezone string) string {
+	next := slot.Next()
+	if next.IsZero() {
+		return "never"
+	}
+	if !next.After(time.Now()) {
+		return "now"
+	}
+	loc := slot.Location()
+	if slot.Every > 0 {
+		loc = Schedule{Timezone: chatTimezone}.Location()
+	}
+	return next.In(loc).Format("Mon 2 Jan 15:04 MST")
+}
+
+// addSchedule adds a slot to a chat. A timezone given with it becomes the
+// chat's default for later slots.
+func (h *Handler) addSchedule(chatID int64, args string) {
+	var (
+		slot     Schedule
+		timezone string
+		problem  string // why the slot was not added
+	)
+	h.state.Update(chatID, func(s *ChatState) {
+		if len(s.Schedules) >= maxSchedulesPerChat {
+			problem = fmt.Sprintf("⚠️ A chat can have at most %d scheduled times. Remove one with <code>/schedule remove &lt;id&gt;</code> first.", maxSchedulesPerChat)
+			return
+		}
+
+		parsed, err := ParseSchedule(args, s.Timezone)
+		if err != nil {
+			problem = fmt.Sprintf("⚠️ Invalid schedule: %v.\n\n%s", err, scheduleUsage)
+			return
+		}
+		for _, existing := range s.Schedules {
+			if existing.String() == parsed.String() {
+				problem = fmt.Sprintf("ℹ️ This chat is already scheduled %s (#%d).", html.EscapeString(parsed.String()), existing.ID)
+				return
+			}
 		}
+
+		slot = parsed
+		slot.ID = nextScheduleID(s.Schedules)
+		slot.LastSentAt = time.Now()
+		slot.Content = s.defaultContent()
+		s.Schedules = append(s.Schedules, slot)
+		if slot.Every == 0 {
+			s.Timezone = slot.Timezone
+		}
+		timezone = s.Timezone
+	})
+	if problem != "" {
+		h.sendMessage(chatID, problem)
 		return
 	}
+	h.wakeScheduler()
+
+	h.sendMessageWithKeyboard(chatID, fmt.Sprintf("✅ Schedule updated! A random hadith image will be sent %s (#%d). Next: %s.", html.EscapeString(slot.String()), slot.ID, formatNextFire(slot, timezone)), tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⚙️ Choose what it posts", fmt.Sprintf("sched:menu:%d", slot.ID)),
+	)))
+}
 
-	if strings.ToLower(args) == "off" {
-		chatState.ScheduleInterval = 0
-		h.state.SetChatState(m.Chat.ID, chatState)
-		h.sendMessage(m.Chat.ID, "✅ Automatic scheduled messages have been turned **OFF**.")
+// removeSchedule removes one of a chat's slots by ID
+func (h *Handler) removeSchedule(chatID int64, args string) {
+	id, ok := parseScheduleID(args)
+	if !ok {
+		h.sendMessage(chatID, "⚠️ Say which time to remove, such as <code>/schedule remove 2</code>. Send /schedule to see their IDs.")
 		return
 	}
 
-	d, err := time.ParseDuration(args)
-	if err != nil || d <= 0 {
-		h.sendMessage(m.Chat.ID, "⚠️ Invalid duration format. Please use something like `2h`, `6h`, or `12h`.")
+	var (
+		removed Schedule
+		found   bool
+	)
+	h.state.Update(chatID, func(s *ChatState) {
+		for i, slot := range s.Schedules {
+			if slot.ID == id {
+				s.Schedules = append(s.Schedules[:i], s.Schedules[i+1:]...)
+				removed, found = slot, true
+				return
+			}
+		}
+	})
+	if !found {
+		h.sendMessage(chatID, fmt.Sprintf("⚠️ There is no scheduled time #%d. Send /schedule to see them.", id))
 		return
 	}
-
-	chatState.ScheduleInterval = d
-	// Reset the timer when they set a new schedule
-	chatState.LastSentAt = time.Now()
-	h.state.SetChatState(m.Chat.ID, chatState)
-
-	h.sendMessage(m.Chat.ID, fmt.Sprintf("✅ Schedule updated! A random hadith image will be sent every **%v**.", d))
+	h.wakeScheduler()
+	h.sendMessage(chatID, fmt.Sprintf("✅ Removed #%d, %s.", id, html.EscapeString(removed.String())))
 }
 
 func (h *Handler) handleToggleArabic(m *tgbotapi.Message) {
 	userID := m.From.ID
 
-	chatState := h.state.GetChatState(userID)
-	if chatState == nil {
-		chatState = &ChatState{}
-	}
-
-	newSetting := !chatState.UseClassicArabic
-	chatState.UseClassicArabic = newSetting
-
-	h.state.SetChatState(userID, chatState)
+	var newSetting bool
+	h.state.Update(userID, func(s *ChatState) {
+		s.UseClassicArabic = !s.UseClassicArabic
+		newSetting = s.UseClassicArabic
+	})
 
 	var status string
 	if newSetting {
@@ -324,15 +779,11 @@ func (h *Handler) handleToggleArabic(m *tgbotapi.Message) {
 func (h *Handler) handleToggleBackgrounds(m *tgbotapi.Message) {
 	userID := m.From.ID
 
-	chatState := h.state.GetChatState(userID)
-	if chatState == nil {
-		chatState = &ChatState{}
-	}
-
-	newSetting := !chatState.UseCustomBg
-	chatState.UseCustomBg = newSetting
-
-	h.state.SetChatState(userID, chatState)
+	var newSetting bool
+	h.state.Update(userID, func(s *ChatState) {
+		s.UseCustomBg = !s.UseCustomBg
+		newSetting = s.UseCustomBg
+	})
 
 	var status string
 	if newSetting {
@@ -345,10 +796,12 @@ func (h *Handler) handleToggleBackgrounds(m *tgbotapi.Message) {
 	h.sendMessage(m.Chat.ID, text)
 }
 
+// handleAddBackground saves a photo captioned /addbg as a background for
+// generated images. The router lets only the bot's administrator through.
 func (h *Handler) handleAddBackground(m *tgbotapi.Message) {
-	// 1. Check permissions: if AdminUserID is set, only that user can add backgrounds
-	if h.adminUserID != 0 && m.From.ID != h.adminUserID {
-		h.sendMessage(m.Chat.ID, "⚠️ You do not have permission to add new backgrounds.")
+	// 1. If they just typed /addbg without a photo
+	if len(m.Photo) == 0 {
+		h.sendMessage(m.Chat.ID, "🖼️ Please send a photo and include `/addbg` in the caption to add a new background.")
 		return
 	}
 
@@ -357,16 +810,13 @@ func (h *Handler) handleAddBackground(m *tgbotapi.Message) {
 	largestPhoto := photos[len(photos)-1]
 
 	// 3. Get the file URL
-	fileConfig := tgbotapi.FileConfig{FileID: largestPhoto.FileID}
-	file, err := h.bot.GetFile(fileConfig)
+	downloadURL, err := h.bot.GetFileDirectURL(largestPhoto.FileID)
 	if err != nil {
 		h.log.Error("Failed to get file info from Telegram: %v", err)
 		h.sendMessage(m.Chat.ID, "⚠️ Failed to process the image. Please try again.")
 		return
 	}
 
-	downloadURL := file.Link(h.bot.Token)
-
 	// 4. Download the image
 	resp, err := http.Get(downloadURL)
 	if err != nil {
@@ -425,66 +875,43 @@ func (h *Handler) handleAddBackground(m *tgbotapi.Message) {
 	h.sendMessage(m.Chat.ID, "✅ Successfully added the new background!")
 }
 
-// --- CALLBACK HANDLER ---
-
-func (h *Handler) handleCallback(c *tgbotapi.CallbackQuery) {
-	if !h.rateLimiter.Allow(c.From.ID) {
-		h.bot.Request(tgbotapi.NewCallback(c.ID, "⏳ Slow down a little."))
+// handleDataReport shows how the data files loaded. The router lets only
+// the bot's administrator through.
+func (h *Handler) handleDataReport(m *tgbotapi.Message) {
+	report := h.hadithService.LoadReport()
+	if report == nil {
+		h.sendMessage(m.Chat.ID, "ℹ️ No data directory was loaded; the bot is using its default data.")
 		return
 	}
 
-	parts := strings.Split(c.Data, ":")
-	var chatID int64
-	var msgID int
-	if c.Message != nil {
-		chatID = c.Message.Chat.ID
-		msgID = c.Message.MessageID
-	}
-	iMID := c.InlineMessageID
-
-	switch parts[0] {
-	case "collections":
-		page, _ := strconv.Atoi(parts[1])
-		h.sendCollectionsMenu(chatID, msgID, iMID, h.hadithService.GetCollections(), page)
-	case "books":
-		colName := parts[1]
-		page, _ := strconv.Atoi(parts[2])
-		h.sendBooksMenu(chatID, msgID, iMID, colName, h.hadithService.GetBooks(colName), page)
-	case "hadiths":
-		colName := parts[1]
-		bookNum, _ := strconv.Atoi(parts[2])
-		page, _ := strconv.Atoi(parts[3])
-		res := h.hadithService.GetHadiths(colName, bookNum, page, 10)
-		h.sendHadithsMenu(chatID, msgID, iMID, colName, bookNum, res)
-	case "hadith_detail":
-		h.handleHadithDetailCallback(c, parts)
-	case "hadith_search":
-		h.handleHadithSearchCallback(c, parts)
-	case "hadith_page":
-		h.handleHadithPageCallback(c, parts)
-	case "random":
-		h.handleRandomCallback(c)
-	case "search_next", "search_prev":
-		query := parts[1]
-		page, _ := strconv.Atoi(parts[2])
-		res := h.hadithService.SearchHadiths(query, page, 5)
-		h.sendSearchResults(chatID, msgID, iMID, query, res)
-	case "help":
-		h.sendMessage(chatID, "Use <b>/help</b> to view all commands and examples.")
-	case "hadith_image":
-		h.handleHadithImageCallback(c, parts)
-	}
-
-	h.bot.Request(tgbotapi.NewCallback(c.ID, ""))
+	// Split the plain text first so no page cuts through the <pre> block
+	for i, page := range splitTelegramMessage(report.Summary(3), telegramMessageMaxRunes/2) {
+		text := fmt.Sprintf("<pre>%s</pre>", html.EscapeString(page))
+		if i == 0 {
+			text = "🗂 <b>Data Load Report</b>\n\n" + text
+		}
+		h.sendMessage(m.Chat.ID, text)
+	}
 }
 
 // --- INLINE QUERY HANDLER ---
 
-func (h *Handler) handleInlineQuery(q *tgbotapi.InlineQuery) {
-	query := strings.TrimSpace(q.Query)
+// answerInline answers an inline query with its results
+func (h *Handler) answerInline(q *tgbotapi.InlineQuery, results []interface{}) {
+	h.bot.Request(tgbotapi.InlineConfig{
+		InlineQueryID: q.ID,
+		Results:       results,
+		CacheTime:     10,
+	})
+}
+
+// handleInlineBrowse offers the collections menu for an empty query or
+// "collections", and nothing for queries no other prefix takes
+func (h *Handler) handleInlineBrowse(req *Request) {
+	q := req.Inline
 	var results []interface{}
 
-	if query == "" || query == "collections" {
+	if req.Route != "" || req.Args == "" {
 		text := "📚 <b>Browse Hadith Collections</b>\nSelect a collection to view its books and hadiths."
 		article := tgbotapi.NewInlineQueryResultArticleHTML(q.ID+"_browse", "📚 Browse Collections", text)
 		article.Description = "View Bukhari, Muslim, and other major collections"
@@ -498,145 +925,220 @@ func (h *Handler) handleInlineQuery(q *tgbotapi.InlineQuery) {
 		results = append(results, article)
 	}
 
-	if query == "random" {
-		res := h.hadithService.GetRandomHadith()
-		if res.Hadith != nil && res.Collection != nil {
-			txt := h.formatHadithDisplay(res.Hadith, res.Collection, res.Book)
-			pages := splitTelegramMessage(txt, telegramMessageMaxRunes)
-			if len(pages) == 0 {
-				pages = []string{txt}
-			}
+	h.answerInline(q, results)
+}
 
-			display := pages[0]
-			if len(pages) > 1 {
-				display = fmt.Sprintf("<b>Page 1/%d</b>\n\n%s", len(pages), display)
-			}
+func (h *Handler) handleInlineRandom(req *Request) {
+	q := req.Inline
+	var results []interface{}
 
-			article := tgbotapi.NewInlineQueryResultArticleHTML(q.ID, "🎲 Random Hadith", txt)
-			article.Description = fmt.Sprintf("Hadith #%d from %s", res.Hadith.HadithNumber, getCollectionTitle(res.Collection))
-			article.InputMessageContent = tgbotapi.InputTextMessageContent{
-				Text:      display,
-				ParseMode: tgbotapi.ModeHTML,
-			}
+	res := h.hadithService.GetRandomHadith(nil)
+	if res.Hadith != nil && res.Collection != nil {
+		txt := h.formatHadithDisplay(res.Hadith, res.Collection, res.Book)
+		pages := splitTelegramMessage(txt, telegramMessageMaxRunes)
+		if len(pages) == 0 {
+			pages = []string{txt}
+		}
 
-			var rows [][]tgbotapi.InlineKeyboardButton
-			if len(pages) > 1 {
-				rows = append(rows, tgbotapi.NewInlineKeyboardRow(
-					tgbotapi.NewInlineKeyboardButtonData("Next Part ➡️", fmt.Sprintf("hadith_page:r:%s:%d:%d", res.Collection.Name, res.Hadith.HadithNumber, 1)),
-				))
-			}
+		display := pages[0]
+		if len(pages) > 1 {
+			display = fmt.Sprintf("<b>Page 1/%d</b>\n\n%s", len(pages), display)
+		}
+
+		article := tgbotapi.NewInlineQueryResultArticleHTML(q.ID, "🎲 Random Hadith", txt)
+		article.Description = fmt.Sprintf("Hadith #%d from %s", res.Hadith.HadithNumber, getCollectionTitle(res.Collection))
+		article.InputMessageContent = tgbotapi.InputTextMessageContent{
+			Text:      display,
+			ParseMode: tgbotapi.ModeHTML,
+		}
+
+		var rows [][]tgbotapi.InlineKeyboardButton
+		if len(pages) > 1 {
 			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("🎲 Another Random", "random"),
+				tgbotapi.NewInlineKeyboardButtonData("Next Part ➡️", fmt.Sprintf("hadith_page:r:%s:%d:%d", res.Collection.Name, res.Hadith.HadithNumber, 1)),
 			))
-			kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
-			article.ReplyMarkup = &kb
-			results = append(results, article)
 		}
-	} else if strings.HasPrefix(query, "image-search ") {
-		keyword := strings.TrimSpace(strings.TrimPrefix(query, "image-search "))
-		if keyword != "" {
-			if h.imageCacheChannelID == 0 {
-				article := tgbotapi.NewInlineQueryResultArticle(q.ID+"_err", "⚠️ Image Search Unavailable", "The bot administrator has not configured an image cache channel. Image search is currently disabled.")
-				results = append(results, article)
-			} else {
-				searchRes := h.hadithService.SearchHadiths(keyword, 1, 1) // only top 1 result
-				if len(searchRes.Hadiths) > 0 {
-					hadith := searchRes.Hadiths[0]
-					colName := h.findCollectionForHadith(hadith)
-					book := h.hadithService.GetBook(colName, hadith.ChapterID)
-
-					title := "Hadith"
-					if book != nil {
-						title = book.Title
-						if idx := strings.Index(title, ". "); idx != -1 {
-							title = title[idx+2:]
-						}
-					}
-					ref := fmt.Sprintf("[%s: %d]", services.GetCollectionDisplayName(colName), hadith.HadithNumber)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🎲 Another Random", "random"),
+		))
+		kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
+		article.ReplyMarkup = &kb
+		results = append(results, article)
+	}
 
-					useCustomBg := false
-					useClassicArabic := false
-					if chatState := h.state.GetChatState(int64(q.From.ID)); chatState != nil {
-						useCustomBg = chatState.UseCustomBg
-						useClassicArabic = chatState.UseClassicArabic
-					}
+	h.answerInline(q, results)
+}
 
-					imgBytes, err := h.imageGenerator.GenerateHadithImage(title, hadith.Narrator, hadith.Arabic, hadith.English, ref, useCustomBg, useClassicArabic)
-					if err == nil {
-						photoMsg := tgbotapi.NewPhoto(h.imageCacheChannelID, tgbotapi.FileBytes{
-							Name:  "hadith.png",
-							Bytes: imgBytes,
-						})
-
-						sentMsg, err := h.bot.Send(photoMsg)
-						if err == nil && len(sentMsg.Photo) > 0 {
-							// get the largest photo
-							largestPhoto := sentMsg.Photo[len(sentMsg.Photo)-1]
-							fileID := largestPhoto.FileID
-
-							photoResult := tgbotapi.NewInlineQueryResultCachedPhoto(q.ID+"_img", fileID)
-							results = append(results, photoResult)
-						} else {
-							h.log.Error("Failed to send image to cache channel: %v", err)
-						}
-					} else {
-						h.log.Error("Failed to generate image for inline query: %v", err)
-					}
-				}
+// handleInlineImageSearch renders the top result of "image-search <keyword>"
+// as an image, uploaded to the image cache channel so it can be sent inline
+func (h *Handler) handleInlineImageSearch(req *Request) {
+	q, keyword := req.Inline, req.Args
+	var results []interface{}
 
-				if len(results) == 0 {
-					article := tgbotapi.NewInlineQueryResultArticle(q.ID+"_nores", "No results found", "Could not find any hadiths matching your query or an error occurred.")
-					results = append(results, article)
-				}
-			}
-		}
-	} else if strings.HasPrefix(query, "search ") {
-		keyword := strings.TrimSpace(strings.TrimPrefix(query, "search "))
-		if keyword != "" {
-			searchRes := h.hadithService.SearchHadiths(keyword, 1, 5)
-			for i, hadith := range searchRes.Hadiths {
+	if keyword != "" {
+		if h.imageCacheChannelID == 0 {
+			article := tgbotapi.NewInlineQueryResultArticle(q.ID+"_err", "⚠️ Image Search Unavailable", "The bot administrator has not configured an image cache channel. Image search is currently disabled.")
+			results = append(results, article)
+		} else {
+			searchRes, err := h.hadithService.SearchHadiths(keyword, 1, 1) // only top 1 result
+			if err == nil && len(searchRes.Hadiths) > 0 {
+				hadith := searchRes.Hadiths[0]
 				colName := h.findCollectionForHadith(hadith)
-				col := h.hadithService.GetCollection(colName)
-				txt := h.formatHadithDisplay(&hadith, col, nil)
-				pages := splitTelegramMessage(txt, telegramMessageMaxRunes)
-				if len(pages) == 0 {
-					pages = []string{txt}
-				}
+				book := h.hadithService.GetBook(colName, hadith.ChapterID)
 
-				display := pages[0]
-				if len(pages) > 1 {
-					display = fmt.Sprintf("<b>Page 1/%d</b>\n\n%s", len(pages), display)
+				title := "Hadith"
+				if book != nil {
+					title = book.Title
+					if idx := strings.Index(title, ". "); idx != -1 {
+						title = title[idx+2:]
+					}
 				}
+				ref := fmt.Sprintf("[%s: %d]", h.hadithService.GetCollectionDisplayName(colName), hadith.HadithNumber)
 
-				id := fmt.Sprintf("inline_%d_%d", hadith.HadithNumber, i)
-				article := tgbotapi.NewInlineQueryResultArticleHTML(id, fmt.Sprintf("🵿 Hadith #%d", hadith.HadithNumber), txt)
-				article.Description = truncate(hadith.English, 50)
-				article.InputMessageContent = tgbotapi.InputTextMessageContent{
-					Text:      display,
-					ParseMode: tgbotapi.ModeHTML,
+				useCustomBg := false
+				useClassicArabic := false
+				if chatState := h.state.GetChatState(int64(q.From.ID)); chatState != nil {
+					useCustomBg = chatState.UseCustomBg
+					useClassicArabic = chatState.UseClassicArabic
 				}
 
-				var rows [][]tgbotapi.InlineKeyboardButton
-				if len(pages) > 1 {
-					rows = append(rows, tgbotapi.NewInlineKeyboardRow(
-						tgbotapi.NewInlineKeyboardButtonData("Next Part ➡️", fmt.Sprintf("hadith_page:s:%s:%d:%d", colName, hadith.HadithNumber, 1)),
-					))
+				imgBytes, err := h.imageGenerator.GenerateHadithImage(title, hadith.Narrator, hadith.Arabic, hadith.English, ref, useCustomBg, useClassicArabic)
+				if err == nil {
+					photoMsg := tgbotapi.NewPhoto(h.imageCacheChannelID, tgbotapi.FileBytes{
+						Name:  "hadith.png",
+						Bytes: imgBytes,
+					})
+
+					sentMsg, err := h.bot.Send(photoMsg)
+					if err == nil && len(sentMsg.Photo) > 0 {
+						// get the largest photo
+						largestPhoto := sentMsg.Photo[len(sentMsg.Photo)-1]
+						fileID := largestPhoto.FileID
+
+						photoResult := tgbotapi.NewInlineQueryResultCachedPhoto(q.ID+"_img", fileID)
+						results = append(results, photoResult)
+					} else {
+						h.log.Error("Failed to send image to cache channel: %v", err)
+					}
+				} else {
+					h.log.Error("Failed to generate image for inline query: %v", err)
 				}
-				rows = append(rows, tgbotapi.NewInlineKeyboardRow(
-					tgbotapi.NewInlineKeyboardButtonData("Open in Bot", fmt.Sprintf("hadith_search:%s:%d", colName, hadith.HadithNumber)),
-				))
-				kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
-				article.ReplyMarkup = &kb
+			}
+
+			if len(results) == 0 {
+				article := tgbotapi.NewInlineQueryResultArticle(q.ID+"_nores", "No results found", "Could not find any hadiths matching your query or an error occurred.")
 				results = append(results, article)
 			}
 		}
 	}
 
-	h.bot.Request(tgbotapi.InlineConfig{
-		InlineQueryID: q.ID,
-		Results:       results,
-		CacheTime:     10,
-	})
+	h.answerInline(q, results)
+}
+
+func (h *Handler) handleInlineSearch(req *Request) {
+	q, keyword := req.Inline, req.Args
+	var results []interface{}
+
+	if keyword != "" {
+		searchRes, err := h.hadithService.SearchHadiths(keyword, 1, 5)
+		if err != nil {
+			article := tgbotapi.NewInlineQueryResultArticle(q.ID+"_err", "⚠️ Invalid search", err.Error())
+			article.Description = err.Error()
+			results = append(results, article)
+		}
+		if searchRes.Suggestion != "" {
+			article := tgbotapi.NewInlineQueryResultArticleHTML(q.ID+"_suggest", "Did you mean: "+searchRes.Suggestion+"?", "No results found. Did you mean <b>"+html.EscapeString(searchRes.Suggestion)+"</b>?")
+			article.Description = "No results found. Tap the button to search for this instead."
+			switchQuery := "search " + searchRes.Suggestion
+			kb := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(tgbotapi.InlineKeyboardButton{
+				Text:                         "🔎 Search " + searchRes.Suggestion,
+				SwitchInlineQueryCurrentChat: &switchQuery,
+			}))
+			article.ReplyMarkup = &kb
+			results = append(results, article)
+		}
+		for i, hadith := range searchRes.Hadiths {
+			id := fmt.Sprintf("inline_%d_%d", hadith.HadithNumber, i)
+			results = append(results, h.inlineHadithArticle(id, hadith, plainText(hadith.Snippet), ""))
+		}
+	}
+
+	h.answerInline(q, results)
+}
+
+func (h *Handler) handleInlineToday(req *Request) {
+	q := req.Inline
+	var results []interface{}
+
+	if daily, ok := h.hadithService.HadithOfTheDay(time.Now()); ok {
+		hadith := *daily.Hadith
+		hadith.CollectionName = daily.Collection.Name
+		article := h.inlineHadithArticle(q.ID+"_today", hadith, fmt.Sprintf("%s — Hadith #%d from %s", todayDate(daily), hadith.HadithNumber, getCollectionTitle(daily.Collection)), todayHeading(daily))
+		article.Title = "📅 Hadith of the Day"
+		results = append(results, article)
+	}
+
+	h.answerInline(q, results)
+}
+
+func (h *Handler) handleInlineRef(req *Request) {
+	q, text := req.Inline, req.Args
+	var results []interface{}
+
+	if ref, ok := h.hadithService.ParseReference(text); ok {
+		for i, hadith := range h.hadithService.LookupReference(ref) {
+			id := fmt.Sprintf("ref_%s_%d_%d", hadith.CollectionName, hadith.HadithNumber, i)
+			results = append(results, h.inlineHadithArticle(id, hadith, plainText(hadith.Snippet), ""))
+		}
+	}
+	if len(results) == 0 && text != "" {
+		article := tgbotapi.NewInlineQueryResultArticle(q.ID+"_nores", "No hadith found", "Could not find a hadith for that reference. Try e.g. \"bukhari 1\" or \"muslim 2564\".")
+		article.Description = "Try e.g. bukhari 1, muslim 2564 or nasai 100-105"
+		results = append(results, article)
+	}
+
+	h.answerInline(q, results)
+}
+
+// inlineHadithArticle builds an inline result that posts the hadith's first
+// page, under heading if one is given, with buttons to read on and to open
+// it in the bot
+func (h *Handler) inlineHadithArticle(id string, hadith models.Hadith, description, heading string) tgbotapi.InlineQueryResultArticle {
+	colName := h.findCollectionForHadith(hadith)
+	col := h.hadithService.GetCollection(colName)
+	txt := h.formatHadithDisplay(&hadith, col, nil)
+	pages := splitTelegramMessage(txt, telegramMessageMaxRunes)
+	if len(pages) == 0 {
+		pages = []string{txt}
+	}
+
+	display := pages[0]
+	if len(pages) > 1 {
+		display = fmt.Sprintf("<b>Page 1/%d</b>\n\n%s", len(pages), display)
+	}
+	if heading != "" {
+		display = heading + "\n\n" + display
+	}
+
+	article := tgbotapi.NewInlineQueryResultArticleHTML(id, fmt.Sprintf("🵿 Hadith #%d", hadith.HadithNumber), txt)
+	article.Description = description
+	article.InputMessageContent = tgbotapi.InputTextMessageContent{
+		Text:      display,
+		ParseMode: tgbotapi.ModeHTML,
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if len(pages) > 1 {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Next Part ➡️", fmt.Sprintf("hadith_page:s:%s:%d:%d", colName, hadith.HadithNumber, 1)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Open in Bot", fmt.Sprintf("hadith_search:%s:%d", colName, hadith.HadithNumber)),
+	))
+	kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	article.ReplyMarkup = &kb
+	return article
 }
 
 // --- NAVIGATION MENUS ---
@@ -691,85 +1193,182 @@ func (h *Handler) sendBooksMenu(chatID int64, msgID int, inlineMsgID string, col
 	}
 
 	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⬅️ Back to Collections", "collections:1")))
-	h.editOrSendMessage(chatID, msgID, inlineMsgID, fmt.Sprintf("📚 <b>%s — Books</b>", html.EscapeString(services.GetCollectionDisplayName(col))), tgbotapi.NewInlineKeyboardMarkup(rows...))
+	h.editOrSendMessage(chatID, msgID, inlineMsgID, fmt.Sprintf("📚 <b>%s — Books</b>", html.EscapeString(h.hadithService.GetCollectionDisplayName(col))), tgbotapi.NewInlineKeyboardMarkup(rows...))
 }
 
-func (h *Handler) sendHadithsMenu(chatID int64, msgID int, inlineMsgID string, col string, bookNum int, result models.HadithResponse) {
+// narratorsPerPage is the page size of the narrator list and of a narrator's
+// hadiths
+const narratorsPerPage = 10
+
+func (h *Handler) sendNarratorsMenu(chatID int64, msgID int, inlineMsgID string, page int) {
+	narrators := h.hadithService.GetNarrators()
+	if len(narrators) == 0 {
+		h.editOrSendMessage(chatID, msgID, inlineMsgID, "👤 No narrators were found in the loaded collections.", tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("📚 Collections", "collections:1")),
+		))
+		return
+	}
+
+	totalPages := (len(narrators) + narratorsPerPage - 1) / narratorsPerPage
+	if page < 1 {
+		page = 1
+	}
+	if page > totalPages {
+		page = totalPages
+	}
+	start, end := (page-1)*narratorsPerPage, page*narratorsPerPage
+	if end > len(narrators) {
+		end = len(narrators)
+	}
+
 	var rows [][]tgbotapi.InlineKeyboardButton
-	for i, hadith := range result.Hadiths {
-		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("📜 Hadith #%d", hadith.HadithNumber), fmt.Sprintf("hadith_detail:%s:%d:%d:%d", col, bookNum, result.Page, i))))
+	for _, n := range narrators[start:end] {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%s (%d)", truncate(n.Name, 35), n.Count), fmt.Sprintf("narrator:%d:1", n.ID))))
+	}
+
+	var nav []tgbotapi.InlineKeyboardButton
+	if page > 1 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("⬅️ Page", fmt.Sprintf("narrators:%d", page-1)))
+	}
+	if end < len(narrators) {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("Page ➡️", fmt.Sprintf("narrators:%d", page+1)))
+	}
+	if len(nav) > 0 {
+		rows = append(rows, nav)
+	}
+
+	h.editOrSendMessage(chatID, msgID, inlineMsgID, fmt.Sprintf("👤 <b>Narrators</b> — Page %d/%d\nNarrators with the most hadiths come first.", page, totalPages), tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+func (h *Handler) sendNarratorHadiths(chatID int64, msgID int, inlineMsgID string, id, page int) {
+	narrator, result, ok := h.hadithService.GetNarratorHadiths(id, page, narratorsPerPage)
+	if !ok {
+		h.sendNarratorsMenu(chatID, msgID, inlineMsgID, 1)
+		return
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, hadith := range result.Hadiths {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("📜 Hadith #%d (%s)", hadith.HadithNumber, h.hadithService.GetCollectionDisplayName(hadith.CollectionName)), fmt.Sprintf("hadith_search:%s:%d", hadith.CollectionName, hadith.HadithNumber))))
 	}
 
 	var nav []tgbotapi.InlineKeyboardButton
 	if result.Page > 1 {
-		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("⬅️ Prev", fmt.Sprintf("hadiths:%s:%d:%d", col, bookNum, result.Page-1)))
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("⬅️ Prev", fmt.Sprintf("narrator:%d:%d", id, result.Page-1)))
 	}
 	if result.Page < result.TotalPages {
-		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("Next ➡️", fmt.Sprintf("hadiths:%s:%d:%d", col, bookNum, result.Page+1)))
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("Next ➡️", fmt.Sprintf("narrator:%d:%d", id, result.Page+1)))
 	}
 	if len(nav) > 0 {
 		rows = append(rows, nav)
 	}
 
-	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⬅️ Back to Books", fmt.Sprintf("books:%s:1", col))))
-	h.editOrSendMessage(chatID, msgID, inlineMsgID, fmt.Sprintf("📑 <b>Hadith List — Page %d/%d</b>", result.Page, result.TotalPages), tgbotapi.NewInlineKeyboardMarkup(rows...))
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⬅️ Back to Narrators", fmt.Sprintf("narrators:%d", id/narratorsPerPage+1))))
+	h.editOrSendMessage(chatID, msgID, inlineMsgID, fmt.Sprintf("👤 <b>%s</b> — %d hadiths, page %d/%d", html.EscapeString(narrator.Name), narrator.Count, result.Page, result.TotalPages), tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+// topicsPerPage is the page size of the topic list and of a topic's hadiths
+const topicsPerPage = 10
+
+func (h *Handler) sendTopicsMenu(chatID int64, msgID int, inlineMsgID string, page int) {
+	topics := h.hadithService.GetTopics()
+	if len(topics) == 0 {
+		h.editOrSendMessage(chatID, msgID, inlineMsgID, "🏷️ No hadiths are tagged with topics in the loaded collections.", tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("📚 Collections", "collections:1")),
+		))
+		return
+	}
+
+	totalPages := (len(topics) + topicsPerPage - 1) / topicsPerPage
+	if page < 1 {
+		page = 1
+	}
+	if page > totalPages {
+		page = totalPages
+	}
+	start, end := (page-1)*topicsPerPage, page*topicsPerPage
+	if end > len(topics) {
+		end = len(topics)
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, t := range topics[start:end] {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%s (%d)", truncate(t.Name, 35), t.Count), fmt.Sprintf("topic:%s:1", t.ID))))
+	}
+
+	var nav []tgbotapi.InlineKeyboardButton
+	if page > 1 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("⬅️ Page", fmt.Sprintf("topics:%d", page-1)))
+	}
+	if end < len(topics) {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("Page ➡️", fmt.Sprintf("topics:%d", page+1)))
+	}
+	if len(nav) > 0 {
+		rows = append(rows, nav)
+	}
+
+	h.editOrSendMessage(chatID, msgID, inlineMsgID, fmt.Sprintf("🏷️ <b>Topics</b> — Page %d/%d", page, totalPages), tgbotapi.NewInlineKeyboardMarkup(rows...))
 }
 
-func (h *Handler) handleHadithDetailCallback(c *tgbotapi.CallbackQuery, parts []string) {
-	col, bookNum, page, index := parts[1], 0, 0, 0
-	fmt.Sscanf(parts[2], "%d", &bookNum)
-	fmt.Sscanf(parts[3], "%d", &page)
-	fmt.Sscanf(parts[4], "%d", &index)
+func (h *Handler) sendTopicHadiths(chatID int64, msgID int, inlineMsgID string, id string, page int) {
+	topic, result, ok := h.hadithService.GetTopicHadiths(id, page, topicsPerPage)
+	if !ok {
+		h.sendTopicsMenu(chatID, msgID, inlineMsgID, 1)
+		return
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, hadith := range result.Hadiths {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("📜 Hadith #%d (%s)", hadith.HadithNumber, h.hadithService.GetCollectionDisplayName(hadith.CollectionName)), fmt.Sprintf("hadith_search:%s:%d", hadith.CollectionName, hadith.HadithNumber))))
+	}
 
-	chatID := int64(0)
-	msgID := 0
-	if c.Message != nil {
-		chatID = c.Message.Chat.ID
-		msgID = c.Message.MessageID
+	var nav []tgbotapi.InlineKeyboardButton
+	if result.Page > 1 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("⬅️ Prev", fmt.Sprintf("topic:%s:%d", id, result.Page-1)))
+	}
+	if result.Page < result.TotalPages {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("Next ➡️", fmt.Sprintf("topic:%s:%d", id, result.Page+1)))
+	}
+	if len(nav) > 0 {
+		rows = append(rows, nav)
 	}
-	h.sendHadithDetailPaged(chatID, msgID, c.InlineMessageID, col, bookNum, page, index, 0)
+
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🎲 Random from this topic", "topic_random:"+id)),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⬅️ Back to Topics", "topics:1")),
+	)
+	h.editOrSendMessage(chatID, msgID, inlineMsgID, fmt.Sprintf("🏷️ <b>%s</b> — %d hadiths, page %d/%d", html.EscapeString(topic.Name), topic.Count, result.Page, result.TotalPages), tgbotapi.NewInlineKeyboardMarkup(rows...))
 }
 
-func (h *Handler) handleHadithPageCallback(c *tgbotapi.CallbackQuery, parts []string) {
-	if len(parts) < 3 {
+// sendRandomInTopic sends a random hadith tagged with a topic
+func (h *Handler) sendRandomInTopic(chatID int64, msgID int, inlineMsgID string, id string) {
+	res, ok := h.pickRandomHadith(chatID, models.SearchFilter{Topics: []string{id}})
+	if !ok {
+		h.sendMessage(chatID, "⚠️ No hadiths are tagged with that topic.")
 		return
 	}
+	h.sendRandomHadithPaged(chatID, msgID, inlineMsgID, res.Collection.Name, res.Hadith.HadithNumber, 0)
+}
 
-	chatID := int64(0)
-	msgID := 0
-	if c.Message != nil {
-		chatID = c.Message.Chat.ID
-		msgID = c.Message.MessageID
+func (h *Handler) sendHadithsMenu(chatID int64, msgID int, inlineMsgID string, col string, bookNum int, result models.HadithResponse) {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, hadith := range result.Hadiths {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("📜 Hadith #%d", hadith.HadithNumber), fmt.Sprintf("hadith_detail:%s:%d:%d:%d", col, bookNum, result.Page, i))))
 	}
 
-	switch parts[1] {
-	case "d":
-		if len(parts) < 7 {
-			return
-		}
-		col := parts[2]
-		bookNum, _ := strconv.Atoi(parts[3])
-		listPage, _ := strconv.Atoi(parts[4])
-		index, _ := strconv.Atoi(parts[5])
-		textPage, _ := strconv.Atoi(parts[6])
-		h.sendHadithDetailPaged(chatID, msgID, c.InlineMessageID, col, bookNum, listPage, index, textPage)
-	case "s":
-		if len(parts) < 5 {
-			return
-		}
-		col := parts[2]
-		hadithNum, _ := strconv.Atoi(parts[3])
-		textPage, _ := strconv.Atoi(parts[4])
-		h.sendSearchHadithPaged(chatID, msgID, c.InlineMessageID, col, hadithNum, textPage)
-	case "r":
-		if len(parts) < 5 {
-			return
-		}
-		col := parts[2]
-		hadithNum, _ := strconv.Atoi(parts[3])
-		textPage, _ := strconv.Atoi(parts[4])
-		h.sendRandomHadithPaged(chatID, msgID, c.InlineMessageID, col, hadithNum, textPage)
+	var nav []tgbotapi.InlineKeyboardButton
+	if result.Page > 1 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("⬅️ Prev", fmt.Sprintf("hadiths:%s:%d:%d", col, bookNum, result.Page-1)))
+	}
+	if result.Page < result.TotalPages {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("Next ➡️", fmt.Sprintf("hadiths:%s:%d:%d", col, bookNum, result.Page+1)))
+	}
+	if len(nav) > 0 {
+		rows = append(rows, nav)
 	}
+
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⬅️ Back to Books", fmt.Sprintf("books:%s:1", col))))
+	h.editOrSendMessage(chatID, msgID, inlineMsgID, fmt.Sprintf("📑 <b>Hadith List — Page %d/%d</b>", result.Page, result.TotalPages), tgbotapi.NewInlineKeyboardMarkup(rows...))
 }
 
 func (h *Handler) sendHadithDetailPaged(chatID int64, msgID int, inlineMsgID, col string, bookNum, page, index, textPage int) {
@@ -809,30 +1408,26 @@ func (h *Handler) sendHadithDetailPaged(chatID int64, msgID int, inlineMsgID, co
 			}
 		}
 
-		shareURL := fmt.Sprintf("https://t.me/%s?start=hadith_%s_%d", h.bot.Self.UserName, col, hadith.HadithNumber)
+		shareURL := fmt.Sprintf("https://t.me/%s?start=hadith_%s_%d", h.botUserName, col, hadith.HadithNumber)
 		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("⬅️ Back", fmt.Sprintf("hadiths:%s:%d:%d", col, bookNum, page)),
 			tgbotapi.NewInlineKeyboardButtonData("🎨 Image", fmt.Sprintf("hadith_image:%s:%d", col, hadith.HadithNumber)),
 			tgbotapi.NewInlineKeyboardButtonURL("📤 Share", shareURL),
 		))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔗 Similar", fmt.Sprintf("similar:%s:%d", col, hadith.HadithNumber)),
+		))
 		h.editOrSendMessage(chatID, msgID, inlineMsgID, display, tgbotapi.NewInlineKeyboardMarkup(rows...))
 	}
 }
 
-func (h *Handler) handleHadithSearchCallback(c *tgbotapi.CallbackQuery, parts []string) {
-	colName := parts[1]
-	hadithNum, _ := strconv.Atoi(parts[2])
-
-	chatID := int64(0)
-	msgID := 0
-	if c.Message != nil {
-		chatID = c.Message.Chat.ID
-		msgID = c.Message.MessageID
-	}
-	h.sendSearchHadithPaged(chatID, msgID, c.InlineMessageID, colName, hadithNum, 0)
+func (h *Handler) sendSearchHadithPaged(chatID int64, msgID int, inlineMsgID, colName string, hadithNum, textPage int) {
+	h.sendHadithView(chatID, msgID, inlineMsgID, colName, hadithNum, textPage, "")
 }
 
-func (h *Handler) sendSearchHadithPaged(chatID int64, msgID int, inlineMsgID, colName string, hadithNum, textPage int) {
+// sendHadithView shows a hadith with its Image, Similar and Share buttons.
+// A heading, if given, is shown above the first part.
+func (h *Handler) sendHadithView(chatID int64, msgID int, inlineMsgID, colName string, hadithNum, textPage int, heading string) {
 	hadith, book := h.hadithService.FindHadithByNumber(colName, hadithNum)
 	if hadith == nil {
 		var rows [][]tgbotapi.InlineKeyboardButton
@@ -863,6 +1458,9 @@ func (h *Handler) sendSearchHadithPaged(chatID int64, msgID int, inlineMsgID, co
 	if len(pages) > 1 {
 		display = fmt.Sprintf("<b>Page %d/%d</b>\n\n%s", textPage+1, len(pages), display)
 	}
+	if heading != "" && textPage == 0 {
+		display = heading + "\n\n" + display
+	}
 
 	var rows [][]tgbotapi.InlineKeyboardButton
 	if len(pages) > 1 {
@@ -878,9 +1476,10 @@ func (h *Handler) sendSearchHadithPaged(chatID int64, msgID int, inlineMsgID, co
 		}
 	}
 
-	shareURL := fmt.Sprintf("https://t.me/%s?start=hadith_%s_%d", h.bot.Self.UserName, colName, hadithNum)
+	shareURL := fmt.Sprintf("https://t.me/%s?start=hadith_%s_%d", h.botUserName, colName, hadithNum)
 	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
 		tgbotapi.NewInlineKeyboardButtonData("🎨 Image", fmt.Sprintf("hadith_image:%s:%d", colName, hadithNum)),
+		tgbotapi.NewInlineKeyboardButtonData("🔗 Similar", fmt.Sprintf("similar:%s:%d", colName, hadithNum)),
 		tgbotapi.NewInlineKeyboardButtonURL("📤 Share", shareURL),
 	))
 
@@ -894,17 +1493,72 @@ func (h *Handler) sendSearchHadithPaged(chatID int64, msgID int, inlineMsgID, co
 	h.editOrSendMessage(chatID, msgID, inlineMsgID, display, tgbotapi.NewInlineKeyboardMarkup(rows...))
 }
 
-func (h *Handler) handleRandomCallback(c *tgbotapi.CallbackQuery) {
-	res := h.hadithService.GetRandomHadith()
-	if res.Hadith != nil && res.Collection != nil {
-		chatID := int64(0)
-		msgID := 0
-		if c.Message != nil {
-			chatID = c.Message.Chat.ID
-			msgID = c.Message.MessageID
+// sendSimilarHadiths lists the hadiths whose text is closest to the given
+// one, often the same report in other collections
+func (h *Handler) sendSimilarHadiths(chatID int64, msgID int, inlineMsgID, colName string, hadithNum int) {
+	back := tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⬅️ Back", fmt.Sprintf("hadith_search:%s:%d", colName, hadithNum)))
+	similar := h.hadithService.SimilarHadiths(colName, hadithNum, similarHadithsLimit)
+	if len(similar) == 0 {
+		h.editOrSendMessage(chatID, msgID, inlineMsgID, "🔗 No similar hadiths were found in the loaded collections.", tgbotapi.NewInlineKeyboardMarkup(back))
+		return
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "🔗 <b>Similar to %s #%d</b>", html.EscapeString(h.hadithService.GetCollectionDisplayName(colName)), hadithNum)
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, hd := range similar {
+		title := h.hadithService.GetCollectionDisplayName(hd.CollectionName)
+		fmt.Fprintf(&text, "\n\n<b>%d. %s #%d</b> (%d%% similar)\n%s", i+1, html.EscapeString(title), hd.HadithNumber, int(hd.Score*100+0.5), hd.Snippet)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d. 📜 Hadith #%d (%s)", i+1, hd.HadithNumber, title), fmt.Sprintf("hadith_search:%s:%d", hd.CollectionName, hd.HadithNumber))))
+	}
+	rows = append(rows, back)
+
+	h.editOrSendMessage(chatID, msgID, inlineMsgID, text.String(), tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+func (h *Handler) handleRandomCallback(cb *callback) {
+	if res, ok := h.pickRandomHadith(cb.chatID, models.SearchFilter{}); ok {
+		h.sendRandomHadithPaged(cb.chatID, cb.msgID, cb.inlineMsgID, res.Collection.Name, res.Hadith.HadithNumber, 0)
+	}
+}
+
+// pickRandomHadith returns a random hadith for a chat, from those the filter
+// allows, that is not among the chat's recently sent hadiths, and records it
+// as sent. Once the whole pool has been sent the rotation starts over. Chat
+// 0 (inline messages) has no history.
+func (h *Handler) pickRandomHadith(chatID int64, filter models.SearchFilter) (models.RandomHadithResult, bool) {
+	pick := func(exclude func(collection string, hadithNumber int) bool) (models.RandomHadithResult, bool) {
+		return h.hadithService.GetRandomHadithMatching(filter, exclude)
+	}
+	if chatID == 0 || h.randomHistorySize <= 0 {
+		return pick(nil)
+	}
+
+	recent := make(map[string]bool)
+	for _, ref := range h.state.RecentHadiths(chatID) {
+		recent[ref] = true
+	}
+	res, ok := pick(func(collection string, hadithNumber int) bool {
+		return recent[recentHadithRef(collection, hadithNumber)]
+	})
+	if !ok && len(recent) > 0 {
+		// Everything in the pool has been sent: start a new rotation
+		if err := h.state.ClearRecentHadiths(chatID); err != nil {
+			h.log.Error("Failed to save state for %d: %v", chatID, err)
+		}
+		res, ok = pick(nil)
+	}
+	if ok {
+		if err := h.state.AddRecentHadith(chatID, recentHadithRef(res.Collection.Name, res.Hadith.HadithNumber), h.randomHistorySize); err != nil {
+			h.log.Error("Failed to save state for %d: %v", chatID, err)
 		}
-		h.sendRandomHadithPaged(chatID, msgID, c.InlineMessageID, res.Collection.Name, res.Hadith.HadithNumber, 0)
 	}
+	return res, ok
+}
+
+func recentHadithRef(collection string, hadithNumber int) string {
+	return fmt.Sprintf("%s:%d", collection, hadithNumber)
 }
 
 func (h *Handler) sendRandomHadithPaged(chatID int64, msgID int, inlineMsgID, colName string, hadithNum, textPage int) {
@@ -946,7 +1600,7 @@ func (h *Handler) sendRandomHadithPaged(chatID int64, msgID int, inlineMsgID, co
 		}
 	}
 
-	shareURL := fmt.Sprintf("https://t.me/%s?start=hadith_%s_%d", h.bot.Self.UserName, colName, hadithNum)
+	shareURL := fmt.Sprintf("https://t.me/%s?start=hadith_%s_%d", h.botUserName, colName, hadithNum)
 	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
 		tgbotapi.NewInlineKeyboardButtonData("🎲 Another Random", "random"),
 		tgbotapi.NewInlineKeyboardButtonData("🎨 Image", fmt.Sprintf("hadith_image:%s:%d", colName, hadithNum)),
@@ -955,25 +1609,21 @@ func (h *Handler) sendRandomHadithPaged(chatID int64, msgID int, inlineMsgID, co
 	h.editOrSendMessage(chatID, msgID, inlineMsgID, display, tgbotapi.NewInlineKeyboardMarkup(rows...))
 }
 
-func (h *Handler) handleHadithImageCallback(c *tgbotapi.CallbackQuery, parts []string) {
-	// parts: hadith_image:collection:hadithNum
-	if len(parts) < 3 {
-		return
-	}
-	col := parts[1]
-	hadithNum, _ := strconv.Atoi(parts[2])
+// handleHadithImageCallback sends a hadith as an image:
+// hadith_image:<collection>:<number>
+func (h *Handler) handleHadithImageCallback(cb *callback) {
+	col, hadithNum := cb.String(0), cb.Int(1)
+	c := cb.query
 
 	// Determine where to send the image
-	var chatID int64
-	if c.Message != nil {
-		chatID = c.Message.Chat.ID
-	} else {
+	chatID := cb.chatID
+	if c.Message == nil {
 		// Inline button: try sending to user's private chat
 		chatID = c.From.ID
 	}
 
 	// Answer callback to show loading state (toast or just stop loading)
-	h.bot.Request(tgbotapi.NewCallback(c.ID, "🎨 Generating image..."))
+	cb.answer("🎨 Generating image...")
 
 	// Fetch hadith
 	hadith, _ := h.hadithService.FindHadithByNumber(col, hadithNum)
@@ -995,7 +1645,7 @@ func (h *Handler) handleHadithImageCallback(c *tgbotapi.CallbackQuery, parts []s
 		}
 	}
 
-	ref := fmt.Sprintf("[%s: %d]", services.GetCollectionDisplayName(col), hadith.HadithNumber)
+	ref := fmt.Sprintf("[%s: %d]", h.hadithService.GetCollectionDisplayName(col), hadith.HadithNumber)
 
 	useCustomBg := false
 	useClassicArabic := false
@@ -1016,7 +1666,7 @@ func (h *Handler) handleHadithImageCallback(c *tgbotapi.CallbackQuery, parts []s
 		Name:  "hadith.png",
 		Bytes: imgBytes,
 	})
-	photo.Caption = fmt.Sprintf("Hadith #%d from %s", hadith.HadithNumber, services.GetCollectionDisplayName(col))
+	photo.Caption = fmt.Sprintf("Hadith #%d from %s", hadith.HadithNumber, h.hadithService.GetCollectionDisplayName(col))
 	h.bot.Send(photo)
 }
 
@@ -1059,25 +1709,141 @@ func (h *Handler) sendMessageWithKeyboard(chatID int64, text string, kb tgbotapi
 	h.bot.Send(msg)
 }
 
-func (h *Handler) sendSearchResults(chatID int64, msgID int, inlineMsgID string, query string, res models.SearchResult) {
+// sendNoSearchResults tells the user nothing matched and, when the search
+// found a spelling correction, offers a button that switches session id to it
+func (h *Handler) sendNoSearchResults(chatID int64, msgID int, inlineMsgID string, id string, res models.SearchResult) {
+	text := "No results found for your search. Try a different keyword."
+	kb := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+
+	if res.Suggestion != "" && id != "" {
+		text = fmt.Sprintf("No results found for your search.\n\nDid you mean: <b>%s</b>?", html.EscapeString(res.Suggestion))
+		kb = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🔎 Did you mean: %s?", res.Suggestion), "sf:"+id+":"+choiceSuggestion+":0"),
+		))
+	}
+	h.editOrSendMessage(chatID, msgID, inlineMsgID, text, kb)
+}
+
+// showSearchSession shows a page of a search session's results, or the page
+// last shown when page is 0
+func (h *Handler) showSearchSession(cb *callback, id string, page int) {
+	session, ok := h.sessions.Get(id)
+	if !ok || session.Origin != cb.chatID {
+		cb.alert("⌛ These results have expired. Search again with /search.")
+		return
+	}
+	if page < 1 {
+		page = session.Page
+	}
+
+	res, err := h.hadithService.SearchHadithsFiltered(session.Query, session.Filter, page, searchResultsPerPage)
+	if err != nil {
+		h.sendMessage(cb.chatID, searchErrorText(err))
+		return
+	}
+	session.offer(res)
+	if len(res.Hadiths) == 0 {
+		h.sessions.Update(id, func(s *SearchSession) { s.offer(res) })
+		h.sendNoSearchResults(cb.chatID, cb.msgID, cb.inlineMsgID, id, res)
+		return
+	}
+	session.Page = res.Page
+	h.sessions.Update(id, func(s *SearchSession) {
+		s.offer(res)
+		s.Page = res.Page
+	})
+	h.sendSearchResults(cb.chatID, cb.msgID, cb.inlineMsgID, id, session, res)
+}
+
+// chooseSearchSession narrows a search session to one of the choices its
+// buttons last offered, the n-th collection or grade facet or the spelling
+// suggestion, and shows its first page
+func (h *Handler) chooseSearchSession(cb *callback, id string, choice string, n int) {
+	session, ok := h.sessions.Get(id)
+	if !ok || session.Origin != cb.chatID {
+		cb.alert("⌛ These results have expired. Search again with /search.")
+		return
+	}
+
+	var narrow func(s *SearchSession)
+	switch {
+	case choice == choiceCollection && n >= 0 && n < len(session.Collections):
+		narrow = func(s *SearchSession) { s.Filter.Collections = []string{session.Collections[n]} }
+	case choice == choiceGrade && n >= 0 && n < len(session.Grades):
+		narrow = func(s *SearchSession) { s.Filter.Grades = []string{session.Grades[n]} }
+	case choice == choiceSuggestion && session.Suggestion != "":
+		narrow = func(s *SearchSession) { s.Query = session.Suggestion }
+	}
+	if narrow == nil {
+		cb.alert("⌛ This button has expired. Search again with /search.")
+		return
+	}
+	h.sessions.Update(id, narrow)
+	h.showSearchSession(cb, id, 1)
+}
+
+// sendSearchResults shows a page of a search session's results, with
+// buttons that page through the session
+func (h *Handler) sendSearchResults(chatID int64, msgID int, inlineMsgID string, id string, session SearchSession, res models.SearchResult) {
+	query := session.Query
+	if len(session.Filter.Collections) > 0 || len(session.Filter.Grades) > 0 {
+		query = h.hadithService.NarrowQuery(query, session.Filter)
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "🔍 <b>Results for:</b> %s", html.EscapeString(query))
+	if res.TotalPages > 1 {
+		fmt.Fprintf(&text, " (%d found, page %d/%d)", res.Total, res.Page, res.TotalPages)
+	}
+
 	var rows [][]tgbotapi.InlineKeyboardButton
-	for _, hd := range res.Hadiths {
+	for i, hd := range res.Hadiths {
 		col := h.findCollectionForHadith(hd)
-		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("📜 Hadith #%d (%s)", hd.HadithNumber, services.GetCollectionDisplayName(col)), fmt.Sprintf("hadith_search:%s:%d", col, hd.HadithNumber))))
+		n := (res.Page-1)*searchResultsPerPage + i + 1
+		fmt.Fprintf(&text, "\n\n<b>%d. %s #%d</b>\n%s", n, html.EscapeString(h.hadithService.GetCollectionDisplayName(col)), hd.HadithNumber, hd.Snippet)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d. 📜 Hadith #%d (%s)", n, hd.HadithNumber, h.hadithService.GetCollectionDisplayName(col)), fmt.Sprintf("hadith_search:%s:%d", col, hd.HadithNumber))))
 	}
 
+	rows = append(rows, h.searchFacetRows(id, session, res)...)
+
 	if res.TotalPages > 1 {
 		var nav []tgbotapi.InlineKeyboardButton
 		if res.Page > 1 {
-			nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("⬅️ Prev", fmt.Sprintf("search_prev:%s:%d", query, res.Page-1)))
+			nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("⬅️ Prev", fmt.Sprintf("s:%s:%d", id, res.Page-1)))
 		}
 		if res.Page < res.TotalPages {
-			nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("Next ➡️", fmt.Sprintf("search_next:%s:%d", query, res.Page+1)))
+			nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("Next ➡️", fmt.Sprintf("s:%s:%d", id, res.Page+1)))
 		}
 		rows = append(rows, nav)
 	}
 
-	h.editOrSendMessage(chatID, msgID, inlineMsgID, fmt.Sprintf("🔍 <b>Results for:</b> %s", html.EscapeString(query)), tgbotapi.NewInlineKeyboardMarkup(rows...))
+	h.editOrSendMessage(chatID, msgID, inlineMsgID, text.String(), tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+// searchFacetRows returns buttons such as "Sahih al-Bukhari (42)" that
+// narrow session id to one collection or grade of those the session offers.
+// Each set of buttons is only shown when the results span more than one
+// value.
+func (h *Handler) searchFacetRows(id string, session SearchSession, res models.SearchResult) [][]tgbotapi.InlineKeyboardButton {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	addFacets := func(choice string, values []string, facets []models.Facet, label func(string) string) {
+		var row []tgbotapi.InlineKeyboardButton
+		for i, value := range values {
+			data := fmt.Sprintf("sf:%s:%s:%d", id, choice, i)
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%s (%d)", label(value), facets[i].Count), data))
+			if len(row) == facetButtonsPerRow {
+				rows = append(rows, row)
+				row = nil
+			}
+		}
+		if len(row) > 0 {
+			rows = append(rows, row)
+		}
+	}
+
+	addFacets(choiceCollection, session.Collections, res.CollectionFacets, h.hadithService.GetCollectionDisplayName)
+	addFacets(choiceGrade, session.Grades, res.GradeFacets, func(v string) string { return v })
+	return rows
 }
 
 func (h *Handler) formatHadithDisplay(hdt *models.Hadith, col *models.Collection, b *models.Book) string {
@@ -1119,11 +1885,19 @@ func (h *Handler) findCollectionForHadith(hadith models.Hadith) string {
 	return "bukhari"
 }
 
+// plainText strips the <b> tags from a search snippet and unescapes it, for
+// places like inline result descriptions that do not render HTML
+func plainText(snippet string) string {
+	snippet = strings.NewReplacer("<b>", "", "</b>", "").Replace(snippet)
+	return html.UnescapeString(snippet)
+}
+
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
+	runes := []rune(s)
+	if len(runes) <= maxLen {
 		return s
 	}
-	return s[:maxLen-3] + "..."
+	return string(runes[:maxLen-3]) + "..."
 }
 
 func getCollectionTitle(c *models.Collection) string {
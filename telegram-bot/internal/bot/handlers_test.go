@@ -1,9 +1,19 @@
 package bot
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"hadith-bot/internal/logger"
 	"hadith-bot/internal/models"
+	"io"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 func TestEscapeHTML(t *testing.T) {
@@ -68,3 +78,162 @@ func TestFormatHadithDisplay(t *testing.T) {
 		t.Errorf("Result should contain bold Narrator label")
 	}
 }
+
+func TestShutdown(t *testing.T) {
+	log := logger.New(io.Discard, logger.ErrorLevel, false)
+	newHandler := func(handle func(tgbotapi.Update)) *Handler {
+		h := &Handler{
+			log:          log,
+			state:        NewStateManager(filepath.Join(t.TempDir(), "state.json")),
+			scheduleWake: make(chan struct{}, 1),
+		}
+		h.dispatcher = NewDispatcher(2, handle, log)
+		return h
+	}
+	chat := func(id int64) tgbotapi.Update {
+		return tgbotapi.Update{Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: id}}}
+	}
+	chatUpdate := func(updateID int, chatID int64) tgbotapi.Update {
+		u := chat(chatID)
+		u.UpdateID = updateID
+		return u
+	}
+
+	t.Run("drains", func(t *testing.T) {
+		handled := make(chan int64, 3)
+		h := newHandler(func(u tgbotapi.Update) {
+			time.Sleep(10 * time.Millisecond)
+			handled <- u.Message.Chat.ID
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		h.StartScheduler(ctx)
+		for _, id := range []int64{1, 2, 1} {
+			h.dispatcher.Dispatch(chat(id))
+		}
+		h.state.SetChatState(1, &ChatState{Timezone: "UTC"})
+		cancel()
+
+		if err := h.Shutdown(context.Background()); err != nil {
+			t.Fatalf("Shutdown returned error: %v", err)
+		}
+		if len(handled) != 3 {
+			t.Errorf("handled %d updates before Shutdown returned; want 3", len(handled))
+		}
+		if h.dispatcher.Dispatch(chat(3)) {
+			t.Error("Dispatch accepted an update after Shutdown")
+		}
+		if NewStateManager(h.state.filePath).GetChatState(1) == nil {
+			t.Error("state was not saved")
+		}
+	})
+
+	t.Run("polled updates", func(t *testing.T) {
+		var handled []int
+		var mu sync.Mutex
+		h := newHandler(func(u tgbotapi.Update) {
+			mu.Lock()
+			handled = append(handled, u.UpdateID)
+			mu.Unlock()
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		release := make(chan struct{})
+		defer close(release)
+
+		// Shutdown starts while a batch of three is in hand and the next
+		// poll, which would bring a fourth, is still waiting
+		var offsets []int
+		h.bot = &fakeClient{poll: func(u tgbotapi.UpdateConfig) ([]tgbotapi.Update, error) {
+			mu.Lock()
+			offsets = append(offsets, u.Offset)
+			polls := len(offsets)
+			mu.Unlock()
+			switch {
+			case polls == 1:
+				cancel()
+				return []tgbotapi.Update{chatUpdate(10, 1), chatUpdate(11, 2), chatUpdate(12, 1)}, nil
+			case u.Limit == 1:
+				return nil, nil // confirming
+			}
+			<-release // a long poll still open at exit
+			return nil, nil
+		}}
+
+		h.StartListening(ctx)
+		if err := h.Shutdown(context.Background()); err != nil {
+			t.Fatalf("Shutdown returned error: %v", err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(handled) != 3 {
+			t.Errorf("handled updates %v; want the three polled", handled)
+		}
+		// Confirmed past the dispatched three, and no further
+		if last := offsets[len(offsets)-1]; last != 13 {
+			t.Errorf("polled with offsets %v; want the last 13", offsets)
+		}
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		h := newHandler(func(tgbotapi.Update) { <-release })
+		h.dispatcher.Dispatch(chat(1))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		err := h.Shutdown(ctx)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Shutdown with a stuck update = %v; want the deadline", err)
+		}
+	})
+}
+
+func TestSettingsUpdatesDoNotRace(t *testing.T) {
+	client := &fakeClient{}
+	h := &Handler{
+		bot:          client,
+		log:          logger.New(io.Discard, logger.ErrorLevel, false),
+		rateLimiter:  NewRateLimiter(1000, time.Minute),
+		state:        NewStateManager(filepath.Join(t.TempDir(), "state.json")),
+		scheduleWake: make(chan struct{}, 1),
+		metrics:      NewMetrics(),
+	}
+	h.router = h.newRouter()
+	h.state.SetChatState(1, &ChatState{Schedules: []Schedule{{ID: 1, Every: time.Hour}}})
+	sent := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	ownChat := &tgbotapi.Chat{ID: 1, Type: "private"}
+
+	// User 1 toggles from their own chat and a group, which the dispatcher
+	// runs side by side, while schedules are added and the scheduler marks a
+	// send
+	var wg sync.WaitGroup
+	for i := 0; i < 9; i++ {
+		wg.Add(4)
+		go func() {
+			defer wg.Done()
+			h.HandleUpdate(commandUpdate(ownChat, 1, "/togglearabic"))
+		}()
+		go func() {
+			defer wg.Done()
+			h.HandleUpdate(commandUpdate(&tgbotapi.Chat{ID: -100, Type: "group"}, 1, "/togglebackgrounds"))
+		}()
+		go func() {
+			defer wg.Done()
+			h.HandleUpdate(commandUpdate(ownChat, 1, fmt.Sprintf("/schedule add every %dh", i+2)))
+		}()
+		go func() {
+			defer wg.Done()
+			h.state.MarkScheduleSent(1, 1, sent.Add(time.Duration(i)*time.Minute))
+		}()
+	}
+	wg.Wait()
+
+	state := h.state.GetChatState(1)
+	if !state.UseClassicArabic || !state.UseCustomBg {
+		t.Errorf("nine toggles each left classic Arabic %v and custom backgrounds %v; want both on", state.UseClassicArabic, state.UseCustomBg)
+	}
+	if len(state.Schedules) != 10 || state.Schedules[0].LastSentAt.Before(sent) {
+		t.Errorf("%d schedules, the first last sent %v; want 10 and a mark", len(state.Schedules), state.Schedules[0].LastSentAt)
+	}
+}
@@ -0,0 +1,113 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RouteStats counts the requests of one route
+type RouteStats struct {
+	Requests    int
+	RateLimited int
+	Denied      int
+	Panics      int
+	Duration    time.Duration // total time spent in the handler
+}
+
+// Metrics counts requests per route since the bot started
+type Metrics struct {
+	mu      sync.Mutex
+	started time.Time
+	routes  map[string]*RouteStats // by "kind route"
+}
+
+// NewMetrics creates empty metrics, starting now
+func NewMetrics() *Metrics {
+	return &Metrics{started: time.Now(), routes: make(map[string]*RouteStats)}
+}
+
+// Middleware counts each request under its kind and route
+func (m *Metrics) Middleware() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(r *Request) {
+			start := time.Now()
+			next(r)
+			m.record(r.Kind+" "+r.Route, r.Outcome, time.Since(start))
+		}
+	}
+}
+
+func (m *Metrics) record(route, outcome string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.routes[route]
+	if !ok {
+		s = &RouteStats{}
+		m.routes[route] = s
+	}
+	s.Requests++
+	s.Duration += d
+	switch outcome {
+	case OutcomeRateLimited:
+		s.RateLimited++
+	case OutcomeDenied:
+		s.Denied++
+	case OutcomePanic:
+		s.Panics++
+	}
+}
+
+// Snapshot returns a copy of the counts, by "kind route" such as
+// "command search"
+func (m *Metrics) Snapshot() map[string]RouteStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := make(map[string]RouteStats, len(m.routes))
+	for route, s := range m.routes {
+		snapshot[route] = *s
+	}
+	return snapshot
+}
+
+// Summary writes the counts as a plain text table, busiest route first
+func (m *Metrics) Summary() string {
+	snapshot := m.Snapshot()
+	routes := make([]string, 0, len(snapshot))
+	for route := range snapshot {
+		routes = append(routes, route)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		a, b := snapshot[routes[i]], snapshot[routes[j]]
+		if a.Requests != b.Requests {
+			return a.Requests > b.Requests
+		}
+		return routes[i] < routes[j]
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Since %s (%s)\n", m.started.UTC().Format("2006-01-02 15:04 MST"), time.Since(m.started).Round(time.Minute))
+	if len(routes) == 0 {
+		b.WriteString("No requests yet.\n")
+		return b.String()
+	}
+	for _, route := range routes {
+		s := snapshot[route]
+		fmt.Fprintf(&b, "%-22s %6d  avg %s", strings.TrimSpace(route), s.Requests, (s.Duration / time.Duration(s.Requests)).Round(time.Millisecond))
+		if s.RateLimited > 0 {
+			fmt.Fprintf(&b, "  limited %d", s.RateLimited)
+		}
+		if s.Denied > 0 {
+			fmt.Fprintf(&b, "  denied %d", s.Denied)
+		}
+		if s.Panics > 0 {
+			fmt.Fprintf(&b, "  panics %d", s.Panics)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,106 @@
+package bot
+
+import (
+	"runtime/debug"
+	"time"
+
+	"hadith-bot/internal/logger"
+)
+
+// Recover stops a panicking handler from taking the others down, logging
+// the panic and telling the user something went wrong
+func Recover(log *logger.Logger) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(r *Request) {
+			defer func() {
+				if p := recover(); p != nil {
+					r.Outcome = OutcomePanic
+					log.Error("Panic handling %s %q: %v\n%s", r.Kind, r.Route, p, debug.Stack())
+					r.Reply("⚠️ Something went wrong. Please try again.")
+				}
+			}()
+			next(r)
+		}
+	}
+}
+
+// Logging logs each request with what came of it and how long it took
+func Logging(log *logger.Logger) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(r *Request) {
+			start := time.Now()
+			next(r)
+			outcome := r.Outcome
+			if outcome == "" {
+				outcome = "ok"
+			}
+			log.Debug("Request: kind=%s route=%q update_id=%d chat_id=%d user_id=%d outcome=%s duration=%s",
+				r.Kind, r.Route, r.Update.UpdateID, r.ChatID(), r.UserID, outcome, time.Since(start).Round(time.Millisecond))
+		}
+	}
+}
+
+// RateLimit turns away the requests of users over limiter's rate. Inline
+// queries, sent as the user types, are not limited.
+func RateLimit(limiter *RateLimiter) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(r *Request) {
+			if r.Kind == KindInline || limiter.Allow(r.UserID) {
+				next(r)
+				return
+			}
+			r.Outcome = OutcomeRateLimited
+			if r.Callback != nil {
+				r.Callback.answer("⏳ Slow down a little.")
+			} else {
+				r.Reply("⏳ Please wait a moment before sending another command.")
+			}
+		}
+	}
+}
+
+// AdminOnly lets only the bot's administrator through, replying denial to
+// anyone else. Without an administrator set, everyone is let through.
+func AdminOnly(adminUserID int64, denial string) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(r *Request) {
+			if adminUserID != 0 && r.UserID != adminUserID {
+				r.Outcome = OutcomeDenied
+				r.Reply(denial)
+				return
+			}
+			next(r)
+		}
+	}
+}
+
+// RequireAdmin is AdminOnly for requests that reveal what only the
+// administrator should see: without an administrator set, no one is let
+// through
+func RequireAdmin(adminUserID int64, denial string) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(r *Request) {
+			if adminUserID == 0 || r.UserID != adminUserID {
+				r.Outcome = OutcomeDenied
+				r.Reply(denial)
+				return
+			}
+			next(r)
+		}
+	}
+}
+
+// GroupAdminOnly lets through anyone in a private chat and only the
+// administrators in a group, replying denial to others
+func GroupAdminOnly(client TelegramClient, denial string) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(r *Request) {
+			if r.Chat == nil || !isGroupAdmin(client, r.Chat, r.UserID) {
+				r.Outcome = OutcomeDenied
+				r.Reply(denial)
+				return
+			}
+			next(r)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package bot
+
+import (
+	"strings"
+
+	"hadith-bot/internal/logger"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Kinds of request
+const (
+	KindCommand  = "command"
+	KindMessage  = "message"
+	KindCallback = "callback"
+	KindInline   = "inline"
+)
+
+// Outcomes the middleware records on a request that was not handled
+// normally
+const (
+	OutcomeRateLimited = "rate_limited"
+	OutcomeDenied      = "denied"
+	OutcomePanic       = "panic"
+)
+
+// Request is an update on its way through the router to a handler: a
+// command, another message, a button press or an inline query
+type Request struct {
+	Kind  string
+	Route string // command name, callback key or inline prefix
+	Args  string // text after the command or inline prefix
+
+	Update   tgbotapi.Update
+	Message  *tgbotapi.Message     // commands and messages
+	Callback *callback             // button presses
+	Inline   *tgbotapi.InlineQuery // inline queries
+
+	Chat   *tgbotapi.Chat // nil for inline queries and buttons on inline messages
+	UserID int64
+
+	// Outcome is set by middleware that stops or fails the request
+	Outcome string
+
+	client TelegramClient
+}
+
+// ChatID returns the request's chat, or 0 when it has none
+func (r *Request) ChatID() int64 {
+	if r.Chat == nil {
+		return 0
+	}
+	return r.Chat.ID
+}
+
+// Reply tells the user something about their request: as a message in the
+// chat, as an alert for a button press, and not at all for inline queries
+func (r *Request) Reply(text string) {
+	switch {
+	case r.Callback != nil:
+		r.Callback.alert(text)
+	case r.Chat != nil:
+		msg := tgbotapi.NewMessage(r.Chat.ID, text)
+		msg.ParseMode = tgbotapi.ModeHTML
+		r.client.Send(msg)
+	}
+}
+
+// HandlerFunc handles a routed request
+type HandlerFunc func(r *Request)
+
+// Middleware wraps a handler, acting before or after it or instead of it
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// chain wraps handle in mw, the first outermost
+func chain(handle HandlerFunc, mw []Middleware) HandlerFunc {
+	for i := len(mw) - 1; i >= 0; i-- {
+		handle = mw[i](handle)
+	}
+	return handle
+}
+
+// Router passes updates to the handlers registered for their commands,
+// button data and inline query prefixes, through its middleware. Updates
+// no handler takes, such as unknown commands, skip the middleware.
+type Router struct {
+	client     TelegramClient
+	log        *logger.Logger
+	middleware []Middleware
+
+	commands  map[string]HandlerFunc
+	message   HandlerFunc
+	callbacks callbackRouter
+	inline    map[string]HandlerFunc
+}
+
+// NewRouter creates a router that answers through client
+func NewRouter(client TelegramClient, log *logger.Logger) *Router {
+	return &Router{
+		client:    client,
+		log:       log,
+		commands:  make(map[string]HandlerFunc),
+		callbacks: make(callbackRouter),
+		inline:    make(map[string]HandlerFunc),
+	}
+}
+
+// Use adds middleware that every routed request goes through, in the order
+// added, around the middleware of the route itself
+func (r *Router) Use(mw ...Middleware) {
+	r.middleware = append(r.middleware, mw...)
+}
+
+// Command registers the handler of a command, without its slash. A photo
+// whose caption starts with the command is routed to it too.
+func (r *Router) Command(name string, handle HandlerFunc, mw ...Middleware) {
+	r.commands[name] = chain(handle, mw)
+}
+
+// Message registers the handler of messages that are not commands
+func (r *Router) Message(handle HandlerFunc, mw ...Middleware) {
+	r.message = chain(handle, mw)
+}
+
+// Callback registers the handler of buttons whose data starts with key. The
+// route's params are checked before it runs; see callbackRoute.
+func (r *Router) Callback(key string, route callbackRoute, mw ...Middleware) {
+	route.run = chain(func(req *Request) { route.handle(req.Callback) }, mw)
+	r.callbacks[key] = route
+}
+
+// Inline registers the handler of inline queries whose first word is
+// prefix. The handler for "" takes the queries no other prefix does.
+func (r *Router) Inline(prefix string, handle HandlerFunc, mw ...Middleware) {
+	r.inline[prefix] = chain(handle, mw)
+}
+
+// Route passes an update to its handler
+func (r *Router) Route(update tgbotapi.Update) {
+	switch {
+	case update.Message != nil:
+		r.routeMessage(update, update.Message)
+	case update.CallbackQuery != nil:
+		r.routeCallback(update, update.CallbackQuery)
+	case update.InlineQuery != nil:
+		r.routeInline(update, update.InlineQuery)
+	}
+}
+
+func (r *Router) routeMessage(update tgbotapi.Update, m *tgbotapi.Message) {
+	req := &Request{Update: update, Message: m, Chat: m.Chat, client: r.client}
+	if m.From != nil {
+		req.UserID = m.From.ID
+	}
+
+	name, args, isCommand := messageCommand(m)
+	if !isCommand {
+		if r.message != nil {
+			req.Kind = KindMessage
+			r.run(req, r.message)
+		}
+		return
+	}
+	if handle, ok := r.commands[name]; ok {
+		req.Kind, req.Route, req.Args = KindCommand, name, args
+		r.run(req, handle)
+	}
+}
+
+func (r *Router) routeCallback(update tgbotapi.Update, c *tgbotapi.CallbackQuery) {
+	key, route, cb, ok := r.callbacks.match(c)
+	if !ok {
+		r.log.Debug("No route for callback data %q", c.Data)
+		r.client.Request(tgbotapi.NewCallbackWithAlert(c.ID, "⌛ This button has expired. Send the command again."))
+		return
+	}
+	cb.client = r.client
+
+	req := &Request{Kind: KindCallback, Route: key, Update: update, Callback: cb, client: r.client}
+	if c.Message != nil {
+		req.Chat = c.Message.Chat
+	}
+	if c.From != nil {
+		req.UserID = c.From.ID
+	}
+	r.run(req, route.run)
+	cb.answer("")
+}
+
+func (r *Router) routeInline(update tgbotapi.Update, q *tgbotapi.InlineQuery) {
+	query := strings.TrimSpace(q.Query)
+	prefix, args, _ := strings.Cut(query, " ")
+	handle, ok := r.inline[prefix]
+	if !ok {
+		prefix, args = "", query
+		if handle, ok = r.inline[""]; !ok {
+			return
+		}
+	}
+
+	req := &Request{Kind: KindInline, Route: prefix, Args: strings.TrimSpace(args), Update: update, Inline: q, client: r.client}
+	if q.From != nil {
+		req.UserID = q.From.ID
+	}
+	r.run(req, handle)
+}
+
+func (r *Router) run(req *Request, handle HandlerFunc) {
+	chain(handle, r.middleware)(req)
+}
+
+// messageCommand returns the command a message starts with and the text
+// after it, looking in a photo's caption too
+func messageCommand(m *tgbotapi.Message) (name, args string, ok bool) {
+	if m.IsCommand() {
+		return m.Command(), m.CommandArguments(), true
+	}
+	if len(m.Photo) == 0 || !strings.HasPrefix(m.Caption, "/") {
+		return "", "", false
+	}
+	word, args, _ := strings.Cut(m.Caption, " ")
+	name, _, _ = strings.Cut(strings.TrimPrefix(word, "/"), "@")
+	return name, strings.TrimSpace(args), name != ""
+}
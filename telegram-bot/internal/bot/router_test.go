@@ -0,0 +1,252 @@
+package bot
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"hadith-bot/internal/logger"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fakeClient records what handlers send instead of calling Telegram
+type fakeClient struct {
+	mu     sync.Mutex
+	sent   []tgbotapi.Chattable
+	status string                                                 // every user's status in every chat
+	poll   func(tgbotapi.UpdateConfig) ([]tgbotapi.Update, error) // answers GetUpdates
+}
+
+func (f *fakeClient) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, c)
+	return tgbotapi.Message{}, nil
+}
+
+func (f *fakeClient) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, c)
+	return &tgbotapi.APIResponse{Ok: true}, nil
+}
+
+func (f *fakeClient) GetChatMember(tgbotapi.GetChatMemberConfig) (tgbotapi.ChatMember, error) {
+	return tgbotapi.ChatMember{Status: f.status}, nil
+}
+
+func (f *fakeClient) GetFileDirectURL(string) (string, error) {
+	return "", errors.New("no files in tests")
+}
+
+func (f *fakeClient) GetUpdates(u tgbotapi.UpdateConfig) ([]tgbotapi.Update, error) {
+	return f.poll(u)
+}
+
+// texts returns the text of each message and callback answer sent, in order
+func (f *fakeClient) texts() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var texts []string
+	for _, c := range f.sent {
+		switch c := c.(type) {
+		case tgbotapi.MessageConfig:
+			texts = append(texts, c.Text)
+		case tgbotapi.CallbackConfig:
+			texts = append(texts, c.Text)
+		}
+	}
+	return texts
+}
+
+func commandUpdate(chat *tgbotapi.Chat, userID int64, text string) tgbotapi.Update {
+	word, _, _ := strings.Cut(text, " ")
+	return tgbotapi.Update{Message: &tgbotapi.Message{
+		Chat:     chat,
+		From:     &tgbotapi.User{ID: userID},
+		Text:     text,
+		Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(word)}},
+	}}
+}
+
+var privateChat = &tgbotapi.Chat{ID: 42, Type: "private"}
+
+func TestRouterRoutes(t *testing.T) {
+	var got []string
+	record := func(req *Request) { got = append(got, req.Kind+" "+req.Route+" "+req.Args) }
+
+	client := &fakeClient{}
+	r := NewRouter(client, logger.New(io.Discard, logger.ErrorLevel, false))
+	r.Command("search", record)
+	r.Command("addbg", record)
+	r.Message(record)
+	r.Callback("books", callbackRoute{
+		params: []callbackParam{paramString, paramInt},
+		handle: func(cb *callback) { got = append(got, "callback books "+cb.String(0)) },
+	})
+	r.Inline("", record)
+	r.Inline("search", record)
+
+	tests := []struct {
+		update tgbotapi.Update
+		want   string
+	}{
+		{commandUpdate(privateChat, 1, "/search prayer times"), "command search prayer times"},
+		{commandUpdate(privateChat, 1, "/unknown"), ""},
+		{tgbotapi.Update{Message: &tgbotapi.Message{Chat: privateChat, Text: "bukhari 1"}}, "message  "},
+		{tgbotapi.Update{Message: &tgbotapi.Message{Chat: privateChat, Photo: []tgbotapi.PhotoSize{{FileID: "f"}}, Caption: "/addbg@HadithBot dawn"}}, "command addbg dawn"},
+		{tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{ID: "1", Data: "books:bukhari:2"}}, "callback books bukhari"},
+		{tgbotapi.Update{InlineQuery: &tgbotapi.InlineQuery{ID: "1", Query: "search  fasting "}}, "inline search fasting"},
+		{tgbotapi.Update{InlineQuery: &tgbotapi.InlineQuery{ID: "1", Query: "fasting in ramadan"}}, "inline  fasting in ramadan"},
+	}
+
+	for _, tt := range tests {
+		got = nil
+		r.Route(tt.update)
+		if strings.Join(got, "|") != tt.want {
+			t.Errorf("routed %+v to %q; want %q", tt.update, got, tt.want)
+		}
+	}
+
+	// Buttons are answered, and unknown ones answered as expired
+	client.sent = nil
+	r.Route(tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{ID: "1", Data: "books:bukhari:2"}})
+	r.Route(tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{ID: "2", Data: "gone:1"}})
+	if texts := client.texts(); len(texts) != 2 || texts[0] != "" || !strings.Contains(texts[1], "expired") {
+		t.Errorf("callback answers = %q", texts)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	log := logger.New(io.Discard, logger.ErrorLevel, false)
+	client := &fakeClient{status: "member"}
+	metrics := NewMetrics()
+
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(r *Request) {
+				order = append(order, name)
+				next(r)
+			}
+		}
+	}
+
+	r := NewRouter(client, log)
+	r.Use(metrics.Middleware(), Logging(log), Recover(log), trace("global"), RateLimit(NewRateLimiter(2, time.Minute)))
+	r.Command("ok", func(*Request) { order = append(order, "handler") }, trace("route"))
+	r.Command("panic", func(*Request) { panic("boom") })
+	r.Command("admin", func(*Request) {}, AdminOnly(7, "admins only"))
+	r.Command("group", func(*Request) {}, GroupAdminOnly(client, "group admins only"))
+	r.Command("report", func(*Request) {}, RequireAdmin(0, "no admin set"))
+
+	r.Route(commandUpdate(privateChat, 1, "/ok"))
+	if strings.Join(order, ",") != "global,route,handler" {
+		t.Errorf("middleware ran as %v", order)
+	}
+
+	group := &tgbotapi.Chat{ID: -100, Type: "supergroup"}
+	r.Route(commandUpdate(privateChat, 2, "/panic"))
+	r.Route(commandUpdate(privateChat, 3, "/admin"))
+	r.Route(commandUpdate(privateChat, 7, "/admin"))
+	r.Route(commandUpdate(group, 4, "/group"))
+	r.Route(commandUpdate(privateChat, 4, "/group"))
+	r.Route(commandUpdate(privateChat, 5, "/report"))
+	r.Route(commandUpdate(privateChat, 1, "/ok"))
+	r.Route(commandUpdate(privateChat, 1, "/ok")) // a third within the minute
+
+	want := []string{"⚠️ Something went wrong. Please try again.", "admins only", "group admins only", "no admin set", "⏳ Please wait a moment before sending another command."}
+	if got := client.texts(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("replies = %q; want %q", got, want)
+	}
+
+	stats := metrics.Snapshot()
+	for route, want := range map[string]RouteStats{
+		"command ok":     {Requests: 3, RateLimited: 1},
+		"command panic":  {Requests: 1, Panics: 1},
+		"command admin":  {Requests: 2, Denied: 1},
+		"command group":  {Requests: 2, Denied: 1},
+		"command report": {Requests: 1, Denied: 1},
+	} {
+		got := stats[route]
+		got.Duration = 0
+		if got != want {
+			t.Errorf("metrics for %q = %+v; want %+v", route, got, want)
+		}
+	}
+	if summary := metrics.Summary(); !strings.Contains(summary, "command ok") || !strings.Contains(summary, "panics 1") {
+		t.Errorf("summary lacks the routes:\n%s", summary)
+	}
+}
+
+func TestHandlerRoutesWithoutBot(t *testing.T) {
+	client := &fakeClient{status: "member"}
+	h := &Handler{
+		bot:          client,
+		log:          logger.New(io.Discard, logger.ErrorLevel, false),
+		rateLimiter:  NewRateLimiter(10, time.Minute),
+		state:        NewStateManager(filepath.Join(t.TempDir(), "state.json")),
+		adminUserID:  7,
+		scheduleWake: make(chan struct{}, 1),
+		sessions:     NewSessionStore(callbackSessionTTL, maxCallbackSessions),
+		metrics:      NewMetrics(),
+	}
+	h.router = h.newRouter()
+
+	h.HandleUpdate(commandUpdate(privateChat, 1, "/datareport"))
+	h.HandleUpdate(commandUpdate(&tgbotapi.Chat{ID: -100, Type: "group"}, 1, "/schedule off"))
+	h.HandleUpdate(commandUpdate(privateChat, 1, "/schedule add daily 06:30 UTC"))
+	h.HandleUpdate(tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:      "1",
+		From:    &tgbotapi.User{ID: 1},
+		Data:    "sched:fmt:1",
+		Message: &tgbotapi.Message{MessageID: 5, Chat: privateChat},
+	}})
+	h.HandleUpdate(commandUpdate(privateChat, 7, "/stats"))
+
+	texts := client.texts()
+	if len(texts) < 4 {
+		t.Fatalf("replies = %q", texts)
+	}
+	if !strings.Contains(texts[0], "permission to view the data report") {
+		t.Errorf("/datareport from a non-admin replied %q", texts[0])
+	}
+	if !strings.Contains(texts[1], "Only group administrators") {
+		t.Errorf("/schedule from a group member replied %q", texts[1])
+	}
+	if !strings.Contains(texts[2], "(#1)") {
+		t.Errorf("/schedule add replied %q", texts[2])
+	}
+	if slot := h.state.GetChatState(42).Schedules[0]; slot.Content.Format != FormatText {
+		t.Errorf("format after the button = %q; want text", slot.Content.Format)
+	}
+	if last := texts[len(texts)-1]; !strings.Contains(last, "command schedule") || !strings.Contains(last, "denied 1") {
+		t.Errorf("/stats replied %q", last)
+	}
+}
+
+func TestAdminCommandsWithoutAdmin(t *testing.T) {
+	client := &fakeClient{}
+	h := &Handler{
+		bot:         client,
+		log:         logger.New(io.Discard, logger.ErrorLevel, false),
+		rateLimiter: NewRateLimiter(10, time.Minute),
+		metrics:     NewMetrics(),
+	}
+	h.router = h.newRouter()
+
+	// With no ADMIN_USER_ID, the commands that reveal the bot's internals
+	// are refused to everyone
+	for _, command := range []string{"/stats", "/datareport"} {
+		client.sent = nil
+		h.HandleUpdate(commandUpdate(privateChat, 1, command))
+		if texts := client.texts(); len(texts) != 1 || !strings.Contains(texts[0], "do not have permission") {
+			t.Errorf("%s without an admin replied %q", command, texts)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package bot
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"hadith-bot/internal/models"
+)
+
+// maxSchedulesPerChat caps the slots a chat can have
+const maxSchedulesPerChat = 10
+
+// scheduleGrace is how late a wall-clock slot may still fire, for example
+// after a restart. A slot missed by more is skipped until its next time, so a
+// morning hadith is not posted in the afternoon.
+const scheduleGrace = 30 * time.Minute
+
+// Schedule is one slot at which a chat gets a random hadith: at a time of day
+// on some weekdays in a timezone, or, for the older interval schedules,
+// every Every since the last one.
+type Schedule struct {
+	ID       int            `json:"id"`
+	Every    time.Duration  `json:"every,omitempty"`
+	Days     []time.Weekday `json:"days,omitempty"` // empty for every day
+	Time     string         `json:"time,omitempty"` // "15:04"
+	Timezone string         `json:"timezone,omitempty"`
+
+	// LastSentAt is when the slot last fired, or when it was added; its next
+	// time is counted from here
+	LastSentAt time.Time `json:"last_sent_at"`
+
+	Content ScheduleContent `json:"content"`
+}
+
+// Formats of a scheduled post
+const (
+	FormatImage = "" // the default
+	FormatText  = "text"
+	FormatBoth  = "both"
+)
+
+// Languages of a scheduled post
+const (
+	LanguageBoth    = "" // the default
+	LanguageArabic  = "arabic"
+	LanguageEnglish = "english"
+)
+
+// ScheduleContent is what a slot posts: which hadiths it draws from and how
+// it shows them. The zero value posts an image of any hadith in both
+// languages.
+type ScheduleContent struct {
+	Collections []string `json:"collections,omitempty"` // collection names; empty for all
+	Topic       string   `json:"topic,omitempty"`       // topic ID
+	BookFrom    int      `json:"book_from,omitempty"`   // book range, with a single collection
+	BookTo      int      `json:"book_to,omitempty"`
+	Format      string   `json:"format,omitempty"`
+	Language    string   `json:"language,omitempty"`
+
+	UseCustomBg      bool `json:"use_custom_bg,omitempty"`
+	UseClassicArabic bool `json:"use_classic_arabic,omitempty"`
+}
+
+// Filter returns the search filter for the hadiths the slot draws from
+func (c ScheduleContent) Filter() models.SearchFilter {
+	f := models.SearchFilter{Collections: c.Collections}
+	if c.Topic != "" {
+		f.Topics = []string{c.Topic}
+	}
+	if len(c.Collections) == 1 && c.BookFrom > 0 {
+		f.BookFrom, f.BookTo = c.BookFrom, c.BookTo
+	}
+	return f
+}
+
+// nextFormat and nextLanguage cycle through the choices for the settings
+// menu's buttons
+func nextFormat(format string) string {
+	switch format {
+	case FormatImage:
+		return FormatText
+	case FormatText:
+		return FormatBoth
+	default:
+		return FormatImage
+	}
+}
+
+func nextLanguage(language string) string {
+	switch language {
+	case LanguageBoth:
+		return LanguageArabic
+	case LanguageArabic:
+		return LanguageEnglish
+	default:
+		return LanguageBoth
+	}
+}
+
+func formatName(format string) string {
+	switch format {
+	case FormatText:
+		return "Text"
+	case FormatBoth:
+		return "Image and text"
+	default:
+		return "Image"
+	}
+}
+
+func languageName(language string) string {
+	switch language {
+	case LanguageArabic:
+		return "Arabic only"
+	case LanguageEnglish:
+		return "English only"
+	default:
+		return "Arabic and English"
+	}
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tues": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// ParseSchedule reads a slot as typed after /schedule add. Its errors are
+// HTML for the reply.
+//
+//	daily 06:30 Asia/Karachi
+//	mon,thu 20:00
+//	every 6h
+//
+// A bare duration ("6h") is an interval too. Wall-clock slots without a
+// timezone use defaultTimezone, or UTC when that is empty. ID and LastSentAt
+// are left for the caller to set.
+func ParseSchedule(args string, defaultTimezone string) (Schedule, error) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return Schedule{}, fmt.Errorf("say when, such as <code>daily 06:30</code>")
+	}
+
+	if strings.EqualFold(fields[0], "every") {
+		if len(fields) != 2 {
+			return Schedule{}, fmt.Errorf("use <code>every 6h</code> for an interval")
+		}
+		return parseInterval(fields[1])
+	}
+	if len(fields) == 1 {
+		return parseInterval(fields[0])
+	}
+
+	if len(fields) > 3 {
+		return Schedule{}, fmt.Errorf("too many words; use <code>daily 06:30 Asia/Karachi</code>")
+	}
+
+	var days []time.Weekday
+	if day := strings.ToLower(fields[0]); day != "daily" && day != "everyday" {
+		seen := make(map[time.Weekday]bool)
+		for _, name := range strings.Split(day, ",") {
+			d, ok := weekdayNames[strings.TrimSpace(name)]
+			if !ok {
+				return Schedule{}, fmt.Errorf("%q is not a weekday; use names such as <code>mon,thu</code> or <code>daily</code>", html.EscapeString(name))
+			}
+			if !seen[d] {
+				seen[d] = true
+				days = append(days, d)
+			}
+		}
+		if len(days) == 7 {
+			days = nil
+		}
+		sort.Slice(days, func(i, j int) bool { return days[i] < days[j] })
+	}
+
+	clock, err := time.Parse("15:04", fields[1])
+	if err != nil {
+		return Schedule{}, fmt.Errorf("%q is not a time such as <code>06:30</code> or <code>20:00</code>", html.EscapeString(fields[1]))
+	}
+
+	tz := defaultTimezone
+	if len(fields) == 3 {
+		tz = fields[2]
+	}
+	if tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil || strings.EqualFold(tz, "local") {
+			return Schedule{}, fmt.Errorf("unknown timezone %q; use a name such as <code>Asia/Karachi</code> or <code>UTC</code>", html.EscapeString(tz))
+		}
+		tz = loc.String()
+	}
+
+	return Schedule{Days: days, Time: clock.Format("15:04"), Timezone: tz}, nil
+}
+
+func parseInterval(text string) (Schedule, error) {
+	d, err := time.ParseDuration(text)
+	if err != nil || d < time.Minute {
+		return Schedule{}, fmt.Errorf("%q is not an interval such as <code>2h</code>, nor a time such as <code>daily 06:30</code>", html.EscapeString(text))
+	}
+	return Schedule{Every: d}, nil
+}
+
+// Location returns the slot's timezone, or UTC when it has none or it no
+// longer loads
+func (s Schedule) Location() *time.Location {
+	if s.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Next returns when the slot fires next: the first of its times after
+// LastSentAt. It may be in the past when the slot is due.
+func (s Schedule) Next() time.Time {
+	if s.Every > 0 {
+		return s.LastSentAt.Add(s.Every)
+	}
+	return s.nextAfter(s.LastSentAt)
+}
+
+// nextAfter returns the first of the slot's wall-clock times after t. A time
+// skipped by a daylight saving change fires at the time it normalizes to.
+func (s Schedule) nextAfter(t time.Time) time.Time {
+	clock, err := time.Parse("15:04", s.Time)
+	if err != nil {
+		return time.Time{}
+	}
+	loc := s.Location()
+	local := t.In(loc)
+
+	// A week ahead always holds one of the slot's days; the extra day covers
+	// a time already past on the first
+	for i := 0; i <= 7; i++ {
+		day := time.Date(local.Year(), local.Month(), local.Day()+i, 0, 0, 0, 0, loc)
+		if !s.onDay(day.Weekday()) {
+			continue
+		}
+		next := time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+		if next.After(t) {
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+func (s Schedule) onDay(d time.Weekday) bool {
+	if len(s.Days) == 0 {
+		return true
+	}
+	for _, day := range s.Days {
+		if day == d {
+			return true
+		}
+	}
+	return false
+}
+
+// String describes the slot, such as "Mon, Thu at 20:00 (Asia/Karachi)" or
+// "every 6h0m0s"
+func (s Schedule) String() string {
+	if s.Every > 0 {
+		return "every " + s.Every.String()
+	}
+	days := "daily"
+	if len(s.Days) > 0 {
+		names := make([]string, len(s.Days))
+		for i, d := range s.Days {
+			names[i] = d.String()[:3]
+		}
+		days = strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%s at %s (%s)", days, s.Time, s.Location())
+}
+
+// nextScheduleID returns an ID no slot of the chat uses
+func nextScheduleID(schedules []Schedule) int {
+	id := 0
+	for _, s := range schedules {
+		if s.ID > id {
+			id = s.ID
+		}
+	}
+	return id + 1
+}
+
+// parseScheduleID reads the ID given to /schedule remove
+func parseScheduleID(text string) (int, bool) {
+	id, err := strconv.Atoi(strings.TrimPrefix(text, "#"))
+	return id, err == nil && id > 0
+}
@@ -0,0 +1,362 @@
+package bot
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+
+	"hadith-bot/internal/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// scheduleBooksPerPage is how many books the book range picker lists
+const scheduleBooksPerPage = 10
+
+// registerScheduleCallbacks routes the buttons of the schedule settings
+// menu:
+//
+//	sched:list                      the chat's slots
+//	sched:menu:<id>                 a slot's settings
+//	sched:cols:<id>                 collection picker
+//	sched:col:<id>:<name>           toggle a collection; "*" for all
+//	sched:topics:<id>:<page>        topic picker
+//	sched:topic:<id>:<topic>        set the topic; empty for any
+//	sched:books:<id>:<page>         first book picker
+//	sched:bookto:<id>:<from>:<page> last book picker
+//	sched:book:<id>:<from>:<to>     set the book range; 0:0 for all
+//	sched:fmt|lang|bg|font:<id>     cycle format or language, toggle image style
+//
+// They answer with an alert when the user may not change the schedule.
+func (h *Handler) registerScheduleCallbacks(r *Router) {
+	id := []callbackParam{paramInt}
+	newRoute := func(params []callbackParam, handle func(cb *callback)) callbackRoute {
+		return callbackRoute{params: params, handle: handle}
+	}
+	// setting changes a slot's content and shows the settings again
+	setting := func(update func(content *ScheduleContent)) callbackRoute {
+		return newRoute(id, func(cb *callback) {
+			if h.updateScheduleContent(cb.chatID, cb.msgID, cb.Int(0), update) {
+				h.sendScheduleMenu(cb.chatID, cb.msgID, cb.Int(0))
+			}
+		})
+	}
+
+	for key, route := range map[string]callbackRoute{
+		"sched:list": newRoute(nil, func(cb *callback) {
+			h.sendScheduleList(cb.chatID, cb.msgID)
+		}),
+		"sched:menu": newRoute(id, func(cb *callback) {
+			h.sendScheduleMenu(cb.chatID, cb.msgID, cb.Int(0))
+		}),
+		"sched:cols": newRoute(id, func(cb *callback) {
+			h.sendScheduleCollections(cb.chatID, cb.msgID, cb.Int(0))
+		}),
+		"sched:col": newRoute([]callbackParam{paramInt, paramString}, func(cb *callback) {
+			slot, name := cb.Int(0), cb.String(1)
+			if h.updateScheduleContent(cb.chatID, cb.msgID, slot, func(content *ScheduleContent) {
+				if name == "*" {
+					content.Collections = nil
+				} else {
+					content.Collections = toggleString(content.Collections, name)
+				}
+				// A book range belongs to the collection it was chosen in
+				content.BookFrom, content.BookTo = 0, 0
+			}) {
+				h.sendScheduleCollections(cb.chatID, cb.msgID, slot)
+			}
+		}),
+		"sched:topics": newRoute([]callbackParam{paramInt, paramInt}, func(cb *callback) {
+			h.sendScheduleTopics(cb.chatID, cb.msgID, cb.Int(0), cb.Int(1))
+		}),
+		"sched:topic": newRoute([]callbackParam{paramInt, paramString}, func(cb *callback) {
+			slot, topic := cb.Int(0), cb.String(1)
+			if h.updateScheduleContent(cb.chatID, cb.msgID, slot, func(content *ScheduleContent) { content.Topic = topic }) {
+				h.sendScheduleMenu(cb.chatID, cb.msgID, slot)
+			}
+		}),
+		"sched:books": newRoute([]callbackParam{paramInt, paramInt}, func(cb *callback) {
+			if !h.sendScheduleBooks(cb.chatID, cb.msgID, cb.Int(0), 0, cb.Int(1)) {
+				cb.answer("Choose a single collection first.")
+			}
+		}),
+		"sched:bookto": newRoute([]callbackParam{paramInt, paramInt, paramInt}, func(cb *callback) {
+			h.sendScheduleBooks(cb.chatID, cb.msgID, cb.Int(0), cb.Int(1), cb.Int(2))
+		}),
+		"sched:book": newRoute([]callbackParam{paramInt, paramInt, paramInt}, func(cb *callback) {
+			slot, from, to := cb.Int(0), cb.Int(1), cb.Int(2)
+			if h.updateScheduleContent(cb.chatID, cb.msgID, slot, func(content *ScheduleContent) { content.BookFrom, content.BookTo = from, max(from, to) }) {
+				h.sendScheduleMenu(cb.chatID, cb.msgID, slot)
+			}
+		}),
+		"sched:fmt":  setting(func(content *ScheduleContent) { content.Format = nextFormat(content.Format) }),
+		"sched:lang": setting(func(content *ScheduleContent) { content.Language = nextLanguage(content.Language) }),
+		"sched:bg":   setting(func(content *ScheduleContent) { content.UseCustomBg = !content.UseCustomBg }),
+		"sched:font": setting(func(content *ScheduleContent) { content.UseClassicArabic = !content.UseClassicArabic }),
+	} {
+		r.Callback(key, route, GroupAdminOnly(h.bot, "Only group administrators can change the schedule."))
+	}
+}
+
+// updateScheduleContent changes the content of one of a chat's slots. When
+// the slot is gone, as after /schedule remove, it says so in the menu's
+// message and returns false.
+func (h *Handler) updateScheduleContent(chatID int64, msgID int, id int, update func(*ScheduleContent)) bool {
+	_, ok, err := h.state.UpdateSchedule(chatID, id, func(slot *Schedule) { update(&slot.Content) })
+	if err != nil {
+		h.log.Error("Failed to save schedule #%d of %d: %v", id, chatID, err)
+	}
+	if !ok {
+		h.sendScheduleGone(chatID, msgID, id)
+	}
+	return ok
+}
+
+// findSchedule returns one of a chat's slots
+func (h *Handler) findSchedule(chatID int64, id int) (Schedule, bool) {
+	if chatState := h.state.GetChatState(chatID); chatState != nil {
+		for _, slot := range chatState.Schedules {
+			if slot.ID == id {
+				return slot, true
+			}
+		}
+	}
+	return Schedule{}, false
+}
+
+func (h *Handler) sendScheduleGone(chatID int64, msgID int, id int) {
+	h.editOrSendMessage(chatID, msgID, "", fmt.Sprintf("⚠️ There is no scheduled time #%d any more.", id), tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⬅️ Back to Schedules", "sched:list")),
+	))
+}
+
+// sendScheduleMenu shows a slot's settings, with a button for each
+func (h *Handler) sendScheduleMenu(chatID int64, msgID int, id int) {
+	slot, ok := h.findSchedule(chatID, id)
+	if !ok {
+		h.sendScheduleGone(chatID, msgID, id)
+		return
+	}
+	content := slot.Content
+
+	collections := "All"
+	if len(content.Collections) > 0 {
+		names := make([]string, len(content.Collections))
+		for i, name := range content.Collections {
+			names[i] = h.hadithService.GetCollectionDisplayName(name)
+		}
+		collections = strings.Join(names, ", ")
+	}
+	topic := "Any"
+	if content.Topic != "" {
+		topic = content.Topic
+		if t, ok := h.hadithService.ResolveTopic(content.Topic); ok {
+			topic = t.Name
+		}
+	}
+	books := "All"
+	if f := content.Filter(); f.BookFrom > 0 {
+		books = strconv.Itoa(f.BookFrom)
+		if f.BookTo > f.BookFrom {
+			books = fmt.Sprintf("%d–%d", f.BookFrom, f.BookTo)
+		}
+	}
+	background := "Default pattern"
+	if content.UseCustomBg {
+		background = "Custom images"
+	}
+	font := "Amiri"
+	if content.UseClassicArabic {
+		font = "Scheherazade New"
+	}
+
+	text := fmt.Sprintf("⚙️ <b>Schedule #%d</b> — %s\n\n"+
+		"📚 <b>Collections:</b> %s\n🏷️ <b>Topic:</b> %s\n📖 <b>Books:</b> %s\n"+
+		"🖼️ <b>Format:</b> %s\n🔤 <b>Language:</b> %s\n🎨 <b>Background:</b> %s\n✒️ <b>Arabic font:</b> %s",
+		slot.ID, html.EscapeString(slot.String()),
+		html.EscapeString(collections), html.EscapeString(topic), books,
+		formatName(content.Format), languageName(content.Language), background, font)
+
+	data := func(action string) string { return fmt.Sprintf("sched:%s:%d", action, id) }
+	kb := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📚 Collections", data("cols")),
+			tgbotapi.NewInlineKeyboardButtonData("🏷️ Topic", data("topics")+":1"),
+			tgbotapi.NewInlineKeyboardButtonData("📖 Books", data("books")+":1"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🖼️ "+formatName(content.Format), data("fmt")),
+			tgbotapi.NewInlineKeyboardButtonData("🔤 "+languageName(content.Language), data("lang")),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🎨 Background", data("bg")),
+			tgbotapi.NewInlineKeyboardButtonData("✒️ Arabic font", data("font")),
+		),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⬅️ Back to Schedules", "sched:list")),
+	)
+	h.editOrSendMessage(chatID, msgID, "", text, kb)
+}
+
+// sendScheduleCollections lists the collections with the slot's ticked
+func (h *Handler) sendScheduleCollections(chatID int64, msgID int, id int) {
+	slot, ok := h.findSchedule(chatID, id)
+	if !ok {
+		h.sendScheduleGone(chatID, msgID, id)
+		return
+	}
+	chosen := make(map[string]bool)
+	for _, name := range slot.Content.Collections {
+		chosen[name] = true
+	}
+	tick := func(on bool, label string) string {
+		if on {
+			return "✅ " + label
+		}
+		return label
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, col := range h.hadithService.GetCollections() {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(tick(chosen[col.Name], truncate(h.hadithService.GetCollectionDisplayName(col.Name), 25)), fmt.Sprintf("sched:col:%d:%s", id, col.Name)))
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(tick(len(chosen) == 0, "All collections"), fmt.Sprintf("sched:col:%d:*", id))),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⬅️ Back", fmt.Sprintf("sched:menu:%d", id))),
+	)
+	h.editOrSendMessage(chatID, msgID, "", fmt.Sprintf("📚 <b>Schedule #%d — Collections</b>\n\nTap collections to draw from them; none means all.", id), tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+// sendScheduleTopics lists the topics a slot can draw from
+func (h *Handler) sendScheduleTopics(chatID int64, msgID int, id int, page int) {
+	slot, ok := h.findSchedule(chatID, id)
+	if !ok {
+		h.sendScheduleGone(chatID, msgID, id)
+		return
+	}
+
+	topics := h.hadithService.GetTopics()
+	totalPages := max((len(topics)+topicsPerPage-1)/topicsPerPage, 1)
+	page = min(max(page, 1), totalPages)
+	start, end := (page-1)*topicsPerPage, min(page*topicsPerPage, len(topics))
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, t := range topics[start:end] {
+		label := fmt.Sprintf("%s (%d)", truncate(t.Name, 35), t.Count)
+		if t.ID == slot.Content.Topic {
+			label = "✅ " + label
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("sched:topic:%d:%s", id, t.ID))))
+	}
+
+	var nav []tgbotapi.InlineKeyboardButton
+	if page > 1 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("⬅️ Page", fmt.Sprintf("sched:topics:%d:%d", id, page-1)))
+	}
+	if end < len(topics) {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("Page ➡️", fmt.Sprintf("sched:topics:%d:%d", id, page+1)))
+	}
+	if len(nav) > 0 {
+		rows = append(rows, nav)
+	}
+
+	anyTopic := "Any topic"
+	if slot.Content.Topic == "" {
+		anyTopic = "✅ " + anyTopic
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(anyTopic, fmt.Sprintf("sched:topic:%d:", id))),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⬅️ Back", fmt.Sprintf("sched:menu:%d", id))),
+	)
+	h.editOrSendMessage(chatID, msgID, "", fmt.Sprintf("🏷️ <b>Schedule #%d — Topic</b> — Page %d/%d", id, page, totalPages), tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+// sendScheduleBooks lists the books of a slot's collection to pick the first
+// book of its range from, or, once from is picked, the last. It returns
+// false when the slot does not draw from a single collection.
+func (h *Handler) sendScheduleBooks(chatID int64, msgID int, id int, from int, page int) bool {
+	slot, ok := h.findSchedule(chatID, id)
+	if !ok {
+		h.sendScheduleGone(chatID, msgID, id)
+		return true
+	}
+	if len(slot.Content.Collections) != 1 {
+		return false
+	}
+	col := slot.Content.Collections[0]
+
+	var books []models.Book
+	for _, b := range h.hadithService.GetBooks(col) {
+		if b.BookNumber >= from {
+			books = append(books, b)
+		}
+	}
+	totalPages := max((len(books)+scheduleBooksPerPage-1)/scheduleBooksPerPage, 1)
+	page = min(max(page, 1), totalPages)
+	start, end := (page-1)*scheduleBooksPerPage, min(page*scheduleBooksPerPage, len(books))
+
+	pageData := func(p int) string { return fmt.Sprintf("sched:books:%d:%d", id, p) }
+	bookData := func(n int) string { return fmt.Sprintf("sched:bookto:%d:%d:1", id, n) }
+	heading := "Choose the first book"
+	if from > 0 {
+		pageData = func(p int) string { return fmt.Sprintf("sched:bookto:%d:%d:%d", id, from, p) }
+		bookData = func(n int) string { return fmt.Sprintf("sched:book:%d:%d:%d", id, from, n) }
+		heading = fmt.Sprintf("Choose the last book, from book %d", from)
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, b := range books[start:end] {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(truncate(fmt.Sprintf("%d. %s", b.BookNumber, bookTitle(b)), 40), bookData(b.BookNumber))))
+	}
+
+	var nav []tgbotapi.InlineKeyboardButton
+	if page > 1 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("⬅️ Page", pageData(page-1)))
+	}
+	if end < len(books) {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("Page ➡️", pageData(page+1)))
+	}
+	if len(nav) > 0 {
+		rows = append(rows, nav)
+	}
+
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("All books", fmt.Sprintf("sched:book:%d:0:0", id))),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⬅️ Back", fmt.Sprintf("sched:menu:%d", id))),
+	)
+	h.editOrSendMessage(chatID, msgID, "", fmt.Sprintf("📖 <b>Schedule #%d — %s books</b>\n\n%s.", id, html.EscapeString(h.hadithService.GetCollectionDisplayName(col)), heading), tgbotapi.NewInlineKeyboardMarkup(rows...))
+	return true
+}
+
+// bookTitle drops the "1. " numbering some book titles start with
+func bookTitle(b models.Book) string {
+	if idx := strings.Index(b.Title, ". "); idx != -1 && idx < 5 {
+		return b.Title[idx+2:]
+	}
+	return b.Title
+}
+
+// toggleString adds value to values, or removes it if it is there
+func toggleString(values []string, value string) []string {
+	var out []string
+	found := false
+	for _, v := range values {
+		if v == value {
+			found = true
+			continue
+		}
+		out = append(out, v)
+	}
+	if !found {
+		out = append(out, value)
+	}
+	return out
+}
@@ -0,0 +1,117 @@
+package bot
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		args     string
+		tz       string // the chat's default timezone
+		expected string // Schedule.String, "" for an error
+	}{
+		{"daily 06:30 Asia/Karachi", "", "daily at 06:30 (Asia/Karachi)"},
+		{"daily 6:30", "", "daily at 06:30 (UTC)"},
+		{"Daily 06:30", "Asia/Karachi", "daily at 06:30 (Asia/Karachi)"},
+		{"thu,mon,thu 20:00", "", "Mon, Thu at 20:00 (UTC)"},
+		{"friday 13:15 Europe/London", "Asia/Karachi", "Fri at 13:15 (Europe/London)"},
+		{"sun,mon,tue,wed,thu,fri,sat 07:00", "", "daily at 07:00 (UTC)"},
+		{"every 6h", "", "every 6h0m0s"},
+		{"12h", "", "every 12h0m0s"},
+		{"", "", ""},
+		{"daily", "", ""},
+		{"every", "", ""},
+		{"every 10s", "", ""},
+		{"noon 12:00", "", ""},
+		{"daily 25:00", "", ""},
+		{"daily 06:30 Mars/Olympus", "", ""},
+		{"daily 06:30 Local", "", ""},
+		{"daily 06:30 UTC extra", "", ""},
+	}
+
+	for _, tt := range tests {
+		slot, err := ParseSchedule(tt.args, tt.tz)
+		if tt.expected == "" {
+			if err == nil {
+				t.Errorf("ParseSchedule(%q) = %s; want an error", tt.args, slot)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseSchedule(%q) returned error: %v", tt.args, err)
+		} else if got := slot.String(); got != tt.expected {
+			t.Errorf("ParseSchedule(%q) = %s; want %s", tt.args, got, tt.expected)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	karachi, err := time.LoadLocation("Asia/Karachi")
+	if err != nil {
+		t.Skipf("no timezone data: %v", err)
+	}
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skipf("no timezone data: %v", err)
+	}
+
+	tests := []struct {
+		slot     Schedule
+		last     time.Time
+		expected time.Time
+	}{
+		// Later the same day, or the next day once the time has passed
+		{Schedule{Time: "06:30", Timezone: "Asia/Karachi"}, time.Date(2026, 10, 17, 5, 0, 0, 0, karachi), time.Date(2026, 10, 17, 6, 30, 0, 0, karachi)},
+		{Schedule{Time: "06:30", Timezone: "Asia/Karachi"}, time.Date(2026, 10, 17, 6, 30, 0, 0, karachi), time.Date(2026, 10, 18, 6, 30, 0, 0, karachi)},
+		// The day follows the slot's timezone, not UTC: 20:00 UTC is already the 18th in Karachi
+		{Schedule{Time: "06:30", Timezone: "Asia/Karachi"}, time.Date(2026, 10, 17, 20, 0, 0, 0, time.UTC), time.Date(2026, 10, 18, 6, 30, 0, 0, karachi)},
+		// 2026-10-17 is a Saturday
+		{Schedule{Days: []time.Weekday{time.Monday, time.Thursday}, Time: "20:00"}, time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC), time.Date(2026, 10, 19, 20, 0, 0, 0, time.UTC)},
+		{Schedule{Days: []time.Weekday{time.Saturday}, Time: "20:00"}, time.Date(2026, 10, 17, 21, 0, 0, 0, time.UTC), time.Date(2026, 10, 24, 20, 0, 0, 0, time.UTC)},
+		// The same wall-clock time on either side of the clocks going back
+		{Schedule{Time: "08:00", Timezone: "Europe/London"}, time.Date(2026, 10, 24, 9, 0, 0, 0, london), time.Date(2026, 10, 25, 8, 0, 0, 0, london)},
+		// Interval slots count from the last one
+		{Schedule{Every: 6 * time.Hour}, time.Date(2026, 10, 17, 5, 0, 0, 0, time.UTC), time.Date(2026, 10, 17, 11, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		tt.slot.LastSentAt = tt.last
+		if got := tt.slot.Next(); !got.Equal(tt.expected) {
+			t.Errorf("%s after %v: next %v; want %v", tt.slot, tt.last, got, tt.expected)
+		}
+	}
+
+	// 08:00 in London is 07:00 UTC in summer and 08:00 UTC after
+	slot := Schedule{Time: "08:00", Timezone: "Europe/London", LastSentAt: time.Date(2026, 10, 24, 9, 0, 0, 0, london)}
+	if got := slot.Next().UTC().Hour(); got != 8 {
+		t.Errorf("08:00 London after the clocks go back is %d:00 UTC; want 8:00", got)
+	}
+}
+
+func TestScheduleContentFilter(t *testing.T) {
+	tests := []struct {
+		content  ScheduleContent
+		expected string
+	}{
+		{ScheduleContent{}, "{Collections:[] Grades:[] BookFrom:0 BookTo:0 Narrator: Topics:[]}"},
+		{ScheduleContent{Collections: []string{"bukhari"}, Topic: "prayer", BookFrom: 2, BookTo: 5}, "{Collections:[bukhari] Grades:[] BookFrom:2 BookTo:5 Narrator: Topics:[prayer]}"},
+		// A book range only applies within a single collection
+		{ScheduleContent{Collections: []string{"bukhari", "muslim"}, BookFrom: 2, BookTo: 5}, "{Collections:[bukhari muslim] Grades:[] BookFrom:0 BookTo:0 Narrator: Topics:[]}"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprintf("%+v", tt.content.Filter()); got != tt.expected {
+			t.Errorf("Filter of %+v = %s; want %s", tt.content, got, tt.expected)
+		}
+	}
+}
+
+func TestToggleString(t *testing.T) {
+	values := toggleString(nil, "bukhari")
+	values = toggleString(values, "muslim")
+	values = toggleString(values, "bukhari")
+	if got := fmt.Sprint(values); got != "[muslim]" {
+		t.Errorf("toggleString left %s; want [muslim]", got)
+	}
+}
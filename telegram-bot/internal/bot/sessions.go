@@ -0,0 +1,179 @@
+package bot
+
+import (
+	"container/list"
+	"crypto/rand"
+	"encoding/base64"
+	"sync"
+	"time"
+
+	"hadith-bot/internal/models"
+)
+
+// Search sessions last callbackSessionTTL after their last use, and at most
+// maxCallbackSessions are kept; past that the least recently used goes
+const (
+	callbackSessionTTL  = 24 * time.Hour
+	maxCallbackSessions = 50000
+)
+
+// sessionIDBytes gives IDs of 8 characters, short enough to leave most of a
+// button's 64 bytes of callback data free
+const sessionIDBytes = 6
+
+// SearchSession is a search whose results are on screen, kept on the server
+// so its buttons carry only the session's ID rather than the query
+type SearchSession struct {
+	Query  string
+	Filter models.SearchFilter // facets chosen on top of the query
+	Page   int                 // the page last shown
+	Origin int64               // chat the results were sent to; 0 for inline messages
+
+	// Choices offered by the buttons last shown; see offer
+	Collections []string
+	Grades      []string
+	Suggestion  string
+}
+
+// Choices a search session's buttons offer, in their callback data
+// sf:<session>:<choice>:<index>
+const (
+	choiceCollection = "c" // the index-th of the session's Collections
+	choiceGrade      = "g" // the index-th of its Grades
+	choiceSuggestion = "d" // its Suggestion ("did you mean")
+)
+
+// offer records the facets and spelling suggestion of a search's results,
+// which the session's buttons refer to by index rather than by name
+func (s *SearchSession) offer(res models.SearchResult) {
+	s.Collections = facetValues(res.CollectionFacets)
+	s.Grades = facetValues(res.GradeFacets)
+	s.Suggestion = res.Suggestion
+}
+
+// facetValues returns the values offered as buttons: up to maxFacetButtons,
+// and none when the results do not span more than one
+func facetValues(facets []models.Facet) []string {
+	if len(facets) < 2 {
+		return nil
+	}
+	var values []string
+	for i, f := range facets {
+		if i == maxFacetButtons {
+			break
+		}
+		values = append(values, f.Value)
+	}
+	return values
+}
+
+type sessionEntry struct {
+	id      string
+	session SearchSession
+	expires time.Time
+}
+
+// SessionStore holds search sessions under short random IDs. Sessions live
+// in memory only, so their buttons stop working after a restart.
+type SessionStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	max     int
+	entries map[string]*list.Element
+	byUse   *list.List // of *sessionEntry, most recently used first
+	now     func() time.Time
+}
+
+// NewSessionStore creates a store whose sessions expire ttl after their last
+// use, holding at most max
+func NewSessionStore(ttl time.Duration, max int) *SessionStore {
+	return &SessionStore{
+		ttl:     ttl,
+		max:     max,
+		entries: make(map[string]*list.Element),
+		byUse:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// Put stores a session and returns its new ID
+func (s *SessionStore) Put(session SearchSession) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.evict(now)
+
+	id := newSessionID()
+	for s.entries[id] != nil {
+		id = newSessionID()
+	}
+	s.entries[id] = s.byUse.PushFront(&sessionEntry{id: id, session: session, expires: now.Add(s.ttl)})
+	return id
+}
+
+// Get returns a session and extends its life, or false when it has expired
+func (s *SessionStore) Get(id string) (SearchSession, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.lookup(id)
+	if !ok {
+		return SearchSession{}, false
+	}
+	return e.session, true
+}
+
+// Update changes a session with update, unless it has expired
+func (s *SessionStore) Update(id string, update func(*SearchSession)) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.lookup(id)
+	if ok {
+		update(&e.session)
+	}
+	return ok
+}
+
+// lookup finds a live session and extends its life; the caller holds s.mu
+func (s *SessionStore) lookup(id string) (*sessionEntry, bool) {
+	elem, ok := s.entries[id]
+	if !ok {
+		return nil, false
+	}
+	e := elem.Value.(*sessionEntry)
+	now := s.now()
+	if !now.Before(e.expires) {
+		s.remove(elem)
+		return nil, false
+	}
+	e.expires = now.Add(s.ttl)
+	s.byUse.MoveToFront(elem)
+	return e, true
+}
+
+// evict drops the expired sessions and, when the store is still full, the
+// least recently used one. Every use extends a session by the same TTL, so
+// the least recently used sessions are the ones expiring first, at the back
+// of the list. The caller holds s.mu.
+func (s *SessionStore) evict(now time.Time) {
+	for elem := s.byUse.Back(); elem != nil; elem = s.byUse.Back() {
+		if now.Before(elem.Value.(*sessionEntry).expires) && s.byUse.Len() < s.max {
+			return
+		}
+		s.remove(elem)
+	}
+}
+
+// remove drops a session; the caller holds s.mu
+func (s *SessionStore) remove(elem *list.Element) {
+	delete(s.entries, elem.Value.(*sessionEntry).id)
+	s.byUse.Remove(elem)
+}
+
+func newSessionID() string {
+	b := make([]byte, sessionIDBytes)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
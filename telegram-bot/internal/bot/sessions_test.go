@@ -0,0 +1,186 @@
+package bot
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"hadith-bot/internal/logger"
+	"hadith-bot/internal/models"
+	"hadith-bot/internal/services"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestSessionStore(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	s := NewSessionStore(time.Hour, 3)
+	s.now = func() time.Time { return now }
+
+	query := strings.Repeat("narrator:abu hurayrah prayer: ", 10)
+	id := s.Put(SearchSession{Query: query, Filter: models.SearchFilter{Grades: []string{"Sahih"}}, Page: 1, Origin: 42})
+	if data := fmt.Sprintf("s:%s:%d", id, 9999); len(data) > callbackDataMaxBytes || strings.Count(data, ":") != 2 {
+		t.Errorf("callback data %q does not fit a button", data)
+	}
+
+	got, ok := s.Get(id)
+	if !ok || got.Query != query || got.Origin != 42 || got.Filter.Grades[0] != "Sahih" {
+		t.Fatalf("Get = %+v, %v", got, ok)
+	}
+	if !s.Update(id, func(session *SearchSession) { session.Page = 3 }) {
+		t.Fatal("Update of a live session returned false")
+	}
+	if got, _ := s.Get(id); got.Page != 3 {
+		t.Errorf("Page after Update = %d; want 3", got.Page)
+	}
+
+	// Use keeps a session alive; an hour without expires it
+	now = now.Add(50 * time.Minute)
+	if _, ok := s.Get(id); !ok {
+		t.Error("session expired within an hour of its last use")
+	}
+	now = now.Add(time.Hour)
+	if _, ok := s.Get(id); ok {
+		t.Error("session outlived its TTL")
+	}
+	if s.Update(id, func(*SearchSession) {}) {
+		t.Error("Update of an expired session returned true")
+	}
+	if _, ok := s.Get("unknown"); ok {
+		t.Error("Get of an unknown ID returned true")
+	}
+}
+
+func TestSessionStoreEvicts(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	s := NewSessionStore(time.Hour, 2)
+	s.now = func() time.Time { return now }
+
+	first := s.Put(SearchSession{Query: "first"})
+	now = now.Add(time.Minute)
+	second := s.Put(SearchSession{Query: "second"})
+	now = now.Add(time.Minute)
+	s.Get(first) // used last, so second is now closest to expiring
+	third := s.Put(SearchSession{Query: "third"})
+
+	if _, ok := s.Get(second); ok {
+		t.Error("the session closest to expiring was kept past the limit")
+	}
+	for _, id := range []string{first, third} {
+		if _, ok := s.Get(id); !ok {
+			t.Errorf("session %s was evicted", id)
+		}
+	}
+	if len(s.entries) != 2 {
+		t.Errorf("store holds %d sessions; want 2", len(s.entries))
+	}
+}
+
+const testSearchCollectionJSON = `{
+	"chapters": [{"id": 1, "arabic": "كتاب", "english": "Book One"}],
+	"hadiths": [
+		{"idInBook": 1, "chapterId": 1, "arabic": "نص", "english": {"narrator": "Narrated A:", "text": "Prayer at its time."}, "grade": "Sahih"},
+		{"idInBook": 2, "chapterId": 1, "arabic": "نص", "english": {"narrator": "Narrated B:", "text": "Prayer at night."}, "grade": "Hasan"}
+	]
+}`
+
+// lastKeyboard returns the buttons of the last message sent or edited
+func lastKeyboard(t *testing.T, client *fakeClient) (string, []string) {
+	t.Helper()
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	for i := len(client.sent) - 1; i >= 0; i-- {
+		var text string
+		var kb *tgbotapi.InlineKeyboardMarkup
+		switch c := client.sent[i].(type) {
+		case tgbotapi.MessageConfig:
+			text = c.Text
+			if m, ok := c.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup); ok {
+				kb = &m
+			}
+		case tgbotapi.EditMessageTextConfig:
+			text, kb = c.Text, c.ReplyMarkup
+		default:
+			continue
+		}
+		var data []string
+		if kb != nil {
+			for _, row := range kb.InlineKeyboard {
+				for _, b := range row {
+					data = append(data, *b.CallbackData)
+				}
+			}
+		}
+		return text, data
+	}
+	t.Fatal("nothing was sent")
+	return "", nil
+}
+
+func TestSearchSessionButtons(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"bukhari.json", "muslim.json"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(testSearchCollectionJSON), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	log := logger.New(io.Discard, logger.ErrorLevel, false)
+	service, err := services.NewHadithService(dir, true, "", "", time.Second, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &fakeClient{}
+	h := &Handler{
+		bot:           client,
+		hadithService: service,
+		log:           log,
+		rateLimiter:   NewRateLimiter(100, time.Minute),
+		sessions:      NewSessionStore(callbackSessionTTL, maxCallbackSessions),
+		metrics:       NewMetrics(),
+	}
+	h.router = h.newRouter()
+	press := func(data string) {
+		h.HandleUpdate(tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:      "1",
+			From:    &tgbotapi.User{ID: 1},
+			Data:    data,
+			Message: &tgbotapi.Message{MessageID: 5, Chat: privateChat},
+		}})
+	}
+
+	h.HandleUpdate(commandUpdate(privateChat, 1, "/search prayer"))
+	_, buttons := lastKeyboard(t, client)
+	var facets []string
+	for _, data := range buttons {
+		if strings.HasPrefix(data, "sf:") {
+			facets = append(facets, data)
+		}
+	}
+	// Two collections and two grades, each a button on the one session
+	if len(facets) != 4 || h.sessions.byUse.Len() != 1 {
+		t.Fatalf("buttons %q on %d sessions; want 4 facets on 1", buttons, h.sessions.byUse.Len())
+	}
+
+	press(facets[0])
+	text, buttons := lastKeyboard(t, client)
+	if !strings.Contains(text, "collection:") || h.sessions.byUse.Len() != 1 {
+		t.Errorf("after the facet: %q on %d sessions", text, h.sessions.byUse.Len())
+	}
+	for _, data := range buttons {
+		if strings.HasPrefix(data, "sf:") && strings.Contains(data, ":c:") {
+			t.Errorf("collection facet %q offered after narrowing to one collection", data)
+		}
+	}
+
+	// An index the session did not offer is refused
+	id := strings.Split(facets[0], ":")[1]
+	press("sf:" + id + ":c:9")
+	if texts := client.texts(); !strings.Contains(texts[len(texts)-1], "expired") {
+		t.Errorf("out of range facet answered %q", texts[len(texts)-1])
+	}
+}
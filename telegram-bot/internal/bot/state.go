@@ -4,27 +4,94 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 )
 
 type ChatState struct {
-	UseCustomBg      bool          `json:"use_custom_bg"`
-	UseClassicArabic bool          `json:"use_classic_arabic"`
-	ScheduleInterval time.Duration `json:"schedule_interval"`
-	LastSentAt       time.Time     `json:"last_sent_at"`
+	UseCustomBg      bool       `json:"use_custom_bg"`
+	UseClassicArabic bool       `json:"use_classic_arabic"`
+	Schedules        []Schedule `json:"schedules,omitempty"`
+	Timezone         string     `json:"timezone,omitempty"` // last timezone given to /schedule, the default for new slots
+
+	// RecentHadiths is the random hadith history of older state files; Load
+	// moves it to the recent hadiths file
+	RecentHadiths []string `json:"recent_hadiths,omitempty"`
+
+	// ScheduleInterval, LastSentAt and ScheduleTopic are the single interval
+	// schedule of older state files; Load turns them into a slot
+	ScheduleInterval time.Duration `json:"schedule_interval,omitempty"`
+	LastSentAt       *time.Time    `json:"last_sent_at,omitempty"`
+	ScheduleTopic    string        `json:"schedule_topic,omitempty"`
+}
+
+// clone copies the state, including its slices, so the copy can be changed
+// without touching the original
+func (s *ChatState) clone() *ChatState {
+	c := *s
+	c.Schedules = append([]Schedule(nil), s.Schedules...)
+	for i := range c.Schedules {
+		c.Schedules[i].Days = append([]time.Weekday(nil), s.Schedules[i].Days...)
+		c.Schedules[i].Content.Collections = append([]string(nil), s.Schedules[i].Content.Collections...)
+	}
+	return &c
+}
+
+// migrate turns the interval schedule of an older state file into a slot
+// that posts as it did: from the chat's topic, in the chat's image style
+func (s *ChatState) migrate() {
+	if s.ScheduleInterval > 0 && len(s.Schedules) == 0 {
+		slot := Schedule{ID: 1, Every: s.ScheduleInterval, Content: s.defaultContent()}
+		if s.LastSentAt != nil {
+			slot.LastSentAt = *s.LastSentAt
+		}
+		s.Schedules = []Schedule{slot}
+	}
+	for i := range s.Schedules {
+		if s.Schedules[i].Content.Topic == "" {
+			s.Schedules[i].Content.Topic = s.ScheduleTopic
+		}
+	}
+	s.ScheduleInterval = 0
+	s.LastSentAt = nil
+	s.ScheduleTopic = ""
 }
 
+// defaultContent is the content of a new slot: any hadith, as an image in
+// the chat's image style
+func (s *ChatState) defaultContent() ScheduleContent {
+	return ScheduleContent{UseCustomBg: s.UseCustomBg, UseClassicArabic: s.UseClassicArabic}
+}
+
+// recentSaveInterval is how often at most the random hadiths sent are
+// written out. Picks in between are written with the next one due, or by
+// Save; a crash loses at most this long of history, which only lets those
+// hadiths come up again sooner.
+const recentSaveInterval = time.Minute
+
+// StateManager keeps the chats' settings, saved to a file on every change,
+// and the random hadiths sent to each chat. The history changes with every
+// /random, so it is kept in a file of its own that is written at most every
+// recentSaveInterval.
 type StateManager struct {
-	filePath string
-	mu       sync.RWMutex
-	data     map[int64]*ChatState
+	filePath      string
+	recentPath    string
+	mu            sync.RWMutex
+	data          map[int64]*ChatState
+	recent        map[int64][]string // "collection:number" of random hadiths sent, oldest first
+	recentDirty   bool               // recent has changed since it was written
+	recentSavedAt time.Time
+	saveMu        sync.Mutex // one Save writes the files at a time
 }
 
 func NewStateManager(filePath string) *StateManager {
+	ext := filepath.Ext(filePath)
 	sm := &StateManager{
-		filePath: filePath,
-		data:     make(map[int64]*ChatState),
+		filePath:   filePath,
+		recentPath: strings.TrimSuffix(filePath, ext) + "_recent" + ext,
+		data:       make(map[int64]*ChatState),
+		recent:     make(map[int64][]string),
 	}
 	sm.Load()
 	return sm
@@ -34,7 +101,17 @@ func (sm *StateManager) Load() error {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	b, err := os.ReadFile(sm.filePath)
+	b, err := os.ReadFile(sm.recentPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(b, &sm.recent); err != nil {
+			return err
+		}
+	}
+
+	b, err = os.ReadFile(sm.filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil
@@ -42,24 +119,100 @@ func (sm *StateManager) Load() error {
 		return err
 	}
 
-	return json.Unmarshal(b, &sm.data)
+	if err := json.Unmarshal(b, &sm.data); err != nil {
+		return err
+	}
+	for chatID, state := range sm.data {
+		state.migrate()
+		if len(state.RecentHadiths) > 0 {
+			if len(sm.recent[chatID]) == 0 {
+				sm.recent[chatID] = state.RecentHadiths
+				sm.recentDirty = true
+			}
+			state.RecentHadiths = nil
+		}
+	}
+	return nil
 }
 
+// Save writes the state to its file, and the random hadiths sent if they
+// have changed since they were written.
 func (sm *StateManager) Save() error {
+	if err := sm.saveState(); err != nil {
+		return err
+	}
+	return sm.saveRecent()
+}
+
+func (sm *StateManager) saveState() error {
+	sm.saveMu.Lock()
+	defer sm.saveMu.Unlock()
+
 	sm.mu.RLock()
-	defer sm.mu.RUnlock()
+	b, err := json.MarshalIndent(sm.data, "", "  ")
+	sm.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(sm.filePath, b)
+}
+
+// saveRecent writes the random hadiths sent, if they have changed
+func (sm *StateManager) saveRecent() error {
+	sm.saveMu.Lock()
+	defer sm.saveMu.Unlock()
 
-	dir := filepath.Dir(sm.filePath)
+	sm.mu.Lock()
+	if !sm.recentDirty {
+		sm.mu.Unlock()
+		return nil
+	}
+	b, err := json.Marshal(sm.recent)
+	sm.recentDirty = false
+	sm.recentSavedAt = time.Now()
+	sm.mu.Unlock()
+
+	if err == nil {
+		err = writeFileAtomic(sm.recentPath, b)
+	}
+	if err != nil {
+		sm.mu.Lock()
+		sm.recentDirty = true
+		sm.mu.Unlock()
+	}
+	return err
+}
+
+// writeFileAtomic writes a temporary file and renames it over the old one, so
+// a crash or shutdown mid-write leaves the previous file rather than a
+// truncated one
+func writeFileAtomic(path string, b []byte) error {
+	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return err
 	}
 
-	b, err := json.MarshalIndent(sm.data, "", "  ")
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
 	if err != nil {
 		return err
 	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
 
-	return os.WriteFile(sm.filePath, b, 0644)
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
 }
 
 func (sm *StateManager) GetChatState(chatID int64) *ChatState {
@@ -72,10 +225,11 @@ func (sm *StateManager) GetChatState(chatID int64) *ChatState {
 	}
 
 	// Return a copy so the caller can't mutate the internal pointer without Save()
-	copyState := *state
-	return &copyState
+	return state.clone()
 }
 
+// SetChatState replaces a chat's whole state. Handlers changing part of it
+// use Update, so they do not undo changes made since they read it.
 func (sm *StateManager) SetChatState(chatID int64, state *ChatState) error {
 	sm.mu.Lock()
 	sm.data[chatID] = state
@@ -84,14 +238,133 @@ func (sm *StateManager) SetChatState(chatID int64, state *ChatState) error {
 	return sm.Save()
 }
 
+// Update changes a chat's state with update, under the lock so no other
+// change comes between reading and writing it, and saves it. A chat without
+// state starts from an empty one.
+func (sm *StateManager) Update(chatID int64, update func(*ChatState)) error {
+	sm.mu.Lock()
+	state, ok := sm.data[chatID]
+	if !ok {
+		state = &ChatState{}
+		sm.data[chatID] = state
+	}
+	update(state)
+	sm.mu.Unlock()
+
+	return sm.Save()
+}
+
+// Create stores the state of a chat that has none yet, reporting whether it
+// did
+func (sm *StateManager) Create(chatID int64, state *ChatState) (bool, error) {
+	sm.mu.Lock()
+	if _, ok := sm.data[chatID]; ok {
+		sm.mu.Unlock()
+		return false, nil
+	}
+	sm.data[chatID] = state
+	sm.mu.Unlock()
+
+	return true, sm.Save()
+}
+
 func (sm *StateManager) GetAll() map[int64]*ChatState {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
 
 	copyData := make(map[int64]*ChatState, len(sm.data))
 	for k, v := range sm.data {
-		s := *v
-		copyData[k] = &s
+		copyData[k] = v.clone()
 	}
 	return copyData
 }
+
+// RecentHadiths returns the random hadiths recently sent to a chat, oldest
+// first
+func (sm *StateManager) RecentHadiths(chatID int64) []string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	recent, ok := sm.recent[chatID]
+	if !ok {
+		return nil
+	}
+	return append([]string(nil), recent...)
+}
+
+// AddRecentHadith records a random hadith sent to a chat, keeping only the
+// last limit. It writes the history only if recentSaveInterval has passed
+// since it was last written.
+func (sm *StateManager) AddRecentHadith(chatID int64, ref string, limit int) error {
+	sm.mu.Lock()
+	recent := append(sm.recent[chatID], ref)
+	if len(recent) > limit {
+		recent = append([]string(nil), recent[len(recent)-limit:]...)
+	}
+	sm.recent[chatID] = recent
+	sm.recentDirty = true
+	due := time.Since(sm.recentSavedAt) >= recentSaveInterval
+	sm.mu.Unlock()
+
+	if !due {
+		return nil
+	}
+	return sm.saveRecent()
+}
+
+// ClearRecentHadiths forgets the random hadiths sent to a chat, writing the
+// history as AddRecentHadith does
+func (sm *StateManager) ClearRecentHadiths(chatID int64) error {
+	sm.mu.Lock()
+	if _, ok := sm.recent[chatID]; ok {
+		delete(sm.recent, chatID)
+		sm.recentDirty = true
+	}
+	due := time.Since(sm.recentSavedAt) >= recentSaveInterval
+	sm.mu.Unlock()
+
+	if !due {
+		return nil
+	}
+	return sm.saveRecent()
+}
+
+// MarkScheduleSent records that a chat's slot fired at t
+func (sm *StateManager) MarkScheduleSent(chatID int64, id int, t time.Time) error {
+	sm.mu.Lock()
+	if state, ok := sm.data[chatID]; ok {
+		for i := range state.Schedules {
+			if state.Schedules[i].ID == id {
+				state.Schedules[i].LastSentAt = t
+			}
+		}
+	}
+	sm.mu.Unlock()
+
+	return sm.Save()
+}
+
+// UpdateSchedule changes one of a chat's slots with update and saves it. It
+// returns the slot as changed, or false when the chat has no such slot.
+func (sm *StateManager) UpdateSchedule(chatID int64, id int, update func(*Schedule)) (Schedule, bool, error) {
+	sm.mu.Lock()
+	var (
+		slot  Schedule
+		found bool
+	)
+	if state, ok := sm.data[chatID]; ok {
+		for i := range state.Schedules {
+			if state.Schedules[i].ID == id {
+				update(&state.Schedules[i])
+				slot, found = state.Schedules[i], true
+				slot.Content.Collections = append([]string(nil), slot.Content.Collections...)
+			}
+		}
+	}
+	sm.mu.Unlock()
+
+	if !found {
+		return Schedule{}, false, nil
+	}
+	return slot, true, sm.Save()
+}
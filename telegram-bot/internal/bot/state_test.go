@@ -0,0 +1,196 @@
+package bot
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRecentHadiths(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	sm := NewStateManager(path)
+
+	for i := 1; i <= 5; i++ {
+		if err := sm.AddRecentHadith(42, fmt.Sprintf("bukhari:%d", i), 3); err != nil {
+			t.Fatalf("AddRecentHadith returned error: %v", err)
+		}
+	}
+	if got := fmt.Sprint(sm.RecentHadiths(42)); got != "[bukhari:3 bukhari:4 bukhari:5]" {
+		t.Errorf("RecentHadiths = %s; want the last 3", got)
+	}
+
+	// Only the first pick is written at once; the rest wait for the interval
+	// or for Save, and none of them rewrites the chats' settings
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("recording random hadiths wrote the state file: %v", err)
+	}
+	if got := fmt.Sprint(NewStateManager(path).RecentHadiths(42)); got != "[bukhari:1]" {
+		t.Errorf("RecentHadiths written before the interval = %s; want only the first", got)
+	}
+
+	// The history survives a restart once saved, as at shutdown
+	if err := sm.Save(); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	reloaded := NewStateManager(path)
+	if got := fmt.Sprint(reloaded.RecentHadiths(42)); got != "[bukhari:3 bukhari:4 bukhari:5]" {
+		t.Errorf("RecentHadiths after reload = %s", got)
+	}
+
+	// Other settings are kept when the history changes
+	reloaded.SetChatState(42, &ChatState{UseClassicArabic: true})
+	reloaded.ClearRecentHadiths(42)
+	if got := reloaded.RecentHadiths(42); len(got) != 0 {
+		t.Errorf("RecentHadiths after clearing = %v", got)
+	}
+	reloaded.Save()
+	if got := NewStateManager(path).RecentHadiths(42); len(got) != 0 {
+		t.Errorf("RecentHadiths after clearing and a restart = %v", got)
+	}
+	if !NewStateManager(path).GetChatState(42).UseClassicArabic {
+		t.Error("clearing the history lost the chat's settings")
+	}
+
+	if got := reloaded.RecentHadiths(7); got != nil {
+		t.Errorf("RecentHadiths of an unknown chat = %v", got)
+	}
+}
+
+func TestLoadMovesRecentHadiths(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	legacy := `{"42": {"use_custom_bg": true, "recent_hadiths": ["bukhari:1", "muslim:2"]}}`
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sm := NewStateManager(path)
+	if got := fmt.Sprint(sm.RecentHadiths(42)); got != "[bukhari:1 muslim:2]" {
+		t.Errorf("RecentHadiths of an older state file = %s", got)
+	}
+	if err := sm.Save(); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "recent_hadiths") {
+		t.Errorf("the state file still holds the history:\n%s", b)
+	}
+	reloaded := NewStateManager(path)
+	if got := fmt.Sprint(reloaded.RecentHadiths(42)); got != "[bukhari:1 muslim:2]" || !reloaded.GetChatState(42).UseCustomBg {
+		t.Errorf("after saving: RecentHadiths = %s, state %+v", got, reloaded.GetChatState(42))
+	}
+}
+
+func TestLoadMigratesIntervalSchedule(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	legacy := `{
+		"42": {"use_custom_bg": true, "schedule_interval": 21600000000000, "last_sent_at": "2026-10-17T06:00:00Z", "schedule_topic": "prayer"},
+		"7": {"schedule_interval": 0, "last_sent_at": "2026-10-17T06:00:00Z"}
+	}`
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sm := NewStateManager(path)
+	state := sm.GetChatState(42)
+	if !state.UseCustomBg || len(state.Schedules) != 1 || state.ScheduleInterval != 0 || state.LastSentAt != nil {
+		t.Fatalf("unexpected state after migration: %+v", state)
+	}
+	if slot := state.Schedules[0]; slot.ID != 1 || slot.Every != 6*time.Hour || !slot.Next().Equal(time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected slot: %+v", slot)
+	}
+	if content := state.Schedules[0].Content; content.Topic != "prayer" || !content.UseCustomBg || state.ScheduleTopic != "" {
+		t.Errorf("the slot does not post as the old schedule did: %+v", content)
+	}
+	if state := sm.GetChatState(7); len(state.Schedules) != 0 {
+		t.Errorf("a chat without a schedule got %+v", state.Schedules)
+	}
+
+	// Changing the copy does not change the stored state
+	state.Schedules[0].LastSentAt = time.Time{}
+	if sm.GetChatState(42).Schedules[0].LastSentAt.IsZero() {
+		t.Error("GetChatState returned a slot shared with the stored state")
+	}
+
+	if err := sm.MarkScheduleSent(42, 1, time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("MarkScheduleSent returned error: %v", err)
+	}
+	reloaded := NewStateManager(path)
+	if next := reloaded.GetChatState(42).Schedules[0].Next(); !next.Equal(time.Date(2026, 10, 17, 18, 0, 0, 0, time.UTC)) {
+		t.Errorf("next after reload = %v", next)
+	}
+}
+
+func TestSaveConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+	sm := NewStateManager(path)
+
+	var wg sync.WaitGroup
+	for i := int64(1); i <= 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := sm.SetChatState(i, &ChatState{Timezone: "UTC"}); err != nil {
+				t.Errorf("SetChatState(%d) returned error: %v", i, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The last Save holds every chat, and no temporary files are left
+	if got := len(NewStateManager(path).GetAll()); got != 20 {
+		t.Errorf("reloaded %d chats; want 20", got)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("state directory holds %d files; want only the state", len(entries))
+	}
+}
+
+func TestUpdateConcurrent(t *testing.T) {
+	sm := NewStateManager(filepath.Join(t.TempDir(), "state.json"))
+	sm.SetChatState(42, &ChatState{Schedules: []Schedule{{ID: 1, Every: time.Hour}}})
+	sent := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+	// Toggles, history and the scheduler's marks race on one chat; none may
+	// undo another
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			sm.Update(42, func(s *ChatState) { s.UseCustomBg = !s.UseCustomBg })
+		}()
+		go func() {
+			defer wg.Done()
+			sm.AddRecentHadith(42, fmt.Sprintf("bukhari:%d", i), 100)
+		}()
+		go func() {
+			defer wg.Done()
+			sm.MarkScheduleSent(42, 1, sent.Add(time.Duration(i)*time.Minute))
+		}()
+	}
+	wg.Wait()
+
+	state, recent := sm.GetChatState(42), sm.RecentHadiths(42)
+	if state.UseCustomBg || len(recent) != 20 || state.Schedules[0].LastSentAt.Before(sent) {
+		t.Errorf("lost an update: custom bg %v, %d recent hadiths, last sent %v", state.UseCustomBg, len(recent), state.Schedules[0].LastSentAt)
+	}
+
+	if created, _ := sm.Create(42, &ChatState{}); created {
+		t.Error("Create replaced an existing chat's state")
+	}
+	if created, _ := sm.Create(7, &ChatState{Timezone: "UTC"}); !created || sm.GetChatState(7).Timezone != "UTC" {
+		t.Error("Create did not store a new chat's state")
+	}
+}
@@ -0,0 +1,144 @@
+package bot
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"hadith-bot/internal/logger"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// webhookSecretHeader carries the secret token given to setWebhook on every
+// update Telegram posts
+const webhookSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// webhookMaxBodyBytes caps an update's size; real ones are a few kilobytes
+const webhookMaxBodyBytes = 1 << 20
+
+// allowedUpdates are the kinds of update HandleUpdate handles; Telegram
+// leaves the others out
+var allowedUpdates = []string{"message", "callback_query", "inline_query"}
+
+// NewWebhookHandler returns the HTTP handler Telegram posts updates to. It
+// rejects requests without the secret token, when one is set, and passes
+// each update to dispatch before answering. When dispatch returns false, as
+// while shutting down, Telegram is told to retry the update later.
+func NewWebhookHandler(secret string, dispatch func(tgbotapi.Update) bool, log *logger.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if secret != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get(webhookSecretHeader)), []byte(secret)) != 1 {
+			log.Warn("Rejected webhook request from %s: wrong secret token", r.RemoteAddr)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		var update tgbotapi.Update
+		if err := json.NewDecoder(io.LimitReader(r.Body, webhookMaxBodyBytes)).Decode(&update); err != nil {
+			log.Warn("Rejected webhook request from %s: %v", r.RemoteAddr, err)
+			http.Error(w, "invalid update", http.StatusBadRequest)
+			return
+		}
+
+		if !dispatch(update) {
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// Webhook serves updates over HTTP, as the alternative to StartListening's
+// long polling
+type Webhook struct {
+	bot    *tgbotapi.BotAPI
+	log    *logger.Logger
+	server *http.Server
+	url    string
+	secret string
+}
+
+// NewWebhook prepares a webhook server on addr for updates posted to
+// publicURL. Without a publicURL the server runs without registering with
+// Telegram, on every path, for posting recorded updates locally or when the
+// webhook is registered elsewhere. Without a secret, one is generated when
+// registering; a local server without one accepts any request.
+func (h *Handler) NewWebhook(addr, publicURL, secret string) (*Webhook, error) {
+	path := "/"
+	if publicURL != "" {
+		u, err := url.Parse(publicURL)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return nil, fmt.Errorf("webhook URL %q must be an https:// URL", publicURL)
+		}
+		if u.Path != "" {
+			path = u.Path
+		}
+		if secret == "" {
+			b := make([]byte, 32)
+			if _, err := rand.Read(b); err != nil {
+				return nil, fmt.Errorf("generating webhook secret: %w", err)
+			}
+			secret = hex.EncodeToString(b)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(path, NewWebhookHandler(secret, h.dispatcher.Dispatch, h.log))
+	return &Webhook{
+		bot:    h.api,
+		log:    h.log,
+		url:    publicURL,
+		secret: secret,
+		server: &http.Server{
+			Addr:              addr,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+	}, nil
+}
+
+// ListenAndServe registers the webhook with Telegram and serves updates
+// until Close. It returns http.ErrServerClosed after Close.
+func (wh *Webhook) ListenAndServe() error {
+	if wh.url != "" {
+		params := tgbotapi.Params{"url": wh.url, "secret_token": wh.secret}
+		if err := params.AddInterface("allowed_updates", allowedUpdates); err != nil {
+			return err
+		}
+		if _, err := wh.bot.MakeRequest("setWebhook", params); err != nil {
+			return fmt.Errorf("registering webhook: %w", err)
+		}
+		wh.log.Info("Webhook registered at %s", wh.url)
+	} else {
+		wh.log.Warn("No WEBHOOK_URL: serving updates on %s without registering with Telegram", wh.server.Addr)
+	}
+	if wh.secret == "" {
+		wh.log.Warn("No WEBHOOK_SECRET: the webhook accepts updates from anyone")
+	}
+
+	wh.log.Info("Webhook server listening on %s", wh.server.Addr)
+	return wh.server.ListenAndServe()
+}
+
+// Close unregisters the webhook, so Telegram holds updates until the next
+// start, and stops the server, letting requests in flight finish
+func (wh *Webhook) Close(ctx context.Context) error {
+	if wh.url != "" {
+		if _, err := wh.bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+			wh.log.Error("Failed to unregister webhook: %v", err)
+		}
+	}
+	return wh.server.Shutdown(ctx)
+}
@@ -0,0 +1,119 @@
+package bot
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"hadith-bot/internal/logger"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// recordedUpdate is a /start message as Telegram posts it
+const recordedUpdate = `{
+	"update_id": 10001,
+	"message": {
+		"message_id": 7,
+		"from": {"id": 42, "is_bot": false, "first_name": "Test"},
+		"chat": {"id": 42, "type": "private"},
+		"date": 1760680800,
+		"text": "/start",
+		"entities": [{"offset": 0, "length": 6, "type": "bot_command"}]
+	}
+}`
+
+func TestWebhookHandler(t *testing.T) {
+	var received []tgbotapi.Update
+	handler := NewWebhookHandler("s3cret", func(u tgbotapi.Update) bool {
+		received = append(received, u)
+		return true
+	}, logger.New(io.Discard, logger.ErrorLevel, false))
+
+	tests := []struct {
+		method   string
+		secret   string
+		body     string
+		expected int
+	}{
+		{http.MethodPost, "s3cret", recordedUpdate, http.StatusOK},
+		{http.MethodPost, "wrong", recordedUpdate, http.StatusForbidden},
+		{http.MethodPost, "", recordedUpdate, http.StatusForbidden},
+		{http.MethodGet, "s3cret", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "s3cret", `{"update_id": `, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/webhook", strings.NewReader(tt.body))
+		if tt.secret != "" {
+			req.Header.Set(webhookSecretHeader, tt.secret)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.expected {
+			t.Errorf("%s with secret %q: status %d; want %d", tt.method, tt.secret, rec.Code, tt.expected)
+		}
+	}
+
+	if len(received) != 1 {
+		t.Fatalf("dispatched %d updates; want 1", len(received))
+	}
+	if u := received[0]; u.UpdateID != 10001 || u.Message == nil || !u.Message.IsCommand() || u.Message.Command() != "start" || u.Message.Chat.ID != 42 {
+		t.Errorf("unexpected update: %+v", u)
+	}
+}
+
+func TestWebhookHandlerWithoutSecret(t *testing.T) {
+	dispatched, accepting := 0, true
+	handler := NewWebhookHandler("", func(tgbotapi.Update) bool {
+		dispatched++
+		return accepting
+	}, logger.New(io.Discard, logger.ErrorLevel, false))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(recordedUpdate)))
+	if rec.Code != http.StatusOK || dispatched != 1 {
+		t.Errorf("status %d, %d updates dispatched; want 200 and 1", rec.Code, dispatched)
+	}
+
+	// Updates that cannot be queued are left for Telegram to retry
+	accepting = false
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(recordedUpdate)))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status %d while not accepting updates; want 503", rec.Code)
+	}
+}
+
+func TestNewWebhook(t *testing.T) {
+	h := &Handler{log: logger.New(io.Discard, logger.ErrorLevel, false)}
+
+	for _, bad := range []string{"http://bot.example.com/telegram", "bot.example.com", "https://"} {
+		if _, err := h.NewWebhook(":8080", bad, ""); err == nil {
+			t.Errorf("NewWebhook accepted %q", bad)
+		}
+	}
+
+	wh, err := h.NewWebhook(":8080", "https://bot.example.com/telegram", "")
+	if err != nil {
+		t.Fatalf("NewWebhook returned error: %v", err)
+	}
+	if len(wh.secret) != 64 {
+		t.Errorf("generated secret %q; want 64 hex digits", wh.secret)
+	}
+
+	// Updates are served on the URL's path only
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/other", strings.NewReader(recordedUpdate))
+	req.Header.Set(webhookSecretHeader, wh.secret)
+	wh.server.Handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("POST to another path: status %d; want 404", rec.Code)
+	}
+
+	if wh, err := h.NewWebhook(":8080", "", ""); err != nil || wh.secret != "" {
+		t.Errorf("local webhook: secret %q, error %v; want no secret", wh.secret, err)
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -23,9 +24,30 @@ type Config struct {
 	// Logging
 	LogLevel string
 
+	// Updates handled at once, each chat's in order
+	UpdateWorkers int
+
+	// How long shutdown waits for updates and scheduled sends to finish
+	ShutdownTimeout time.Duration
+
+	// Data loading
+	StrictDataLoad bool
+
+	// Search
+	SynonymsFile string
+
+	// Random hadiths
+	RandomSeed        uint64             // 0 for an unpredictable sequence
+	CollectionWeights map[string]float64 // relative chance of each collection's hadiths
+	RandomHistorySize int                // hadiths per chat not to repeat in random picks
+	TodayTimezone     string             // IANA timezone whose days the hadith of the day follows
+
 	// Server (for webhooks)
-	ServerHost string
-	ServerPort string
+	BotMode       string // ModePolling or ModeWebhook
+	WebhookURL    string // public https URL Telegram posts updates to
+	WebhookSecret string // checked against each update's secret token header
+	ServerHost    string
+	ServerPort    string
 
 	// Image Cache Channel
 	ImageCacheChannelID int64
@@ -46,21 +68,62 @@ func Load() *Config {
 		RateLimitRequests: getEnvInt("RATE_LIMIT_REQUESTS", 10),
 		RateLimitWindow:   getEnvDuration("RATE_LIMIT_WINDOW", 1*time.Minute),
 		LogLevel:          getEnv("LOG_LEVEL", "info"),
+		UpdateWorkers:     getEnvInt("UPDATE_WORKERS", 8),
+		ShutdownTimeout:   getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+		StrictDataLoad:    getEnvBool("STRICT_DATA_LOAD", false),
+		SynonymsFile:      getEnv("SYNONYMS_FILE", ""),
+		RandomSeed:        uint64(getEnvInt("RANDOM_SEED", 0)),
+		CollectionWeights: getEnvWeights("COLLECTION_WEIGHTS"),
+		RandomHistorySize: getEnvInt("RANDOM_HISTORY_SIZE", 1000),
+		TodayTimezone:     getEnv("TODAY_TIMEZONE", "UTC"),
+		BotMode:           strings.ToLower(getEnv("BOT_MODE", ModePolling)),
+		WebhookURL:        getEnv("WEBHOOK_URL", ""),
+		WebhookSecret:     getEnv("WEBHOOK_SECRET", ""),
 		ServerHost:        getEnv("SERVER_HOST", "0.0.0.0"),
 		ServerPort:        getEnv("SERVER_PORT", "8080"),
 	}
 }
 
+// Ways of receiving updates
+const (
+	ModePolling = "polling"
+	ModeWebhook = "webhook"
+)
+
 // Validate validates the required configuration
 func (c *Config) Validate() error {
 	if c.BotToken == "" {
 		return ErrBotTokenRequired
 	}
+	if c.BotMode != ModePolling && c.BotMode != ModeWebhook {
+		return ErrInvalidBotMode
+	}
+	if c.WebhookSecret != "" && !validWebhookSecret(c.WebhookSecret) {
+		return ErrInvalidWebhookSecret
+	}
 	return nil
 }
 
+// validWebhookSecret reports whether Telegram accepts a secret token: 1 to
+// 256 letters, digits, underscores and hyphens
+func validWebhookSecret(secret string) bool {
+	if len(secret) > 256 {
+		return false
+	}
+	for _, r := range secret {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
 // Custom errors
-var ErrBotTokenRequired = &ConfigError{"TELEGRAM_BOT_TOKEN is required"}
+var (
+	ErrBotTokenRequired     = &ConfigError{"TELEGRAM_BOT_TOKEN is required"}
+	ErrInvalidBotMode       = &ConfigError{"BOT_MODE must be polling or webhook"}
+	ErrInvalidWebhookSecret = &ConfigError{"WEBHOOK_SECRET may only have letters, digits, _ and -, up to 256"}
+)
 
 type ConfigError struct {
 	message string
@@ -87,6 +150,37 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolVal, err := strconv.ParseBool(value); err == nil {
+			return boolVal
+		}
+	}
+	return defaultValue
+}
+
+// getEnvWeights parses "name=weight" pairs separated by commas, such as
+// "bukhari=2,muslim=2,darimi=0", skipping malformed pairs
+func getEnvWeights(key string) map[string]float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	weights := make(map[string]float64)
+	for _, pair := range strings.Split(value, ",") {
+		name, weight, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		w, err := strconv.ParseFloat(strings.TrimSpace(weight), 64)
+		if err != nil || w < 0 {
+			continue
+		}
+		weights[strings.ToLower(strings.TrimSpace(name))] = w
+	}
+	return weights
+}
+
 func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
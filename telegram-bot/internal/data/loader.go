@@ -2,193 +2,333 @@ package data
 
 import (
 	"encoding/json"
+	"fmt"
 	"hadith-bot/internal/models"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 )
 
-// LoadHadithData loads hadith data from JSON files
-func LoadHadithData(dataDir string) (*models.CollectionData, error) {
+// ManifestFile is the optional file in the data directory that lists which
+// collections to load and in what order. Without it every *.json file in the
+// directory is treated as a collection.
+const ManifestFile = "collections.json"
+
+// manifestEntry describes one collection in the manifest. Only File is
+// required; the other fields override what the file's own metadata provides.
+type manifestEntry struct {
+	Name        string `json:"name"`
+	File        string `json:"file"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Grade       string `json:"grade"`
+}
+
+// maxCollectionNameLen keeps collection names short enough for Telegram
+// callback data, where the longest button carries a name and four numbers
+const maxCollectionNameLen = 24
+
+// reservedFiles are JSON files in the data directory that are not collections
+var reservedFiles = map[string]bool{
+	ManifestFile: true,
+	TopicsFile:   true,
+	TodayFile:    true,
+}
+
+type manifest struct {
+	Collections []manifestEntry `json:"collections"`
+}
+
+// LoadHadithData loads hadith data from JSON files. Problems with individual
+// files and entries do not stop the load; they are recorded in the returned
+// report. An error is returned only when the manifest is unreadable or no
+// collection could be loaded at all.
+func LoadHadithData(dataDir string) (*models.CollectionData, *LoadReport, error) {
 	data := &models.CollectionData{
 		Books:   make(map[string][]models.Book),
 		Hadiths: make(map[string][]models.Hadith),
 	}
+	report := &LoadReport{DataDir: dataDir}
 
-	// Define major collections
-	collections := []models.Collection{
-		{
-			Name:        "bukhari",
-			Title:       "Sahih al-Bukhari",
-			Author:      "Imam al-Bukhari",
-			Hadiths:     0,
-			Books:       0,
-			Description: "The most authentic collection of hadith",
-			Grade:       "Sahih",
-		},
-		{
-			Name:        "muslim",
-			Title:       "Sahih Muslim",
-			Author:      "Imam Muslim",
-			Hadiths:     0,
-			Books:       0,
-			Description: "The second most authentic collection",
-			Grade:       "Sahih",
-		},
-		{
-			Name:        "abudawud",
-			Title:       "Sunan Abu Dawood",
-			Author:      "Abu Dawood",
-			Hadiths:     0,
-			Books:       0,
-			Description: "Collection of hadith focusing on jurisprudential matters",
-			Grade:       "Sahih",
-		},
-		{
-			Name:        "tirmidhi",
-			Title:       "Jami' at-Tirmidhi",
-			Author:      "Imam at-Tirmidhi",
-			Hadiths:     0,
-			Books:       0,
-			Description: "Comprehensive collection of hadith",
-			Grade:       "Sahih",
-		},
-		{
-			Name:        "nasai",
-			Title:       "Sunan an-Nasa'i",
-			Author:      "Imam an-Nasa'i",
-			Hadiths:     0,
-			Books:       0,
-			Description: "Collection of hadith on jurisprudence",
-			Grade:       "Sahih",
-		},
-		{
-			Name:        "ibnmajah",
-			Title:       "Sunan Ibn Majah",
-			Author:      "Ibn Majah",
-			Hadiths:     0,
-			Books:       0,
-			Description: "Collection of hadith on jurisprudence",
-			Grade:       "Sahih",
-		},
-		{
-			Name:        "riyadussaliheen",
-			Title:       "Riyad as-Salihin",
-			Author:      "Imam al-Nawawi",
-			Hadiths:     0,
-			Books:       0,
-			Description: "The Meadows of the Righteous",
-			Grade:       "Sahih",
-		},
+	entries, fromManifest, err := discoverCollections(dataDir)
+	if err != nil {
+		return nil, report, err
 	}
 
-	data.Collections = collections
-
-	// Load data from each collection
-	collectionFiles := []string{
-		"bukhari.json",
-		"muslim.json",
-		"abudawud.json",
-		"tirmidhi.json",
-		"nasai.json",
-		"ibnmajah.json",
-		"riyadussaliheen.json",
-	}
+	// Metadata ids give the canonical ordering (Bukhari 1, Muslim 2, ...)
+	// when there is no manifest to order the collections explicitly.
+	sortKeys := make(map[string]int)
 
-	for _, file := range collectionFiles {
-		path := filepath.Join(dataDir, file)
-		if _, err := os.Stat(path); err != nil {
+	for _, entry := range entries {
+		collectionName := entry.Name
+		if collectionName == "" {
+			collectionName = strings.TrimSuffix(entry.File, filepath.Ext(entry.File))
+		}
+		fileReport := FileReport{File: entry.File, Collection: collectionName}
+		if err := checkCollectionName(collectionName); err != nil {
+			fileReport.Err = err
+			report.Files = append(report.Files, fileReport)
 			continue
 		}
 
-		content, err := os.ReadFile(path)
+		rawData, err := readCollectionFile(filepath.Join(dataDir, entry.File))
 		if err != nil {
+			fileReport.Err = err
+			report.Files = append(report.Files, fileReport)
 			continue
 		}
 
-		// Parse JSON
-		var rawData map[string]interface{}
-		if err := json.Unmarshal(content, &rawData); err != nil {
-			continue
+		collection := collectionFromMetadata(collectionName, rawData["metadata"])
+		if entry.Title != "" {
+			collection.Title = entry.Title
 		}
-
-		// Extract collection name from filename
-		collectionName := file[:len(file)-5]
+		if entry.Description != "" {
+			collection.Description = entry.Description
+		}
+		if entry.Grade != "" {
+			collection.Grade = entry.Grade
+		}
+		sortKeys[collectionName] = getInt(rawData["id"])
 
 		// Load chapters (books)
+		chapterIDs := make(map[int]bool)
 		if chapters, ok := rawData["chapters"].([]interface{}); ok {
 			var books []models.Book
 			for _, ch := range chapters {
 				if chMap, ok := ch.(map[string]interface{}); ok {
+					// Some collections only have Arabic chapter titles
+					title := getString(chMap["english"])
+					if title == "" {
+						title = getString(chMap["arabic"])
+					}
 					book := models.Book{
 						BookNumber:   getInt(chMap["id"]),
-						Title:        getString(chMap["english"]),
+						Title:        title,
 						EnglishTitle: getString(chMap["english"]),
 						ArabicTitle:  getString(chMap["arabic"]),
 					}
 					books = append(books, book)
+					chapterIDs[book.BookNumber] = true
 				}
 			}
 			data.Books[collectionName] = books
-
-			// Update collection book count
-			for i := range data.Collections {
-				if data.Collections[i].Name == collectionName {
-					data.Collections[i].Books = len(books)
-					break
-				}
-			}
+			collection.Books = len(books)
 		}
 
 		// Load hadiths
-		if hadiths, ok := rawData["hadiths"].([]interface{}); ok {
-			var parsedHadiths []models.Hadith
-			for _, h := range hadiths {
-				if hMap, ok := h.(map[string]interface{}); ok {
-					grade := getString(hMap["grade"])
-					if grade == "" {
-						grade = "Sahih"
-					}
+		hadiths := rawData["hadiths"].([]interface{})
+		var parsedHadiths []models.Hadith
+		seen := make(map[int]bool)
+		hadithsPerChapter := make(map[int]int)
+		for i, h := range hadiths {
+			hMap, ok := h.(map[string]interface{})
+			if !ok {
+				fileReport.Issues = append(fileReport.Issues, Issue{Kind: IssueSkippedEntry, Index: i, Detail: "not an object"})
+				continue
+			}
 
-					// Get english text
-					englishText := ""
-					if eng, ok := hMap["english"]; ok {
-						if engStr, ok := eng.(string); ok {
-							englishText = engStr
-						} else if engMap, ok := eng.(map[string]interface{}); ok {
-							englishText = getString(engMap["text"])
-						}
-					}
+			hadithNumber := getInt(hMap["idInBook"])
+			if hadithNumber <= 0 {
+				fileReport.Issues = append(fileReport.Issues, Issue{Kind: IssueSkippedEntry, Index: i, Detail: "missing idInBook"})
+				continue
+			}
 
-					// Get narrator
-					narrator := ""
-					if engMap, ok := hMap["english"].(map[string]interface{}); ok {
-						narrator = getString(engMap["narrator"])
-					}
+			// Leave the grade empty when the source has none so stats and
+			// filters can tell ungraded hadiths apart; display defaults it.
+			grade := getString(hMap["grade"])
 
-					hadith := models.Hadith{
-						HadithNumber: getInt(hMap["idInBook"]),
-						Grade:        grade,
-						Arabic:       getString(hMap["arabic"]),
-						English:      englishText,
-						Narrator:     narrator,
-						ChapterID:    getInt(hMap["chapterId"]),
-					}
-					parsedHadiths = append(parsedHadiths, hadith)
+			// Get english text
+			englishText := ""
+			if eng, ok := hMap["english"]; ok {
+				if engStr, ok := eng.(string); ok {
+					englishText = engStr
+				} else if engMap, ok := eng.(map[string]interface{}); ok {
+					englishText = getString(engMap["text"])
 				}
 			}
-			data.Hadiths[collectionName] = parsedHadiths
 
-			// Update collection hadith count
-			for i := range data.Collections {
-				if data.Collections[i].Name == collectionName {
-					data.Collections[i].Hadiths = len(parsedHadiths)
+			// Get narrator
+			narrator := ""
+			if engMap, ok := hMap["english"].(map[string]interface{}); ok {
+				narrator = getString(engMap["narrator"])
+			}
+
+			hadith := models.Hadith{
+				HadithNumber: hadithNumber,
+				Grade:        grade,
+				Arabic:       getString(hMap["arabic"]),
+				English:      englishText,
+				Narrator:     narrator,
+				ChapterID:    getInt(hMap["chapterId"]),
+			}
+
+			if seen[hadith.HadithNumber] {
+				fileReport.Issues = append(fileReport.Issues, Issue{Kind: IssueDuplicateID, Index: i, HadithNumber: hadith.HadithNumber})
+			}
+			seen[hadith.HadithNumber] = true
+			if len(chapterIDs) > 0 && !chapterIDs[hadith.ChapterID] {
+				fileReport.Issues = append(fileReport.Issues, Issue{Kind: IssueOrphanChapter, Index: i, HadithNumber: hadith.HadithNumber, ChapterID: hadith.ChapterID, Detail: fmt.Sprintf("chapterId %d", hadith.ChapterID)})
+			}
+			if strings.TrimSpace(hadith.Arabic) == "" {
+				fileReport.Issues = append(fileReport.Issues, Issue{Kind: IssueEmptyArabic, Index: i, HadithNumber: hadith.HadithNumber})
+			}
+			if strings.TrimSpace(hadith.English) == "" {
+				fileReport.Issues = append(fileReport.Issues, Issue{Kind: IssueEmptyEnglish, Index: i, HadithNumber: hadith.HadithNumber})
+			}
+			for _, text := range []string{hadith.Arabic, hadith.English, hadith.Narrator} {
+				if problem := encodingProblem(text); problem != "" {
+					fileReport.Issues = append(fileReport.Issues, Issue{Kind: IssueEncoding, Index: i, HadithNumber: hadith.HadithNumber, Detail: problem})
 					break
 				}
 			}
+			hadithsPerChapter[hadith.ChapterID]++
+
+			parsedHadiths = append(parsedHadiths, hadith)
+		}
+		data.Hadiths[collectionName] = parsedHadiths
+		collection.Hadiths = len(parsedHadiths)
+
+		for i, book := range data.Books[collectionName] {
+			if hadithsPerChapter[book.BookNumber] == 0 {
+				fileReport.Issues = append(fileReport.Issues, Issue{Kind: IssueEmptyChapter, Index: i, ChapterID: book.BookNumber, Detail: fmt.Sprintf("chapter %d %q", book.BookNumber, book.Title)})
+			}
+		}
+
+		fileReport.Books = collection.Books
+		fileReport.Hadiths = collection.Hadiths
+		report.Files = append(report.Files, fileReport)
+		data.Collections = append(data.Collections, collection)
+	}
+
+	if !fromManifest {
+		sort.SliceStable(data.Collections, func(i, j int) bool {
+			ki, kj := sortKeys[data.Collections[i].Name], sortKeys[data.Collections[j].Name]
+			if ki != kj {
+				// Files without an id sort after the numbered ones
+				if ki == 0 || kj == 0 {
+					return kj == 0
+				}
+				return ki < kj
+			}
+			return data.Collections[i].Name < data.Collections[j].Name
+		})
+	}
+
+	if len(data.Collections) == 0 {
+		return nil, report, fmt.Errorf("no hadith collections could be loaded from %s", dataDir)
+	}
+
+	return data, report, nil
+}
+
+// checkCollectionName makes sure a collection's name can be used in search
+// filters and in callback data, which joins its parts with colons
+func checkCollectionName(name string) error {
+	if name == "" || strings.ContainsAny(name, " :,\"") {
+		return fmt.Errorf("collection name %q must be a single word without ':' or ','", name)
+	}
+	if len(name) > maxCollectionNameLen {
+		return fmt.Errorf("collection name %q is longer than %d bytes", name, maxCollectionNameLen)
+	}
+	return nil
+}
+
+// readCollectionFile reads and parses one collection file, making sure it has
+// the hadiths array every collection needs
+func readCollectionFile(path string) (map[string]interface{}, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse JSON
+	var rawData map[string]interface{}
+	if err := json.Unmarshal(content, &rawData); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", filepath.Base(path), err)
+	}
+
+	if _, ok := rawData["hadiths"].([]interface{}); !ok {
+		return nil, fmt.Errorf("%s has no hadiths array", filepath.Base(path))
+	}
+
+	return rawData, nil
+}
+
+// discoverCollections returns the collection files to load from dataDir,
+// reading the manifest when present and globbing *.json otherwise. The
+// boolean reports whether the order came from the manifest.
+func discoverCollections(dataDir string) ([]manifestEntry, bool, error) {
+	content, err := os.ReadFile(filepath.Join(dataDir, ManifestFile))
+	if err == nil {
+		var m manifest
+		if err := json.Unmarshal(content, &m); err != nil {
+			return nil, false, fmt.Errorf("parse %s: %w", ManifestFile, err)
+		}
+		var entries []manifestEntry
+		for _, e := range m.Collections {
+			if e.File == "" && e.Name != "" {
+				e.File = e.Name + ".json"
+			}
+			if e.File != "" {
+				entries = append(entries, e)
+			}
+		}
+		return entries, true, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, false, fmt.Errorf("read %s: %w", ManifestFile, err)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dataDir, "*.json"))
+	if err != nil {
+		return nil, false, err
+	}
+	sort.Strings(matches)
+
+	var entries []manifestEntry
+	for _, match := range matches {
+		name := filepath.Base(match)
+		if reservedFiles[name] {
+			continue
 		}
+		entries = append(entries, manifestEntry{File: name})
+	}
+	return entries, false, nil
+}
+
+// collectionFromMetadata builds a Collection from a file's metadata block,
+// which carries the english/arabic title, author and introduction.
+func collectionFromMetadata(name string, raw interface{}) models.Collection {
+	collection := models.Collection{
+		Name:  name,
+		Title: name,
+	}
+
+	meta, ok := raw.(map[string]interface{})
+	if !ok {
+		return collection
 	}
 
-	return data, nil
+	collection.Hadiths = getInt(meta["length"])
+	if eng, ok := meta["english"].(map[string]interface{}); ok {
+		if title := getString(eng["title"]); title != "" {
+			collection.Title = title
+		}
+		collection.Author = getString(eng["author"])
+		collection.Description = getString(eng["introduction"])
+	}
+	if ar, ok := meta["arabic"].(map[string]interface{}); ok {
+		collection.ArabicTitle = getString(ar["title"])
+		collection.ArabicAuthor = getString(ar["author"])
+		collection.ArabicDescription = getString(ar["introduction"])
+	}
+
+	return collection
 }
 
 // Helper functions
@@ -292,4 +432,3 @@ func GetDefaultCollectionData() *models.CollectionData {
 		Hadiths: make(map[string][]models.Hadith),
 	}
 }
-
@@ -0,0 +1,152 @@
+package data
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testCollectionJSON = `{
+	"id": %d,
+	"metadata": {
+		"id": %d,
+		"length": 2,
+		"arabic": {"title": "عنوان", "author": "مؤلف", "introduction": ""},
+		"english": {"title": "%s", "author": "Some Imam", "introduction": "Intro"}
+	},
+	"chapters": [{"id": 1, "arabic": "كتاب", "english": "Book One"}],
+	"hadiths": [
+		{"idInBook": 1, "chapterId": 1, "arabic": "نص", "english": {"narrator": "Narrated A:", "text": "Text one"}},
+		{"idInBook": 2, "chapterId": 1, "arabic": "نص", "english": {"narrator": "Narrated B:", "text": "Text two"}}
+	]
+}`
+
+func writeTestFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadHadithDataDiscoversFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "zeta.json", fmt.Sprintf(testCollectionJSON, 1, 1, "Zeta Collection"))
+	writeTestFile(t, dir, "alpha.json", fmt.Sprintf(testCollectionJSON, 2, 2, "Alpha Collection"))
+
+	d, _, err := LoadHadithData(dir)
+	if err != nil {
+		t.Fatalf("LoadHadithData returned error: %v", err)
+	}
+
+	if len(d.Collections) != 2 {
+		t.Fatalf("expected 2 collections, got %d", len(d.Collections))
+	}
+	// Ordered by metadata id, not by filename
+	if d.Collections[0].Name != "zeta" || d.Collections[1].Name != "alpha" {
+		t.Errorf("unexpected order: %s, %s", d.Collections[0].Name, d.Collections[1].Name)
+	}
+
+	c := d.GetCollection("alpha")
+	if c.Title != "Alpha Collection" || c.Author != "Some Imam" || c.ArabicTitle != "عنوان" {
+		t.Errorf("metadata not applied: %+v", c)
+	}
+	if c.Hadiths != 2 || c.Books != 1 {
+		t.Errorf("expected 2 hadiths and 1 book, got %d and %d", c.Hadiths, c.Books)
+	}
+}
+
+func TestLoadHadithDataManifest(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "one.json", fmt.Sprintf(testCollectionJSON, 1, 1, "One"))
+	writeTestFile(t, dir, "two.json", fmt.Sprintf(testCollectionJSON, 2, 2, "Two"))
+	writeTestFile(t, dir, ManifestFile, `{"collections": [
+		{"file": "two.json", "grade": "Hasan"},
+		{"name": "first", "file": "one.json", "title": "First Title"}
+	]}`)
+
+	d, _, err := LoadHadithData(dir)
+	if err != nil {
+		t.Fatalf("LoadHadithData returned error: %v", err)
+	}
+
+	if len(d.Collections) != 2 {
+		t.Fatalf("expected 2 collections, got %d", len(d.Collections))
+	}
+	if d.Collections[0].Name != "two" || d.Collections[0].Grade != "Hasan" {
+		t.Errorf("unexpected first collection: %+v", d.Collections[0])
+	}
+	if d.Collections[1].Name != "first" || d.Collections[1].Title != "First Title" {
+		t.Errorf("unexpected second collection: %+v", d.Collections[1])
+	}
+	if len(d.Hadiths["first"]) != 2 {
+		t.Errorf("expected hadiths under manifest name, got %d", len(d.Hadiths["first"]))
+	}
+}
+
+func TestLoadHadithDataReport(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "good.json", `{
+		"chapters": [{"id": 1, "arabic": "كتاب", "english": "Book One"}],
+		"hadiths": [
+			{"idInBook": 1, "chapterId": 1, "arabic": "نص", "english": {"narrator": "", "text": "One"}},
+			{"idInBook": 1, "chapterId": 1, "arabic": "نص", "english": {"narrator": "", "text": "Again"}},
+			{"idInBook": 2, "chapterId": 9, "arabic": "", "english": {"narrator": "", "text": ""}},
+			"garbage",
+			{"chapterId": 1, "arabic": "نص"}
+		]
+	}`)
+	writeTestFile(t, dir, "broken.json", "404: Not Found")
+
+	d, report, err := LoadHadithData(dir)
+	if err != nil {
+		t.Fatalf("LoadHadithData returned error: %v", err)
+	}
+	if len(d.Hadiths["good"]) != 3 {
+		t.Errorf("expected 3 hadiths kept, got %d", len(d.Hadiths["good"]))
+	}
+
+	if !report.HasErrors() {
+		t.Error("expected the broken file to be reported as an error")
+	}
+
+	counts := map[IssueKind]int{
+		IssueSkippedEntry:  2,
+		IssueDuplicateID:   1,
+		IssueOrphanChapter: 1,
+		IssueEmptyArabic:   1,
+		IssueEmptyEnglish:  1,
+	}
+	for kind, want := range counts {
+		if got := report.Count(kind); got != want {
+			t.Errorf("Count(%s) = %d; want %d", kind, got, want)
+		}
+	}
+}
+
+func TestLoadHadithDataCollectionNames(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "good.json", fmt.Sprintf(testCollectionJSON, 1, 1, "Good"))
+	writeTestFile(t, dir, "sahih:bukhari.json", fmt.Sprintf(testCollectionJSON, 2, 2, "Colon"))
+	writeTestFile(t, dir, "a_collection_name_far_too_long_for_buttons.json", fmt.Sprintf(testCollectionJSON, 3, 3, "Long"))
+
+	d, report, err := LoadHadithData(dir)
+	if err != nil {
+		t.Fatalf("LoadHadithData returned error: %v", err)
+	}
+	if len(d.Collections) != 1 || d.Collections[0].Name != "good" {
+		t.Errorf("loaded %+v; want only good", d.Collections)
+	}
+	if report.ErrorCount() != 2 {
+		t.Errorf("ErrorCount() = %d; want 2", report.ErrorCount())
+	}
+}
+
+func TestLoadHadithDataNothingLoaded(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "broken.json", "404: Not Found")
+
+	if _, _, err := LoadHadithData(dir); err == nil {
+		t.Error("expected an error when no collection loads")
+	}
+}
@@ -0,0 +1,179 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// IssueKind classifies a problem found in an otherwise readable collection file
+type IssueKind string
+
+const (
+	IssueSkippedEntry  IssueKind = "skipped entry"
+	IssueDuplicateID   IssueKind = "duplicate idInBook"
+	IssueOrphanChapter IssueKind = "unknown chapterId"
+	IssueEmptyArabic   IssueKind = "empty Arabic"
+	IssueEmptyEnglish  IssueKind = "empty English"
+	IssueEmptyChapter  IssueKind = "chapter without hadiths"
+	IssueEncoding      IssueKind = "encoding problem"
+)
+
+// issueKinds lists the kinds in the order they are reported
+var issueKinds = []IssueKind{
+	IssueSkippedEntry,
+	IssueDuplicateID,
+	IssueOrphanChapter,
+	IssueEmptyArabic,
+	IssueEmptyEnglish,
+	IssueEmptyChapter,
+	IssueEncoding,
+}
+
+// Issue is a single problem with one hadith or chapter entry
+type Issue struct {
+	Kind         IssueKind
+	Index        int // position in the file's hadiths (or chapters) array
+	HadithNumber int
+	ChapterID    int
+	Detail       string
+}
+
+func (i Issue) String() string {
+	s := fmt.Sprintf("%s at entry %d", i.Kind, i.Index)
+	if i.HadithNumber > 0 {
+		s += fmt.Sprintf(" (hadith #%d)", i.HadithNumber)
+	}
+	if i.Detail != "" {
+		s += ": " + i.Detail
+	}
+	return s
+}
+
+// FileReport describes how one collection file loaded
+type FileReport struct {
+	File       string
+	Collection string
+	Err        error // set when the file could not be stat'ed, read or parsed
+	Books      int
+	Hadiths    int
+	Issues     []Issue
+}
+
+// Count returns how many issues of the given kind the file has
+func (f *FileReport) Count(kind IssueKind) int {
+	n := 0
+	for _, issue := range f.Issues {
+		if issue.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// LoadReport collects the outcome of LoadHadithData for every file it tried
+type LoadReport struct {
+	DataDir string
+	Files   []FileReport
+}
+
+// HasErrors reports whether any file failed to load
+func (r *LoadReport) HasErrors() bool {
+	return r.ErrorCount() > 0
+}
+
+// ErrorCount returns the number of files that failed to load
+func (r *LoadReport) ErrorCount() int {
+	n := 0
+	for _, f := range r.Files {
+		if f.Err != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// IssueCount returns the total number of issues across all files
+func (r *LoadReport) IssueCount() int {
+	n := 0
+	for _, f := range r.Files {
+		n += len(f.Issues)
+	}
+	return n
+}
+
+// Count returns how many issues of the given kind were found across all files
+func (r *LoadReport) Count(kind IssueKind) int {
+	n := 0
+	for i := range r.Files {
+		n += r.Files[i].Count(kind)
+	}
+	return n
+}
+
+// Summary renders the report as plain text, one line per file, listing up to
+// maxExamples issues under each file
+func (r *LoadReport) Summary(maxExamples int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Data directory: %s\n", r.DataDir)
+	if len(r.Files) == 0 {
+		b.WriteString("No collection files found\n")
+	}
+
+	for i := range r.Files {
+		b.WriteString(r.Files[i].SummaryLine())
+		b.WriteString("\n")
+		for j, issue := range r.Files[i].Issues {
+			if j >= maxExamples {
+				fmt.Fprintf(&b, "  ... and %d more\n", len(r.Files[i].Issues)-maxExamples)
+				break
+			}
+			fmt.Fprintf(&b, "  - %s\n", issue)
+		}
+	}
+	return b.String()
+}
+
+// SummaryLine renders the file's outcome and issue counts on one line
+func (f *FileReport) SummaryLine() string {
+	if f.Err != nil {
+		return fmt.Sprintf("%s: ERROR %v", f.File, f.Err)
+	}
+
+	line := fmt.Sprintf("%s (%s): %d books, %d hadiths", f.File, f.Collection, f.Books, f.Hadiths)
+	for _, kind := range issueKinds {
+		if n := f.Count(kind); n > 0 {
+			line += fmt.Sprintf(", %d %s", n, kind)
+		}
+	}
+	return line
+}
+
+// encodingProblem describes why text looks mis-encoded, or returns "" when it
+// looks fine. It catches invalid UTF-8, replacement characters left by an
+// earlier bad conversion, stray control characters and UTF-8 that was decoded
+// as Latin-1 somewhere upstream.
+func encodingProblem(text string) string {
+	if !utf8.ValidString(text) {
+		return "invalid UTF-8"
+	}
+	if strings.ContainsRune(text, utf8.RuneError) {
+		return "contains U+FFFD replacement character"
+	}
+	for _, r := range text {
+		if unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t' {
+			return fmt.Sprintf("contains control character %U", r)
+		}
+	}
+	for _, seq := range mojibakeSequences {
+		if strings.Contains(text, seq) {
+			return fmt.Sprintf("looks double-encoded (%q)", seq)
+		}
+	}
+	return ""
+}
+
+// mojibakeSequences are what common UTF-8 punctuation and Arabic letters turn
+// into when UTF-8 bytes are read as Latin-1 or Windows-1252
+var mojibakeSequences = []string{"â€", "Ã©", "Ã¨", "Ã¢", "Ø§", "Ù„"}
@@ -0,0 +1,61 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// TodayFile is the optional file in the data directory that configures the
+// hadith of the day: which hadiths it is drawn from, and hadiths pinned to
+// particular dates.
+const TodayFile = "today.json"
+
+// TodayDateLayout is the layout of the dates in TodayFile
+const TodayDateLayout = "2006-01-02"
+
+// Today configures the hadith of the day. The pool is the Hadiths listed,
+// if any; otherwise the hadiths the filter allows, or every hadith when
+// there is no filter.
+type Today struct {
+	// Hadiths is a curated pool of references, such as "bukhari 1"
+	Hadiths []string `json:"hadiths,omitempty"`
+
+	// Collections, Grades and Topics filter the pool when Hadiths is empty,
+	// as the collection:, grade: and topic: search filters do
+	Collections []string `json:"collections,omitempty"`
+	Grades      []string `json:"grades,omitempty"`
+	Topics      []string `json:"topics,omitempty"`
+
+	// Dates pins a reference to a date ("2026-03-01": "muslim 1151"),
+	// overriding the pick for that day
+	Dates map[string]string `json:"dates,omitempty"`
+}
+
+// LoadToday reads the hadith of the day settings from the data directory,
+// or returns empty settings when there is no file
+func LoadToday(dataDir string) (*Today, error) {
+	raw, err := os.ReadFile(filepath.Join(dataDir, TodayFile))
+	if os.IsNotExist(err) {
+		return &Today{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", TodayFile, err)
+	}
+
+	var t Today
+	if err := json.Unmarshal(raw, &t); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", TodayFile, err)
+	}
+	for date, ref := range t.Dates {
+		if _, err := time.Parse(TodayDateLayout, date); err != nil {
+			return nil, fmt.Errorf("%s: date %q is not YYYY-MM-DD", TodayFile, date)
+		}
+		if ref == "" {
+			return nil, fmt.Errorf("%s: date %s has no hadith", TodayFile, date)
+		}
+	}
+	return &t, nil
+}
@@ -0,0 +1,40 @@
+package data
+
+import "testing"
+
+func TestLoadToday(t *testing.T) {
+	dir := t.TempDir()
+	today, err := LoadToday(dir)
+	if err != nil {
+		t.Fatalf("LoadToday without a file returned error: %v", err)
+	}
+	if len(today.Hadiths) != 0 || len(today.Collections) != 0 || len(today.Dates) != 0 {
+		t.Errorf("expected empty settings, got %+v", today)
+	}
+
+	writeTestFile(t, dir, TodayFile, `{
+		"collections": ["bukhari"],
+		"topics": ["fasting"],
+		"dates": {"2027-02-08": "bukhari 1899"}
+	}`)
+	today, err = LoadToday(dir)
+	if err != nil {
+		t.Fatalf("LoadToday returned error: %v", err)
+	}
+	if len(today.Collections) != 1 || len(today.Topics) != 1 || today.Dates["2027-02-08"] != "bukhari 1899" {
+		t.Errorf("unexpected settings: %+v", today)
+	}
+
+	invalid := []string{
+		`{"dates": {"8 February 2027": "bukhari 1"}}`,
+		`{"dates": {"2027-02-30": "bukhari 1"}}`,
+		`{"dates": {"2027-02-08": ""}}`,
+		`{"hadiths": "bukhari 1"}`,
+	}
+	for _, content := range invalid {
+		writeTestFile(t, dir, TodayFile, content)
+		if _, err := LoadToday(dir); err == nil {
+			t.Errorf("LoadToday accepted %s", content)
+		}
+	}
+}
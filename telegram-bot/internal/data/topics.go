@@ -0,0 +1,220 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"hadith-bot/internal/models"
+	"hadith-bot/internal/search"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// TopicsFile is the optional file in the data directory that defines the
+// topic taxonomy and tags hadiths with topics. Without it the built-in
+// taxonomy is used, assigned from chapter titles only.
+const TopicsFile = "topics.json"
+
+// maxTopicIDLen keeps topic IDs short enough for Telegram callback data
+const maxTopicIDLen = 32
+
+// TopicDef is one topic of the taxonomy. Keywords are the rules for
+// auto-tagging: a hadith gets the topic when its chapter title, English or
+// Arabic, contains all the words of one of the keywords.
+type TopicDef struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Keywords []string `json:"keywords,omitempty"`
+}
+
+// Topics is the topic taxonomy and the hadiths tagged with each topic
+type Topics struct {
+	Topics []TopicDef `json:"topics"`
+
+	// Tags maps collection name, then hadith number, to topic IDs
+	Tags map[string]map[string][]string `json:"tags,omitempty"`
+
+	// AutoTag applies the keyword rules to chapter titles; on unless set
+	// to false
+	AutoTag *bool `json:"autoTag,omitempty"`
+}
+
+// defaultTopics is the built-in taxonomy. Its keywords cover the chapter
+// titles of the common collections in English and Arabic. Faith has no
+// Arabic "الإيمان": without hamza it is the "الأيمان" (oaths) of "كتاب النذور
+// والأيمان".
+var defaultTopics = []TopicDef{
+	{ID: "faith", Name: "Faith", Keywords: []string{"faith", "belief", "iman", "التوحيد", "العقيدة"}},
+	{ID: "knowledge", Name: "Knowledge", Keywords: []string{"knowledge", "العلم"}},
+	{ID: "purification", Name: "Purification", Keywords: []string{"purification", "ablution", "wudu", "ghusl", "menstruation", "tayammum", "الطهارة", "الوضوء", "الغسل", "الحيض", "التيمم"}},
+	{ID: "prayer", Name: "Prayer", Keywords: []string{"prayer", "prayers", "salat", "mosques", "adhan", "call to prayer", "friday", "witr", "الصلاة", "المساجد", "الأذان", "الجمعة", "الوتر"}},
+	{ID: "zakat", Name: "Zakat & Charity", Keywords: []string{"zakat", "charity", "alms", "الزكاة", "الصدقة"}},
+	{ID: "fasting", Name: "Fasting", Keywords: []string{"fasting", "fast", "ramadan", "itikaf", "الصوم", "الصيام", "الاعتكاف"}},
+	{ID: "hajj", Name: "Hajj & Umrah", Keywords: []string{"hajj", "pilgrimage", "umrah", "rites", "المناسك", "الحج", "العمرة"}},
+	{ID: "food", Name: "Food, Drink & Sacrifice", Keywords: []string{"food", "foods", "drinks", "hunting", "slaughtering", "sacrifice", "sacrifices", "الأطعمة", "الأشربة", "الصيد", "الذبائح", "الأضاحي"}},
+	{ID: "family", Name: "Marriage & Family", Keywords: []string{"marriage", "wedlock", "divorce", "nursing", "suckling", "النكاح", "الطلاق", "الرضاع"}},
+	{ID: "trade", Name: "Trade & Transactions", Keywords: []string{"sales", "trade", "business", "loans", "debts", "البيوع", "التجارات", "الرهن"}},
+	{ID: "law", Name: "Law & Judgments", Keywords: []string{"punishments", "blood money", "judgments", "oaths", "vows", "الحدود", "الديات", "الأحكام", "الأقضية", "النذور"}},
+	{ID: "inheritance", Name: "Inheritance & Wills", Keywords: []string{"inheritance", "wills", "الفرائض", "الوصايا"}},
+	{ID: "jihad", Name: "Jihad & Expeditions", Keywords: []string{"jihad", "expeditions", "military", "الجهاد", "السير", "المغازي"}},
+	{ID: "manners", Name: "Manners", Keywords: []string{"manners", "etiquette", "good manners", "asking permission", "greetings", "الأدب", "الاستئذان", "البر والصلة"}},
+	{ID: "heart", Name: "Softening of Hearts", Keywords: []string{"heart-melting", "softening", "asceticism", "zuhd", "الرقاق", "الزهد"}},
+	{ID: "dreams", Name: "Dreams", Keywords: []string{"dreams", "interpretation of dreams", "الرؤيا", "التعبير"}},
+	{ID: "quran", Name: "The Qur'an", Keywords: []string{"quran", "tafsir", "commentary", "القرآن", "التفسير"}},
+	{ID: "supplication", Name: "Supplication & Remembrance", Keywords: []string{"supplication", "supplications", "invocations", "remembrance", "الدعاء", "الدعوات", "الذكر"}},
+	{ID: "medicine", Name: "Medicine", Keywords: []string{"medicine", "الطب"}},
+	{ID: "virtues", Name: "Virtues & Merits", Keywords: []string{"virtues", "merits", "companions", "المناقب", "الفضائل"}},
+}
+
+// DefaultTopics returns the built-in taxonomy
+func DefaultTopics() *Topics {
+	return &Topics{Topics: defaultTopics}
+}
+
+// LoadTopics reads the topics file from the data directory, or returns the
+// built-in taxonomy when there is none
+func LoadTopics(dataDir string) (*Topics, error) {
+	raw, err := os.ReadFile(filepath.Join(dataDir, TopicsFile))
+	if os.IsNotExist(err) {
+		return DefaultTopics(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", TopicsFile, err)
+	}
+
+	var t Topics
+	if err := json.Unmarshal(raw, &t); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", TopicsFile, err)
+	}
+	if err := t.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", TopicsFile, err)
+	}
+	return &t, nil
+}
+
+// validate checks that topic IDs are unique and usable in commands and that
+// every tag names a defined topic
+func (t *Topics) validate() error {
+	ids := make(map[string]bool)
+	for i, topic := range t.Topics {
+		if topic.ID == "" || strings.ContainsAny(topic.ID, " :,\"") {
+			return fmt.Errorf("topic %d: id %q must be a single word without ':' or ','", i, topic.ID)
+		}
+		if len(topic.ID) > maxTopicIDLen {
+			return fmt.Errorf("topic %d: id %q is longer than %d bytes", i, topic.ID, maxTopicIDLen)
+		}
+		if ids[topic.ID] {
+			return fmt.Errorf("topic id %q is defined twice", topic.ID)
+		}
+		ids[topic.ID] = true
+	}
+
+	for collection, tags := range t.Tags {
+		for number, topics := range tags {
+			if _, err := strconv.Atoi(number); err != nil {
+				return fmt.Errorf("tags for %s: %q is not a hadith number", collection, number)
+			}
+			for _, id := range topics {
+				if !ids[id] {
+					return fmt.Errorf("tags for %s %s: unknown topic %q", collection, number, id)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ApplyTopics sets the Topics of every hadith from the tags and, unless
+// turned off, from the keyword rules matched against its chapter title. It
+// returns the taxonomy with hadith counts, in the order the topics are
+// defined.
+func ApplyTopics(data *models.CollectionData, t *Topics) []models.Topic {
+	autoTag := t.AutoTag == nil || *t.AutoTag
+	counts := make(map[string]int)
+
+	for _, c := range data.Collections {
+		// Topics of each book, from its titles; hadiths refer to their book
+		// by number in ChapterID
+		bookTopics := make(map[int][]string)
+		if autoTag {
+			for _, b := range data.Books[c.Name] {
+				bookTopics[b.BookNumber] = t.matchTitle(b.EnglishTitle + " " + b.ArabicTitle + " " + b.Title)
+			}
+		}
+
+		tags := t.Tags[c.Name]
+		hadiths := data.Hadiths[c.Name]
+		for i := range hadiths {
+			h := &hadiths[i]
+			h.Topics = nil
+			for _, id := range bookTopics[h.ChapterID] {
+				h.Topics = appendUnique(h.Topics, id)
+			}
+			for _, id := range tags[strconv.Itoa(h.HadithNumber)] {
+				h.Topics = appendUnique(h.Topics, id)
+			}
+			for _, id := range h.Topics {
+				counts[id]++
+			}
+		}
+	}
+
+	topics := make([]models.Topic, 0, len(t.Topics))
+	for _, def := range t.Topics {
+		topics = append(topics, models.Topic{ID: def.ID, Name: def.Name, Count: counts[def.ID]})
+	}
+	return topics
+}
+
+// matchTitle returns the IDs of the topics whose keyword rules match a title
+func (t *Topics) matchTitle(title string) []string {
+	titleTokens := search.Tokenize(title)
+	if len(titleTokens) == 0 {
+		return nil
+	}
+
+	var ids []string
+	for _, topic := range t.Topics {
+		for _, keyword := range topic.Keywords {
+			if containsAllTokens(titleTokens, search.Tokenize(keyword)) {
+				ids = append(ids, topic.ID)
+				break
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// containsAllTokens reports whether every keyword token is in the title,
+// exactly or, for keywords of three letters or more, as a prefix of a word
+func containsAllTokens(title, keyword []string) bool {
+	if len(keyword) == 0 {
+		return false
+	}
+	for _, k := range keyword {
+		found := false
+		for _, w := range title {
+			if w == k || (utf8.RuneCountInString(k) >= 3 && strings.HasPrefix(w, k)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, item := range list {
+		if item == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,131 @@
+package data
+
+import (
+	"fmt"
+	"testing"
+
+	"hadith-bot/internal/models"
+)
+
+func newTopicsTestData() *models.CollectionData {
+	return &models.CollectionData{
+		Collections: []models.Collection{{Name: "bukhari"}, {Name: "darimi"}},
+		Books: map[string][]models.Book{
+			"bukhari": {
+				{BookNumber: 1, EnglishTitle: "Revelation"},
+				{BookNumber: 2, EnglishTitle: "Times of the Prayers"},
+				{BookNumber: 3, EnglishTitle: "Fasting"},
+			},
+			"darimi": {
+				{BookNumber: 1, ArabicTitle: "كِتَاب الطَّهَارَةِ"},
+				{BookNumber: 2, ArabicTitle: "وَمِنْ كِتَابِ النُّذُورِ وَالْأَيْمَانِ"},
+			},
+		},
+		Hadiths: map[string][]models.Hadith{
+			"bukhari": {
+				{HadithNumber: 1, ChapterID: 1},
+				{HadithNumber: 2, ChapterID: 2},
+				{HadithNumber: 3, ChapterID: 3},
+			},
+			"darimi": {
+				{HadithNumber: 1, ChapterID: 1},
+				{HadithNumber: 2, ChapterID: 2},
+			},
+		},
+	}
+}
+
+func TestApplyTopics(t *testing.T) {
+	d := newTopicsTestData()
+	topics := DefaultTopics()
+	topics.Tags = map[string]map[string][]string{
+		"bukhari": {"1": {"faith"}, "2": {"prayer"}},
+	}
+
+	counts := make(map[string]int)
+	for _, topic := range ApplyTopics(d, topics) {
+		counts[topic.ID] = topic.Count
+	}
+
+	tests := []struct {
+		collection string
+		offset     int
+		expected   string
+	}{
+		{"bukhari", 0, "[faith]"},
+		{"bukhari", 1, "[prayer]"}, // tagged and matched once
+		{"bukhari", 2, "[fasting]"},
+		{"darimi", 0, "[purification]"},
+		{"darimi", 1, "[law]"}, // "الأيمان" is oaths, not faith
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(d.Hadiths[tt.collection][tt.offset].Topics); got != tt.expected {
+			t.Errorf("%s hadith %d topics = %s; want %s", tt.collection, tt.offset+1, got, tt.expected)
+		}
+	}
+	if counts["prayer"] != 1 || counts["faith"] != 1 || counts["zakat"] != 0 {
+		t.Errorf("unexpected counts: %v", counts)
+	}
+
+	off := false
+	topics.AutoTag = &off
+	ApplyTopics(d, topics)
+	if got := fmt.Sprint(d.Hadiths["bukhari"][2].Topics); got != "[]" {
+		t.Errorf("with autoTag off, untagged hadith has topics %s", got)
+	}
+	if got := fmt.Sprint(d.Hadiths["bukhari"][1].Topics); got != "[prayer]" {
+		t.Errorf("with autoTag off, tagged hadith has topics %s", got)
+	}
+}
+
+func TestLoadTopics(t *testing.T) {
+	dir := t.TempDir()
+	topics, err := LoadTopics(dir)
+	if err != nil {
+		t.Fatalf("LoadTopics without a file returned error: %v", err)
+	}
+	if len(topics.Topics) != len(defaultTopics) {
+		t.Errorf("expected the built-in topics, got %d topics", len(topics.Topics))
+	}
+
+	writeTestFile(t, dir, TopicsFile, `{
+		"topics": [{"id": "patience", "name": "Patience", "keywords": ["patience"]}],
+		"tags": {"bukhari": {"5": ["patience"]}},
+		"autoTag": false
+	}`)
+	topics, err = LoadTopics(dir)
+	if err != nil {
+		t.Fatalf("LoadTopics returned error: %v", err)
+	}
+	if len(topics.Topics) != 1 || topics.Topics[0].Name != "Patience" || topics.AutoTag == nil || *topics.AutoTag {
+		t.Errorf("unexpected topics: %+v", topics)
+	}
+
+	invalid := []string{
+		`{"topics": [{"id": "a"}], "tags": {"bukhari": {"5": ["b"]}}}`,
+		`{"topics": [{"id": "a"}], "tags": {"bukhari": {"five": ["a"]}}}`,
+		`{"topics": [{"id": "a"}, {"id": "a"}]}`,
+		`{"topics": [{"id": "two words"}]}`,
+		`{"topics": [`,
+	}
+	for _, content := range invalid {
+		writeTestFile(t, dir, TopicsFile, content)
+		if _, err := LoadTopics(dir); err == nil {
+			t.Errorf("LoadTopics accepted %s", content)
+		}
+	}
+}
+
+func TestLoadHadithDataSkipsTopicsFile(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "one.json", fmt.Sprintf(testCollectionJSON, 1, 1, "One"))
+	writeTestFile(t, dir, TopicsFile, `{"topics": []}`)
+
+	d, report, err := LoadHadithData(dir)
+	if err != nil {
+		t.Fatalf("LoadHadithData returned error: %v", err)
+	}
+	if len(d.Collections) != 1 || report.HasErrors() {
+		t.Errorf("expected only the collection to load, got %d collections and errors %v", len(d.Collections), report.HasErrors())
+	}
+}
@@ -69,9 +69,20 @@ func (l *Logger) WithLevel(level Level) *Logger {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 	
-	newLogger := *l
+	newLogger := l.clone()
 	newLogger.level = level
-	return &newLogger
+	return newLogger
+}
+
+// clone copies the logger settings without copying its mutex
+func (l *Logger) clone() *Logger {
+	return &Logger{
+		level:   l.level,
+		output:  l.output,
+		prefix:  l.prefix,
+		flags:   l.flags,
+		callers: l.callers,
+	}
 }
 
 // log writes a log entry
@@ -137,9 +148,9 @@ func (l *Logger) WithPrefix(prefix string) *Logger {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	newLogger := *l
+	newLogger := l.clone()
 	newLogger.prefix = prefix
-	return &newLogger
+	return newLogger
 }
 
 // Helper functions for common logging patterns
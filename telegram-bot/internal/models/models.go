@@ -1,18 +1,21 @@
 package models
 
 import (
-	"time"
+	"math/rand/v2"
 )
 
 // Collection represents a hadith collection
 type Collection struct {
-	Name        string `json:"name"`
-	Title       string `json:"title"`
-	Author      string `json:"author"`
-	Hadiths     int    `json:"hadiths"`
-	Books       int    `json:"books"`
-	Description string `json:"description"`
-	Grade       string `json:"grade"`
+	Name              string `json:"name"`
+	Title             string `json:"title"`
+	ArabicTitle       string `json:"arabicTitle,omitempty"`
+	Author            string `json:"author"`
+	ArabicAuthor      string `json:"arabicAuthor,omitempty"`
+	Hadiths           int    `json:"hadiths"`
+	Books             int    `json:"books"`
+	Description       string `json:"description"`
+	ArabicDescription string `json:"arabicDescription,omitempty"`
+	Grade             string `json:"grade"`
 }
 
 // Book represents a book/chapter in a collection
@@ -27,14 +30,17 @@ type Book struct {
 
 // Hadith represents a single hadith
 type Hadith struct {
-	HadithNumber   int    `json:"hadithNumber"`
-	Grade          string `json:"grade"`
-	Arabic         string `json:"arabic"`
-	English        string `json:"english"`
-	Narrator       string `json:"narrator"`
-	ChapterID      int    `json:"chapterId"`
-	BookID         int    `json:"bookId"`
-	CollectionName string `json:"collectionName,omitempty"` // Added to track which collection a search result came from
+	HadithNumber   int      `json:"hadithNumber"`
+	Grade          string   `json:"grade"`
+	Arabic         string   `json:"arabic"`
+	English        string   `json:"english"`
+	Narrator       string   `json:"narrator"`
+	ChapterID      int      `json:"chapterId"`
+	BookID         int      `json:"bookId"`
+	CollectionName string   `json:"collectionName,omitempty"` // Added to track which collection a search result came from
+	Score          float64  `json:"score,omitempty"`          // Relevance of a search result; higher is better
+	Snippet        string   `json:"snippet,omitempty"`        // Telegram HTML excerpt of a search result with matches in <b>
+	Topics         []string `json:"topics,omitempty"`         // IDs of the topics the hadith is tagged with
 }
 
 // HadithResponse represents the response from getting hadiths
@@ -51,6 +57,42 @@ type SearchResult struct {
 	Total      int      `json:"total"`
 	Page       int      `json:"page"`
 	TotalPages int      `json:"totalPages"`
+	Suggestion string   `json:"suggestion,omitempty"` // corrected query when nothing matched
+
+	CollectionFacets []Facet `json:"collectionFacets,omitempty"` // matches per collection, across all pages
+	GradeFacets      []Facet `json:"gradeFacets,omitempty"`      // matches per grade, across all pages
+}
+
+// SearchFilter restricts a search by metadata. Empty fields do not restrict.
+type SearchFilter struct {
+	Collections []string `json:"collections,omitempty"`
+	Grades      []string `json:"grades,omitempty"`
+	BookFrom    int      `json:"bookFrom,omitempty"`
+	BookTo      int      `json:"bookTo,omitempty"`
+	Narrator    string   `json:"narrator,omitempty"`
+	Topics      []string `json:"topics,omitempty"`
+}
+
+// Narrator is an entry of the narrator index: a narrator under the most
+// common spelling of their name, and how many hadiths they narrate
+type Narrator struct {
+	ID    int    `json:"id"` // position in the index, most hadiths first
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// Topic is an entry of the topic taxonomy and how many hadiths are tagged
+// with it
+type Topic struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// Facet is the number of search matches sharing one value of a field
+type Facet struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
 }
 
 // RandomHadithResult represents a random hadith result
@@ -60,6 +102,13 @@ type RandomHadithResult struct {
 	Book       *Book       `json:"book"`
 }
 
+// DailyHadith is the hadith of the day
+type DailyHadith struct {
+	RandomHadithResult
+	Date   string `json:"date"`   // YYYY-MM-DD in the configured timezone
+	Pinned bool   `json:"pinned"` // set by the administrator for this date rather than picked
+}
+
 // CollectionData holds all collection data
 type CollectionData struct {
 	Collections []Collection
@@ -156,133 +205,74 @@ func (d *CollectionData) GetHadiths(collection string, bookNumber int, page int,
 	}
 }
 
-// SearchHadiths searches hadiths by keyword
-func (d *CollectionData) SearchHadiths(query string, page int, limit int) SearchResult {
-	query = toLower(query)
-	var results []Hadith
-
-	for collectionName, hadiths := range d.Hadiths {
-		for _, h := range hadiths {
-			if contains(toLower(h.English), query) ||
-			   contains(h.Arabic, query) ||
-			   contains(toLower(h.Narrator), query) {
-				h.CollectionName = collectionName // Save the collection name for the search result
-				results = append(results, h)
-			}
+// GetRandomHadith returns a hadith drawn with rng, every hadith equally
+// likely. A collection's weight, if given, multiplies the chance of each of
+// its hadiths; collections without one have weight 1, and a weight of 0
+// leaves a collection out. Hadiths for which exclude returns true are never
+// picked; the result is empty when every hadith is excluded. Book is the
+// hadith's own book, or nil if it has none.
+func (d *CollectionData) GetRandomHadith(rng *rand.Rand, weights map[string]float64, exclude func(collection string, hadithNumber int) bool) RandomHadithResult {
+	// Hadiths that may be picked per collection, and their weighted counts
+	available := make([]int, len(d.Collections))
+	weighted := make([]float64, len(d.Collections))
+	total := 0.0
+	for i, c := range d.Collections {
+		w := collectionWeight(weights, c.Name)
+		if w == 0 {
+			continue
 		}
-	}
-
-	total := len(results)
-	totalPages := (total + limit - 1) / limit
-
-	if page < 1 {
-		page = 1
-	}
-
-	start := (page - 1) * limit
-	if start >= total {
-		return SearchResult{
-			Hadiths:    []Hadith{},
-			Total:      total,
-			Page:       page,
-			TotalPages: totalPages,
+		for _, h := range d.Hadiths[c.Name] {
+			if exclude == nil || !exclude(c.Name, h.HadithNumber) {
+				available[i]++
+			}
 		}
+		weighted[i] = w * float64(available[i])
+		total += weighted[i]
 	}
-
-	end := start + limit
-	if end > total {
-		end = total
-	}
-
-	return SearchResult{
-		Hadiths:    results[start:end],
-		Total:      total,
-		Page:       page,
-		TotalPages: totalPages,
-	}
-}
-
-// GetRandomHadith returns a random hadith
-func (d *CollectionData) GetRandomHadith() RandomHadithResult {
-	// Get all collections
-	collections := d.Collections
-	if len(collections) == 0 {
+	if total <= 0 {
 		return RandomHadithResult{}
 	}
 
-	// Pick a random collection (prefer major ones)
-	majorCollections := []string{"bukhari", "muslim", "abudawud", "tirmidhi", "nasai", "ibnmajah"}
-	var selectedCollections []Collection
-	for _, name := range majorCollections {
-		if c := d.GetCollection(name); c != nil {
-			selectedCollections = append(selectedCollections, *c)
+	// Pick a collection in proportion to its weighted count, then a hadith
+	// within it
+	x := rng.Float64() * total
+	chosen := -1
+	for i, w := range weighted {
+		if w <= 0 {
+			continue
 		}
+		chosen = i // the last candidate absorbs rounding at the top end
+		if x < w {
+			break
+		}
+		x -= w
 	}
-	if len(selectedCollections) == 0 {
-		selectedCollections = collections
-	}
-
-	collection := selectedCollections[randInt(len(selectedCollections))]
-
-	// Get books for this collection
-	books := d.GetBooks(collection.Name)
-	if len(books) == 0 {
-		return RandomHadithResult{}
-	}
-
-	// Pick a random book
-	book := books[randInt(len(books))]
 
-	// Get hadiths for this collection
-	hadiths := d.Hadiths[collection.Name]
-	if len(hadiths) == 0 {
-		return RandomHadithResult{}
+	collection := d.Collections[chosen]
+	k := rng.IntN(available[chosen])
+	var hadith Hadith
+	for _, h := range d.Hadiths[collection.Name] {
+		if exclude != nil && exclude(collection.Name, h.HadithNumber) {
+			continue
+		}
+		if k == 0 {
+			hadith = h
+			break
+		}
+		k--
 	}
 
-	// Pick a random hadith
-	hadith := hadiths[randInt(len(hadiths))]
-
 	return RandomHadithResult{
 		Hadith:     &hadith,
 		Collection: &collection,
-		Book:       &book,
-	}
-}
-
-// Helper functions
-func toLower(s string) string {
-	result := make([]rune, len(s))
-	for i, r := range s {
-		if r >= 'A' && r <= 'Z' {
-			result[i] = r + 32
-		} else {
-			result[i] = r
-		}
+		Book:       d.GetBook(collection.Name, hadith.ChapterID),
 	}
-	return string(result)
 }
 
-func contains(s, substr string) bool {
-	if len(substr) == 0 {
-		return true
-	}
-	if len(s) < len(substr) {
-		return false
-	}
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
-		}
-	}
-	return false
-}
-
-// Simple random int (avoid import for simplicity)
-func randInt(n int) int {
-	if n <= 0 {
-		return 0
+func collectionWeight(weights map[string]float64, collection string) float64 {
+	w, ok := weights[collection]
+	if !ok {
+		return 1
 	}
-	// Use current time nanoseconds for simple randomness
-	return int(time.Now().UnixNano()) % n
+	return max(w, 0)
 }
-
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+func newRandomTestData() *CollectionData {
+	return &CollectionData{
+		Collections: []Collection{{Name: "bukhari"}, {Name: "muslim"}, {Name: "empty"}},
+		Books: map[string][]Book{
+			"bukhari": {{BookNumber: 1, Title: "Revelation"}, {BookNumber: 2, Title: "Belief"}},
+			"muslim":  {{BookNumber: 1, Title: "Faith"}},
+		},
+		Hadiths: map[string][]Hadith{
+			"bukhari": {
+				{HadithNumber: 1, ChapterID: 1},
+				{HadithNumber: 2, ChapterID: 2},
+				{HadithNumber: 3, ChapterID: 2},
+			},
+			"muslim": {
+				{HadithNumber: 10, ChapterID: 1},
+			},
+		},
+	}
+}
+
+func TestGetRandomHadithDeterministic(t *testing.T) {
+	d := newRandomTestData()
+
+	pick := func(seed uint64) []int {
+		rng := rand.New(rand.NewPCG(seed, 0))
+		var numbers []int
+		for i := 0; i < 20; i++ {
+			numbers = append(numbers, d.GetRandomHadith(rng, nil, nil).Hadith.HadithNumber)
+		}
+		return numbers
+	}
+
+	first, second := pick(7), pick(7)
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("same seed gave different picks: %v and %v", first, second)
+		}
+	}
+}
+
+func TestGetRandomHadithBookMatches(t *testing.T) {
+	d := newRandomTestData()
+	rng := rand.New(rand.NewPCG(1, 2))
+
+	for i := 0; i < 100; i++ {
+		res := d.GetRandomHadith(rng, nil, nil)
+		if res.Hadith == nil || res.Collection == nil {
+			t.Fatal("no hadith returned")
+		}
+		if res.Book == nil || res.Book.BookNumber != res.Hadith.ChapterID {
+			t.Fatalf("%s %d: book %+v does not match chapter %d", res.Collection.Name, res.Hadith.HadithNumber, res.Book, res.Hadith.ChapterID)
+		}
+		if res.Collection.Name == "empty" {
+			t.Fatal("picked a collection without hadiths")
+		}
+	}
+}
+
+func TestGetRandomHadithDistribution(t *testing.T) {
+	d := newRandomTestData()
+	rng := rand.New(rand.NewPCG(3, 4))
+
+	tests := []struct {
+		weights  map[string]float64
+		expected map[int]float64 // share of picks per hadith number
+	}{
+		// Uniform over hadiths, not over collections
+		{nil, map[int]float64{1: 0.25, 2: 0.25, 3: 0.25, 10: 0.25}},
+		{map[string]float64{"muslim": 3}, map[int]float64{1: 1.0 / 6, 2: 1.0 / 6, 3: 1.0 / 6, 10: 0.5}},
+		{map[string]float64{"bukhari": 0}, map[int]float64{10: 1}},
+	}
+
+	const n = 20000
+	for _, tt := range tests {
+		counts := make(map[int]int)
+		for i := 0; i < n; i++ {
+			counts[d.GetRandomHadith(rng, tt.weights, nil).Hadith.HadithNumber]++
+		}
+		for number, count := range counts {
+			if tt.expected[number] == 0 {
+				t.Errorf("weights %v: hadith %d picked %d times; want never", tt.weights, number, count)
+			}
+		}
+		for number, share := range tt.expected {
+			if got := float64(counts[number]) / n; got < share-0.02 || got > share+0.02 {
+				t.Errorf("weights %v: hadith %d picked %.3f of the time; want about %.3f", tt.weights, number, got, share)
+			}
+		}
+	}
+
+	if res := d.GetRandomHadith(rng, map[string]float64{"bukhari": 0, "muslim": 0}, nil); res.Hadith != nil {
+		t.Errorf("all collections weighted 0 returned %+v", res.Hadith)
+	}
+}
+
+func TestGetRandomHadithExclude(t *testing.T) {
+	d := newRandomTestData()
+	rng := rand.New(rand.NewPCG(5, 6))
+
+	sent := map[int]bool{1: true, 3: true, 10: true}
+	exclude := func(collection string, hadithNumber int) bool {
+		return sent[hadithNumber]
+	}
+	for i := 0; i < 50; i++ {
+		if res := d.GetRandomHadith(rng, nil, exclude); res.Hadith == nil || res.Hadith.HadithNumber != 2 {
+			t.Fatalf("expected the only hadith not excluded, got %+v", res.Hadith)
+		}
+	}
+
+	sent[2] = true
+	if res := d.GetRandomHadith(rng, nil, exclude); res.Hadith != nil {
+		t.Errorf("every hadith excluded, got %+v", res.Hadith)
+	}
+}
@@ -0,0 +1,95 @@
+package search
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// arabicLetterForms maps letter variants to the form the index stores, so a
+// query typed without hamza or with ha instead of ta marbuta still matches
+var arabicLetterForms = map[rune]rune{
+	'أ': 'ا',
+	'إ': 'ا',
+	'آ': 'ا',
+	'ٱ': 'ا',
+	'ؤ': 'و',
+	'ئ': 'ي',
+	'ى': 'ي',
+	'ة': 'ه',
+}
+
+// arabicArticles are definite-article prefixes stripped from the start of a
+// term, longest first, with the conjunctions and prepositions that attach to them
+var arabicArticles = []string{"وال", "بال", "فال", "كال", "لل", "ال"}
+
+// allahForms are the name of Allah with the prepositions and conjunctions
+// that attach to it, all indexed as "الله". Stripping an article would turn
+// them into the unrelated word "له", and "لله" (li-llah) has no article left
+// to strip.
+var allahForms = map[string]bool{
+	"الله": true, "لله": true, "بالله": true, "والله": true,
+	"فالله": true, "تالله": true, "ولله": true, "فلله": true,
+}
+
+// isArabicMark reports whether r is a harakah, Quranic annotation or tatweel,
+// all of which are dropped from terms
+func isArabicMark(r rune) bool {
+	switch {
+	case r >= 0x064B && r <= 0x065F: // fathatan .. wavy hamza below
+		return true
+	case r == 0x0670: // superscript alef
+		return true
+	case r >= 0x06D6 && r <= 0x06ED: // Quranic annotation signs
+		return true
+	case r == 0x0640: // tatweel
+		return true
+	}
+	return false
+}
+
+// isIgnorable reports whether r is an invisible formatting character, such as
+// the right-to-left marks in the data, which should neither join nor split terms
+func isIgnorable(r rune) bool {
+	switch r {
+	case 0x200B, 0x200C, 0x200D, 0x200E, 0x200F, 0x061C, 0xFEFF:
+		return true
+	}
+	return false
+}
+
+// NormalizeArabic strips harakat, tatweel and invisible marks from text and
+// unifies alef/hamza forms, alef maqsura and ta marbuta. Non-Arabic text is
+// returned unchanged apart from removed marks.
+func NormalizeArabic(text string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+	for _, r := range text {
+		if isArabicMark(r) || isIgnorable(r) {
+			continue
+		}
+		if mapped, ok := arabicLetterForms[r]; ok {
+			r = mapped
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// stripArabicArticle removes a leading definite article from an already
+// normalized term, keeping at least two letters. Forms of the name of Allah
+// become "الله".
+func stripArabicArticle(term string) string {
+	if allahForms[term] {
+		return "الله"
+	}
+	for _, article := range arabicArticles {
+		if !strings.HasPrefix(term, article) {
+			continue
+		}
+		rest := term[len(article):]
+		if utf8.RuneCountInString(rest) >= 2 {
+			return rest
+		}
+	}
+	return term
+}
@@ -0,0 +1,457 @@
+// Package search provides a full-text index over the loaded hadith
+// collections. The index is built once at load time and answers queries
+// without scanning the hadith texts.
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"hadith-bot/internal/models"
+)
+
+// Field identifies which part of a hadith a term was found in
+type Field uint8
+
+const (
+	FieldEnglish Field = iota
+	FieldArabic
+	FieldNarrator
+	numFields
+)
+
+// minPrefixRunes is the shortest query term that also matches longer terms
+// starting with it, so "pray" finds "prayer" but "a" does not match everything
+const minPrefixRunes = 3
+
+// BM25 parameters. Prefix matches count for less than exact ones so "pray"
+// ranks hadiths containing "pray" above those that only contain "prayer".
+const (
+	bm25K1        = 1.2
+	bm25B         = 0.75
+	prefixPenalty = 0.5
+)
+
+// fieldBoosts weights a match by the field it is in. A query term that names
+// the narrator is a stronger signal than the same word in the text.
+var fieldBoosts = [numFields]float64{
+	FieldEnglish:  1.0,
+	FieldArabic:   1.0,
+	FieldNarrator: 1.5,
+}
+
+// DocID identifies a hadith inside an Index
+type DocID int32
+
+// Doc is the index's record of one hadith
+type Doc struct {
+	Collection   string
+	Offset       int // position in CollectionData.Hadiths[Collection]
+	HadithNumber int
+	ChapterID    int
+	Grade        string
+	Topics       []string
+
+	rank   int // position of the collection in load order
+	length [numFields]int
+	text   [numFields]string
+}
+
+type posting struct {
+	doc DocID
+	tf  int32
+}
+
+// Hit is one hadith matching a query
+type Hit struct {
+	Doc          DocID
+	Collection   string
+	Offset       int
+	HadithNumber int
+	Score        float64
+}
+
+// Index is an inverted index from terms to the hadiths containing them,
+// kept separately for each field
+type Index struct {
+	docs            []Doc
+	postings        [numFields]map[string][]posting
+	vocab           [numFields][]string // sorted terms, for prefix lookups
+	avgLen          [numFields]float64
+	norms           [numFields][]float64 // TF-IDF vector lengths, for Similar
+	collectionStart map[string]DocID
+}
+
+// NewIndex builds an index over every hadith in the collection data. Doc IDs
+// are assigned in collection order, so each collection occupies a contiguous
+// range of IDs.
+func NewIndex(data *models.CollectionData) *Index {
+	idx := &Index{collectionStart: make(map[string]DocID)}
+	for f := range idx.postings {
+		idx.postings[f] = make(map[string][]posting)
+	}
+	if data == nil {
+		return idx
+	}
+
+	for rank, c := range data.Collections {
+		idx.collectionStart[c.Name] = DocID(len(idx.docs))
+		for offset, h := range data.Hadiths[c.Name] {
+			id := DocID(len(idx.docs))
+			idx.docs = append(idx.docs, Doc{
+				Collection:   c.Name,
+				Offset:       offset,
+				HadithNumber: h.HadithNumber,
+				ChapterID:    h.ChapterID,
+				Grade:        h.Grade,
+				Topics:       h.Topics,
+				rank:         rank,
+				text:         [numFields]string{FieldEnglish: h.English, FieldArabic: h.Arabic, FieldNarrator: h.Narrator},
+			})
+			idx.addField(id, FieldEnglish, h.English)
+			idx.addField(id, FieldArabic, h.Arabic)
+			idx.addField(id, FieldNarrator, h.Narrator)
+		}
+	}
+
+	for f := range idx.postings {
+		terms := make([]string, 0, len(idx.postings[f]))
+		for term := range idx.postings[f] {
+			terms = append(terms, term)
+		}
+		sort.Strings(terms)
+		idx.vocab[f] = terms
+	}
+
+	if len(idx.docs) > 0 {
+		for _, d := range idx.docs {
+			for f := range d.length {
+				idx.avgLen[f] += float64(d.length[f])
+			}
+		}
+		for f := range idx.avgLen {
+			idx.avgLen[f] /= float64(len(idx.docs))
+		}
+	}
+	idx.computeNorms()
+
+	return idx
+}
+
+func (idx *Index) addField(id DocID, field Field, text string) {
+	counts := make(map[string]int32)
+	tokens := Tokenize(text)
+	for _, token := range tokens {
+		counts[token]++
+	}
+	idx.docs[id].length[field] = len(tokens)
+	for term, tf := range counts {
+		idx.postings[field][term] = append(idx.postings[field][term], posting{doc: id, tf: tf})
+	}
+}
+
+// Len returns the number of indexed hadiths
+func (idx *Index) Len() int {
+	return len(idx.docs)
+}
+
+// Doc returns the record for a doc ID
+func (idx *Index) Doc(id DocID) Doc {
+	return idx.docs[id]
+}
+
+// Search returns the hadiths matching a parsed query, best BM25 match first.
+// Equal scores keep collection order and then hadith number, so paging
+// through the results is stable.
+func (idx *Index) Search(q *Query) []Hit {
+	var matched *matchSet
+	for _, group := range q.Groups {
+		groupSet := idx.matchGroup(group)
+		if groupSet == nil {
+			continue
+		}
+		if matched == nil {
+			matched = groupSet
+		} else {
+			matched.union(groupSet)
+		}
+	}
+
+	if matched == nil {
+		// A filter-only query lists everything the filter allows
+		if len(q.Groups) > 0 || q.Filter.isEmpty() {
+			return nil
+		}
+		matched = newMatchSet(len(idx.docs))
+		for id := range matched.matched {
+			matched.matched[id] = true
+		}
+	}
+
+	for _, term := range q.Exclude {
+		if excluded := idx.matchTerm(term); excluded != nil {
+			matched.subtract(excluded)
+		}
+	}
+
+	var hits []Hit
+	for id, ok := range matched.matched {
+		if !ok || !idx.passes(DocID(id), q.Filter) {
+			continue
+		}
+		d := idx.docs[id]
+		hits = append(hits, Hit{Doc: DocID(id), Collection: d.Collection, Offset: d.Offset, HadithNumber: d.HadithNumber, Score: matched.score[id]})
+	}
+	idx.sortHits(hits)
+	return hits
+}
+
+// matchGroup returns the docs matching every term of the group, or nil when
+// no term in the group has anything searchable in it
+func (idx *Index) matchGroup(group []Term) *matchSet {
+	var set *matchSet
+	for _, term := range group {
+		termSet := idx.matchTerm(term)
+		if termSet == nil {
+			continue
+		}
+		if set == nil {
+			set = termSet
+		} else {
+			set.intersect(termSet)
+		}
+	}
+	return set
+}
+
+// matchTerm returns the docs matching a word or phrase or any of its
+// variants, or nil when none of them tokenizes to anything (e.g. punctuation)
+func (idx *Index) matchTerm(term Term) *matchSet {
+	set := idx.matchText(term.Text, term, term.Phrase)
+	for _, variant := range term.Variants {
+		// Variants are whole spellings, so they are not prefix-expanded, and
+		// one of several words ("dry ablution") matches only as a phrase
+		variantSet := idx.matchText(variant, term, true)
+		if variantSet == nil {
+			continue
+		}
+		if set == nil {
+			set = variantSet
+		} else {
+			set.union(variantSet)
+		}
+	}
+	return set
+}
+
+// matchText returns the docs matching text in the term's fields. With phrase
+// set, words only match whole indexed terms and must appear next to each
+// other in order.
+func (idx *Index) matchText(text string, term Term, phrase bool) *matchSet {
+	tokens := Tokenize(text)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	fields := []Field{FieldEnglish, FieldArabic, FieldNarrator}
+	if term.Narrator {
+		fields = []Field{FieldNarrator}
+	}
+
+	// Phrases match whole words only, since the adjacency check below needs
+	// exact terms
+	var set *matchSet
+	for _, token := range tokens {
+		tokenSet := idx.scoreTerm(token, fields, phrase)
+		if set == nil {
+			set = tokenSet
+		} else {
+			set.intersect(tokenSet)
+		}
+	}
+
+	if phrase && len(tokens) > 1 {
+		for id, ok := range set.matched {
+			if ok && !idx.containsPhrase(DocID(id), tokens, fields) {
+				set.matched[id] = false
+			}
+		}
+	}
+	return set
+}
+
+// containsPhrase reports whether the tokens appear consecutively in any of
+// the doc's fields
+func (idx *Index) containsPhrase(id DocID, phrase []string, fields []Field) bool {
+	for _, f := range fields {
+		tokens := Tokenize(idx.docs[id].text[f])
+		for i := 0; i+len(phrase) <= len(tokens); i++ {
+			match := true
+			for j := range phrase {
+				if tokens[i+j] != phrase[j] {
+					match = false
+					break
+				}
+			}
+			if match {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// passes reports whether a doc satisfies the metadata filter
+func (idx *Index) passes(id DocID, f Filter) bool {
+	d := idx.docs[id]
+	if len(f.Collections) > 0 && !containsFold(f.Collections, d.Collection) {
+		return false
+	}
+	if len(f.Grades) > 0 && !gradeMatches(d.Grade, f.Grades) {
+		return false
+	}
+	if f.BookFrom > 0 {
+		to := f.BookTo
+		if to < f.BookFrom {
+			to = f.BookFrom
+		}
+		if d.ChapterID < f.BookFrom || d.ChapterID > to {
+			return false
+		}
+	}
+	if len(f.Topics) > 0 && !anyFold(f.Topics, d.Topics) {
+		return false
+	}
+	return true
+}
+
+// anyFold reports whether the lists share an item, ignoring case
+func anyFold(wanted, have []string) bool {
+	for _, item := range have {
+		if containsFold(wanted, item) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// gradeMatches reports whether a grade such as "Hasan Sahih" contains all
+// the words of any of the wanted grades
+func gradeMatches(grade string, wanted []string) bool {
+	gradeTokens := Tokenize(grade)
+	for _, w := range wanted {
+		all := true
+		for _, t := range Tokenize(w) {
+			if !containsFold(gradeTokens, t) {
+				all = false
+				break
+			}
+		}
+		if all {
+			return true
+		}
+	}
+	return false
+}
+
+// sortHits orders hits by score, then collection, then hadith number
+func (idx *Index) sortHits(hits []Hit) {
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		di, dj := idx.docs[hits[i].Doc], idx.docs[hits[j].Doc]
+		if di.rank != dj.rank {
+			return di.rank < dj.rank
+		}
+		if di.HadithNumber != dj.HadithNumber {
+			return di.HadithNumber < dj.HadithNumber
+		}
+		return hits[i].Doc < hits[j].Doc
+	})
+}
+
+// scoreTerm returns the BM25 score of every doc containing term in the given
+// fields, summed over fields. Within a field only the best matching expansion
+// of the term counts, so a prefix that matches several forms of a word is not
+// counted twice.
+func (idx *Index) scoreTerm(term string, fields []Field, exact bool) *matchSet {
+	set := newMatchSet(len(idx.docs))
+	best := make([]float64, len(idx.docs))
+	for _, f := range fields {
+		var touched []DocID
+		terms := idx.expand(f, term)
+		if exact {
+			terms = nil
+			if _, ok := idx.postings[f][term]; ok {
+				terms = []string{term}
+			}
+		}
+		for _, t := range terms {
+			postings := idx.postings[f][t]
+			weight := fieldBoosts[f] * idx.idf(len(postings))
+			if t != term {
+				weight *= prefixPenalty
+			}
+			for _, p := range postings {
+				s := weight * idx.tfNorm(p, f)
+				if best[p.doc] == 0 {
+					touched = append(touched, p.doc)
+				}
+				set.matched[p.doc] = true
+				if s > best[p.doc] {
+					best[p.doc] = s
+				}
+			}
+		}
+		for _, id := range touched {
+			set.score[id] += best[id]
+			best[id] = 0
+		}
+	}
+	return set
+}
+
+func (idx *Index) idf(docFreq int) float64 {
+	n := float64(len(idx.docs))
+	df := float64(docFreq)
+	return math.Log(1 + (n-df+0.5)/(df+0.5))
+}
+
+func (idx *Index) tfNorm(p posting, field Field) float64 {
+	tf := float64(p.tf)
+	norm := 1.0
+	if idx.avgLen[field] > 0 {
+		norm = 1 - bm25B + bm25B*float64(idx.docs[p.doc].length[field])/idx.avgLen[field]
+	}
+	return tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+}
+
+// expand returns the indexed terms of a field that a query term matches: the
+// term itself plus, for long enough terms, every term it is a prefix of
+func (idx *Index) expand(field Field, term string) []string {
+	if utf8.RuneCountInString(term) < minPrefixRunes {
+		if _, ok := idx.postings[field][term]; ok {
+			return []string{term}
+		}
+		return nil
+	}
+
+	vocab := idx.vocab[field]
+	var terms []string
+	for i := sort.SearchStrings(vocab, term); i < len(vocab) && strings.HasPrefix(vocab[i], term); i++ {
+		terms = append(terms, vocab[i])
+	}
+	return terms
+}
@@ -0,0 +1,163 @@
+package search
+
+import (
+	"fmt"
+	"testing"
+
+	"hadith-bot/internal/models"
+)
+
+func testData() *models.CollectionData {
+	return &models.CollectionData{
+		Collections: []models.Collection{{Name: "bukhari"}, {Name: "muslim"}},
+		Hadiths: map[string][]models.Hadith{
+			"bukhari": {
+				{HadithNumber: 1, English: "Actions are judged by intentions.", Narrator: "Narrated Umar bin Al-Khattab:"},
+				{HadithNumber: 2, English: "The prayer offered in congregation is superior.", Narrator: "Narrated Abu Huraira:"},
+			},
+			"muslim": {
+				{HadithNumber: 7, English: "He who prays the dawn prayer is under the protection of Allah.", Narrator: "Jundab reported:"},
+				{HadithNumber: 8, Arabic: "إِنَّمَا الأَعْمَالُ بِالنِّيَّاتِ", Narrator: "Abu Huraira reported:"},
+			},
+		},
+	}
+}
+
+func hitRefs(hits []Hit) []string {
+	var refs []string
+	for _, h := range hits {
+		refs = append(refs, fmt.Sprintf("%s:%d", h.Collection, h.HadithNumber))
+	}
+	return refs
+}
+
+func search(t *testing.T, idx *Index, query string) []Hit {
+	t.Helper()
+	q, err := ParseQuery(query)
+	if err != nil {
+		t.Fatalf("ParseQuery(%q) returned error: %v", query, err)
+	}
+	return idx.Search(q)
+}
+
+func TestIndexSearch(t *testing.T) {
+	idx := NewIndex(testData())
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"prayer", []string{"bukhari:2", "muslim:7"}},
+		{"pray", []string{"muslim:7", "bukhari:2"}},
+		{"PRAYER congregation", []string{"bukhari:2"}},
+		{"huraira", []string{"bukhari:2", "muslim:8"}},
+		{"الأَعْمَالُ", []string{"muslim:8"}},
+		{"intentions zakat", nil},
+	}
+
+	for _, tt := range tests {
+		got := hitRefs(search(t, idx, tt.query))
+		if len(got) != len(tt.expected) {
+			t.Errorf("Search(%q) = %v; want %v", tt.query, got, tt.expected)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.expected[i] {
+				t.Errorf("Search(%q) = %v; want %v", tt.query, got, tt.expected)
+				break
+			}
+		}
+	}
+}
+
+func TestIndexRanking(t *testing.T) {
+	data := &models.CollectionData{
+		Collections: []models.Collection{{Name: "muslim"}, {Name: "bukhari"}},
+		Hadiths: map[string][]models.Hadith{
+			"bukhari": {
+				{HadithNumber: 5, English: "Fasting is a shield."},
+				{HadithNumber: 3, English: "Fasting is a shield."},
+			},
+			"muslim": {
+				{HadithNumber: 9, English: "Fasting is a shield."},
+				{HadithNumber: 1, English: "Charity and fasting and fasting again, and the fasting of Ramadan."},
+				{HadithNumber: 2, English: "Speak good or keep silent about fasting."},
+				{HadithNumber: 4, English: "Faster than the wind."},
+			},
+		},
+	}
+	idx := NewIndex(data)
+
+	// Equal scores fall back to collection order, then hadith number
+	got := hitRefs(search(t, idx, "shield"))
+	want := []string{"muslim:9", "bukhari:3", "bukhari:5"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Search(shield) = %v; want %v", got, want)
+	}
+
+	// Higher term frequency ranks first
+	hits := search(t, idx, "fasting")
+	if len(hits) != 5 {
+		t.Fatalf("Search(fasting) returned %d hits; want 5", len(hits))
+	}
+	for i := 1; i < len(hits); i++ {
+		if hits[i].Score > hits[i-1].Score {
+			t.Errorf("hits not sorted by score: %v", hits)
+		}
+	}
+	if hits[0].Collection != "muslim" || hits[0].HadithNumber != 1 {
+		t.Errorf("expected muslim:1 first, got %s:%d", hits[0].Collection, hits[0].HadithNumber)
+	}
+}
+
+func TestArabicNormalization(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"صلاة", []string{"صلاه"}},
+		{"الصَّلاَةِ", []string{"صلاه"}},
+		{"وَالصَّلَاةُ", []string{"صلاه"}},
+		{"إِنَّمَا الأَعْمَالُ بِالنِّيَّاتِ", []string{"انما", "اعمال", "نيات"}},
+		{"رَسُولُ اللَّهِ", []string{"رسول", "الله"}},
+		{"الْحَمْدُ لِلَّهِ", []string{"حمد", "الله"}},
+		{"بِاللَّهِ", []string{"الله"}},
+		{"وَاللَّهِ", []string{"الله"}},
+		{"لَهُ", []string{"له"}},
+		{"عَلَى‏", []string{"علي"}},
+		{"قـــال", []string{"قال"}},
+		{"مُؤْمِن", []string{"مومن"}},
+	}
+
+	for _, tt := range tests {
+		got := Tokenize(tt.input)
+		if fmt.Sprint(got) != fmt.Sprint(tt.expected) {
+			t.Errorf("Tokenize(%q) = %v; want %v", tt.input, got, tt.expected)
+		}
+	}
+
+	idx := NewIndex(&models.CollectionData{
+		Collections: []models.Collection{{Name: "bukhari"}},
+		Hadiths: map[string][]models.Hadith{
+			"bukhari": {{HadithNumber: 1, Arabic: "أَقِيمُوا الصَّلاَةَ‏"}},
+		},
+	})
+	if hits := search(t, idx, "صلاة"); len(hits) != 1 {
+		t.Errorf("unvocalized query should match vocalized text, got %d hits", len(hits))
+	}
+
+	idx = NewIndex(&models.CollectionData{
+		Collections: []models.Collection{{Name: "bukhari"}},
+		Hadiths: map[string][]models.Hadith{
+			"bukhari": {
+				{HadithNumber: 1, Arabic: "الْحَمْدُ لِلَّهِ"},
+				{HadithNumber: 2, Arabic: "رَسُولُ اللَّهِ"},
+			},
+		},
+	})
+	for _, query := range []string{"الله", "لله"} {
+		if hits := search(t, idx, query); len(hits) != 2 {
+			t.Errorf("search(%q) found %d hits; want both forms of the name", query, len(hits))
+		}
+	}
+}
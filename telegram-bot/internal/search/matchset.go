@@ -0,0 +1,63 @@
+package search
+
+// matchSet is a dense set of matching docs with a score for each, indexed by
+// DocID. The corpus is small enough that whole-corpus slices are cheaper than
+// maps for combining term results.
+type matchSet struct {
+	matched []bool
+	score   []float64
+}
+
+func newMatchSet(n int) *matchSet {
+	return &matchSet{
+		matched: make([]bool, n),
+		score:   make([]float64, n),
+	}
+}
+
+// any reports whether the set matched at least one doc. A nil set, for a
+// term with nothing searchable in it, matches none.
+func (m *matchSet) any() bool {
+	if m == nil {
+		return false
+	}
+	for _, ok := range m.matched {
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// intersect keeps docs present in both sets, adding their scores
+func (m *matchSet) intersect(other *matchSet) {
+	for id := range m.matched {
+		if m.matched[id] && other.matched[id] {
+			m.score[id] += other.score[id]
+		} else {
+			m.matched[id] = false
+		}
+	}
+}
+
+// union keeps docs present in either set, scoring each by its better match
+func (m *matchSet) union(other *matchSet) {
+	for id := range m.matched {
+		if !other.matched[id] {
+			continue
+		}
+		if !m.matched[id] || other.score[id] > m.score[id] {
+			m.score[id] = other.score[id]
+		}
+		m.matched[id] = true
+	}
+}
+
+// subtract removes docs present in the other set
+func (m *matchSet) subtract(other *matchSet) {
+	for id := range m.matched {
+		if other.matched[id] {
+			m.matched[id] = false
+		}
+	}
+}
@@ -0,0 +1,367 @@
+package search
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Query is a parsed search query: any one of the OR-separated groups must
+// match, every term in a group must match, no excluded term may match, and
+// the hadith must pass the filter.
+type Query struct {
+	Groups  [][]Term
+	Exclude []Term
+	Filter  Filter
+}
+
+// Term is a word or quoted phrase, optionally restricted to the narrator
+// field. A hadith matching any of the Variants (other spellings, set by
+// Synonyms.Expand) matches the term; a variant of several words matches as a
+// phrase.
+type Term struct {
+	Text     string
+	Phrase   bool
+	Narrator bool
+	Variants []string
+}
+
+// Filter restricts results by metadata rather than text
+type Filter struct {
+	Collections []string
+	Grades      []string
+	BookFrom    int
+	BookTo      int
+	Topics      []string // topic IDs
+}
+
+// ParseError is returned for queries that cannot be parsed. Its message is
+// written for the person who typed the query.
+type ParseError struct {
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return e.Msg
+}
+
+func parseErrorf(format string, args ...interface{}) *ParseError {
+	return &ParseError{Msg: fmt.Sprintf(format, args...)}
+}
+
+// queryFields are the prefixes recognised before a colon
+var queryFields = map[string]bool{
+	"narrator":   true,
+	"collection": true,
+	"grade":      true,
+	"book":       true,
+	"topic":      true,
+}
+
+// ParseQuery parses the /search syntax:
+//
+//	prayer fasting            both words (AND is implied; it may also be written)
+//	prayer OR fasting         either word
+//	"exact phrase"            the words next to each other
+//	-wine, -"some phrase"     exclude hadiths containing the word or phrase
+//	narrator:"Abu Huraira"    words in the narrator only
+//	collection:bukhari,muslim grade:sahih book:2 book:2-5 topic:prayer
+func ParseQuery(input string) (*Query, error) {
+	tokens, err := lexQuery(input)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &Query{}
+	var group []Term
+	expectTerm := false // set after AND/OR until a term follows
+
+	for i, tok := range tokens {
+		if !tok.quoted && (tok.text == "OR" || tok.text == "AND") {
+			if i == 0 || expectTerm {
+				return nil, parseErrorf("%s needs a search term on both sides.", tok.text)
+			}
+			if tok.text == "OR" {
+				if len(group) == 0 {
+					return nil, parseErrorf("OR needs a search term on both sides.")
+				}
+				q.Groups = append(q.Groups, group)
+				group = nil
+			}
+			expectTerm = true
+			continue
+		}
+		expectTerm = false
+
+		if tok.field != "" {
+			if err := q.applyField(tok, &group); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		term := Term{Text: tok.text, Phrase: tok.quoted}
+		if tok.negate {
+			q.Exclude = append(q.Exclude, term)
+		} else {
+			group = append(group, term)
+		}
+	}
+
+	if expectTerm {
+		return nil, parseErrorf("%s needs a search term on both sides.", tokens[len(tokens)-1].text)
+	}
+	if len(group) > 0 {
+		q.Groups = append(q.Groups, group)
+	}
+
+	if len(q.Groups) == 0 && q.Filter.isEmpty() {
+		if len(q.Exclude) > 0 {
+			return nil, parseErrorf("Add at least one word to search for, not only words to exclude.")
+		}
+		return nil, parseErrorf("Please provide a keyword to search for.")
+	}
+
+	return q, nil
+}
+
+// applyField handles a field:value token, either adding a narrator term to
+// the current group or setting a filter
+func (q *Query) applyField(tok queryToken, group *[]Term) error {
+	if tok.text == "" {
+		return parseErrorf("%s: needs a value, e.g. %s", tok.field, fieldExample(tok.field))
+	}
+
+	if tok.field == "narrator" {
+		term := Term{Text: tok.text, Phrase: tok.quoted, Narrator: true}
+		if tok.negate {
+			q.Exclude = append(q.Exclude, term)
+		} else {
+			*group = append(*group, term)
+		}
+		return nil
+	}
+
+	if tok.negate {
+		return parseErrorf("%s: filters cannot be excluded with -.", tok.field)
+	}
+
+	switch tok.field {
+	case "collection":
+		for _, name := range splitList(tok.text) {
+			q.Filter.Collections = append(q.Filter.Collections, strings.ToLower(name))
+		}
+	case "grade":
+		for _, grade := range splitList(tok.text) {
+			q.Filter.Grades = append(q.Filter.Grades, strings.ToLower(grade))
+		}
+	case "book":
+		from, to, err := parseBookRange(tok.text)
+		if err != nil {
+			return err
+		}
+		q.Filter.BookFrom, q.Filter.BookTo = from, to
+	case "topic":
+		for _, topic := range splitList(tok.text) {
+			q.Filter.Topics = append(q.Filter.Topics, strings.ToLower(topic))
+		}
+	}
+	return nil
+}
+
+func fieldExample(field string) string {
+	switch field {
+	case "narrator":
+		return `narrator:"Abu Huraira"`
+	case "collection":
+		return "collection:bukhari"
+	case "grade":
+		return "grade:sahih"
+	case "topic":
+		return "topic:prayer"
+	default:
+		return "book:2"
+	}
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseBookRange parses "2" or "2-5"
+func parseBookRange(value string) (int, int, error) {
+	fromStr, toStr, isRange := strings.Cut(value, "-")
+	from, err := strconv.Atoi(fromStr)
+	if err != nil || from < 1 {
+		return 0, 0, parseErrorf("book: expects a book number or range, e.g. book:2 or book:2-5")
+	}
+	if !isRange {
+		return from, from, nil
+	}
+	to, err := strconv.Atoi(toStr)
+	if err != nil || to < from {
+		return 0, 0, parseErrorf("book: expects a book number or range, e.g. book:2 or book:2-5")
+	}
+	return from, to, nil
+}
+
+func (f Filter) isEmpty() bool {
+	return len(f.Collections) == 0 && len(f.Grades) == 0 && f.BookFrom == 0 && len(f.Topics) == 0
+}
+
+// queryToken is one lexed element of a query
+type queryToken struct {
+	text   string
+	field  string // set for field:value tokens
+	quoted bool
+	negate bool
+}
+
+// lexQuery splits the input on whitespace, keeping quoted phrases together
+// and recognising the - prefix and field: prefixes
+func lexQuery(input string) ([]queryToken, error) {
+	var tokens []queryToken
+	runes := []rune(strings.TrimSpace(input))
+
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		var tok queryToken
+		if runes[i] == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) {
+			tok.negate = true
+			i++
+		}
+
+		// field:value or field:"value"
+		j := i
+		for j < len(runes) && unicode.IsLetter(runes[j]) {
+			j++
+		}
+		if j < len(runes) && j > i && runes[j] == ':' {
+			name := strings.ToLower(string(runes[i:j]))
+			if queryFields[name] {
+				tok.field = name
+				i = j + 1
+			} else if field, ok := nearQueryField(name); ok {
+				return nil, parseErrorf("Unknown filter %q. Did you mean %s:?", name+":", field)
+			}
+			// Any other word: is searched for as it is, like "note:" in a
+			// pasted text
+		}
+
+		if i < len(runes) && runes[i] == '"' {
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end >= len(runes) {
+				return nil, parseErrorf("A quote is missing its closing \". Phrases look like \"fear Allah\".")
+			}
+			tok.text = strings.TrimSpace(string(runes[i+1 : end]))
+			tok.quoted = true
+			i = end + 1
+			if tok.text == "" && tok.field == "" {
+				return nil, parseErrorf("Empty quotes \"\" don't match anything; put a phrase inside them.")
+			}
+		} else {
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) {
+				end++
+			}
+			tok.text = string(runes[i:end])
+			i = end
+		}
+
+		tokens = append(tokens, tok)
+	}
+
+	return tokens, nil
+}
+
+// nearQueryField returns the field a mistyped field name is meant to be,
+// if it is within a typo or two of one
+func nearQueryField(name string) (string, bool) {
+	limit := maxSuggestDistance(utf8.RuneCountInString(name))
+	best, bestDist := "", limit+1
+	for field := range queryFields {
+		d := editDistance([]rune(name), []rune(field), limit)
+		if d < bestDist || (d == bestDist && field < best) {
+			best, bestDist = field, d
+		}
+	}
+	return best, bestDist <= limit
+}
+
+// Terms returns the positive (non-excluded) terms of the query
+func (q *Query) Terms() []Term {
+	var terms []Term
+	for _, group := range q.Groups {
+		terms = append(terms, group...)
+	}
+	return terms
+}
+
+// String renders the query back into the syntax ParseQuery accepts
+func (q *Query) String() string {
+	var parts []string
+	for i, group := range q.Groups {
+		if i > 0 {
+			parts = append(parts, "OR")
+		}
+		for _, t := range group {
+			parts = append(parts, t.String())
+		}
+	}
+	for _, t := range q.Exclude {
+		parts = append(parts, "-"+t.String())
+	}
+	if len(q.Filter.Collections) > 0 {
+		parts = append(parts, "collection:"+listString(q.Filter.Collections))
+	}
+	if len(q.Filter.Grades) > 0 {
+		parts = append(parts, "grade:"+listString(q.Filter.Grades))
+	}
+	if q.Filter.BookFrom > 0 {
+		if q.Filter.BookTo > q.Filter.BookFrom {
+			parts = append(parts, fmt.Sprintf("book:%d-%d", q.Filter.BookFrom, q.Filter.BookTo))
+		} else {
+			parts = append(parts, fmt.Sprintf("book:%d", q.Filter.BookFrom))
+		}
+	}
+	if len(q.Filter.Topics) > 0 {
+		parts = append(parts, "topic:"+listString(q.Filter.Topics))
+	}
+	return strings.Join(parts, " ")
+}
+
+// listString renders a filter's values, quoting them when one contains a
+// space (e.g. grade:"hasan sahih")
+func listString(values []string) string {
+	list := strings.Join(values, ",")
+	if strings.ContainsAny(list, " \t") {
+		return `"` + list + `"`
+	}
+	return list
+}
+
+func (t Term) String() string {
+	text := t.Text
+	if t.Phrase || strings.ContainsAny(text, " \t") {
+		text = `"` + text + `"`
+	}
+	if t.Narrator {
+		return "narrator:" + text
+	}
+	return text
+}
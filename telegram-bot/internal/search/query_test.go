@@ -0,0 +1,155 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"hadith-bot/internal/models"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // Query.String() of the parsed query
+	}{
+		{"prayer", "prayer"},
+		{"prayer AND fasting", "prayer fasting"},
+		{"prayer OR fasting charity", "prayer OR fasting charity"},
+		{`"fear Allah" -wine`, `"fear Allah" -wine`},
+		{`narrator:"Abu Huraira" fasting`, `narrator:"Abu Huraira" fasting`},
+		{"collection:Bukhari,muslim grade:sahih book:2-5 intentions", "intentions collection:bukhari,muslim grade:sahih book:2-5"},
+		{"collection:bukhari", "collection:bukhari"},
+		{"topic:Prayer,fasting night", "night topic:prayer,fasting"},
+		// Words before a colon that are not near a field name are plain terms
+		{"color:red fasting", "color:red fasting"},
+		{"-note:wine prayer", "prayer -note:wine"},
+		{"ab:c", "ab:c"},
+	}
+
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.input)
+		if err != nil {
+			t.Errorf("ParseQuery(%q) returned error: %v", tt.input, err)
+			continue
+		}
+		if got := q.String(); got != tt.expected {
+			t.Errorf("ParseQuery(%q).String() = %q; want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	inputs := []string{
+		"",
+		`"unterminated phrase`,
+		"prayer OR",
+		"OR prayer",
+		"prayer OR OR fasting",
+		"-wine",
+		"narator:abu",
+		"colection:bukhari",
+		"-grdae:sahih prayer",
+		"book:two",
+		"book:5-2",
+		"collection:",
+		"topic:",
+		"-collection:bukhari prayer",
+	}
+
+	for _, input := range inputs {
+		_, err := ParseQuery(input)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("ParseQuery(%q) = %v; want a *ParseError", input, err)
+		}
+	}
+
+	// A mistyped field name is pointed at the field meant
+	if _, err := ParseQuery("narator:abu"); err == nil || !strings.Contains(err.Error(), "Did you mean narrator:?") {
+		t.Errorf("ParseQuery(narator:abu) = %v; want a suggestion of narrator:", err)
+	}
+}
+
+func TestQuerySearch(t *testing.T) {
+	idx := NewIndex(&models.CollectionData{
+		Collections: []models.Collection{{Name: "bukhari"}, {Name: "muslim"}},
+		Hadiths: map[string][]models.Hadith{
+			"bukhari": {
+				{HadithNumber: 1, ChapterID: 1, Grade: "Sahih", English: "Fear Allah wherever you are.", Narrator: "Narrated Abu Dharr:"},
+				{HadithNumber: 2, ChapterID: 2, Grade: "Sahih", English: "Allah, fear Him in prayer.", Narrator: "Narrated Abu Huraira:", Topics: []string{"prayer"}},
+				{HadithNumber: 3, ChapterID: 3, Grade: "Sahih", English: "Every intoxicant is wine.", Narrator: "Narrated Ibn Umar:"},
+			},
+			"muslim": {
+				{HadithNumber: 4, ChapterID: 2, Grade: "Hasan", English: "Fasting is a shield from the fire.", Narrator: "Abu Huraira reported:", Topics: []string{"fasting"}},
+				{HadithNumber: 5, ChapterID: 5, Grade: "Daif", English: "Fear the fire even with half a date.", Narrator: "Adi reported:", Topics: []string{"charity"}},
+			},
+		},
+	})
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{`"fear Allah"`, []string{"bukhari:1"}},
+		{"fear Allah", []string{"bukhari:1", "bukhari:2"}},
+		{"wine OR shield", []string{"bukhari:3", "muslim:4"}},
+		{"fear -prayer", []string{"bukhari:1", "muslim:5"}},
+		{`narrator:"Abu Huraira"`, []string{"bukhari:2", "muslim:4"}},
+		{`narrator:huraira fire`, []string{"muslim:4"}},
+		{"fire collection:muslim grade:hasan", []string{"muslim:4"}},
+		{"fire grade:daif,hasan", []string{"muslim:4", "muslim:5"}},
+		{"book:2", []string{"bukhari:2", "muslim:4"}},
+		{"book:2-3 -wine", []string{"bukhari:2", "muslim:4"}},
+		{"fire topic:fasting", []string{"muslim:4"}},
+		{"topic:prayer,charity", []string{"bukhari:2", "muslim:5"}},
+		{"!!!", nil},
+	}
+
+	for _, tt := range tests {
+		got := hitRefs(search(t, idx, tt.query))
+		if fmt.Sprint(got) != fmt.Sprint(tt.expected) {
+			t.Errorf("Search(%q) = %v; want %v", tt.query, got, tt.expected)
+		}
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	text := "The Prophet said: <Prayer> in congregation is twenty-seven times superior to prayer offered alone, so keep to the congregation."
+
+	got, ok := Snippet(text, []string{"prayer"}, 60)
+	if !ok {
+		t.Fatal("expected a match")
+	}
+	want := "The Prophet said: &lt;<b>Prayer</b>&gt; in congregation is twenty-seven…"
+	if got != want {
+		t.Errorf("Snippet = %q; want %q", got, want)
+	}
+
+	got, _ = Snippet(text, []string{"alone"}, 40)
+	if !strings.Contains(got, "<b>alone</b>") {
+		t.Errorf("Snippet should centre on the match, got %q", got)
+	}
+	if !strings.HasPrefix(got, "…") {
+		t.Errorf("Snippet starting mid-text should begin with an ellipsis, got %q", got)
+	}
+
+	if _, ok := Snippet(text, []string{"fasting"}, 40); ok {
+		t.Error("expected no match for an absent term")
+	}
+
+	// A matched word crossing the end of the window is cut there
+	long := strings.Repeat("a", 200)
+	for _, text := range []string{long, "Said " + long + " then", strings.Repeat("word ", 30) + long} {
+		got, ok := Snippet(text, []string{long[:10]}, 160)
+		if !ok || !strings.Contains(got, "<b>aaaa") || !strings.HasSuffix(got, "</b>…") {
+			t.Errorf("Snippet of a %d-byte text with a long match = %q", len(text), got)
+		}
+	}
+
+	h := &models.Hadith{English: "", Arabic: "إِنَّمَا الأَعْمَالُ بِالنِّيَّاتِ"}
+	if got := SnippetFor(h, []string{"اعمال"}, 40); !strings.Contains(got, "<b>الأَعْمَالُ</b>") {
+		t.Errorf("SnippetFor should highlight the Arabic match, got %q", got)
+	}
+}
@@ -0,0 +1,125 @@
+package search
+
+import "math"
+
+// similarFields are compared by Similar. The narrator is left out: sharing a
+// narrator does not make two reports the same report.
+var similarFields = []Field{FieldEnglish, FieldArabic}
+
+// Tuning for Similar. Terms in more than maxSimilarDocShare of all hadiths
+// (particles, "Allah", "messenger") say little about a text and are skipped,
+// which also keeps lookups fast. Hadiths scoring below minSimilarity share
+// only incidental words and are not reported.
+const (
+	maxSimilarDocShare = 0.1
+	minSimilarity      = 0.2
+)
+
+// computeNorms stores the length of every doc's TF-IDF vector per field, for
+// cosine similarity
+func (idx *Index) computeNorms() {
+	for _, f := range similarFields {
+		norms := make([]float64, len(idx.docs))
+		for _, postings := range idx.postings[f] {
+			idf := idx.similarIDF(len(postings))
+			for _, p := range postings {
+				w := float64(p.tf) * idf
+				norms[p.doc] += w * w
+			}
+		}
+		for i := range norms {
+			norms[i] = math.Sqrt(norms[i])
+		}
+		idx.norms[f] = norms
+	}
+}
+
+// similarIDF is the plain inverse document frequency used for similarity
+// vectors; 0 for terms too common to compare on
+func (idx *Index) similarIDF(docFreq int) float64 {
+	n := float64(len(idx.docs))
+	if docFreq == 0 || float64(docFreq) > maxSimilarDocShare*n {
+		return 0
+	}
+	return math.Log(n / float64(docFreq))
+}
+
+// DocFor returns the doc ID of the hadith at offset in a collection's
+// hadiths, as passed to NewIndex
+func (idx *Index) DocFor(collection string, offset int) (DocID, bool) {
+	start, ok := idx.collectionStart[collection]
+	if !ok || offset < 0 {
+		return 0, false
+	}
+	id := start + DocID(offset)
+	if int(id) >= len(idx.docs) || idx.docs[id].Collection != collection {
+		return 0, false
+	}
+	return id, true
+}
+
+// Similar returns up to limit hadiths whose English and Arabic texts are
+// closest to the given hadith's, by cosine similarity of TF-IDF term vectors,
+// most similar first. The score of each hit, from 0 to 1, is its similarity
+// averaged over the fields both hadiths have text in, so a collection without
+// translations is compared on the Arabic alone. This tends to find the same
+// report as narrated in other collections.
+func (idx *Index) Similar(id DocID, limit int) []Hit {
+	if int(id) < 0 || int(id) >= len(idx.docs) || limit <= 0 {
+		return nil
+	}
+
+	scores := make([]float64, len(idx.docs))
+	for _, f := range similarFields {
+		norm := idx.norms[f][id]
+		if norm == 0 {
+			continue
+		}
+
+		counts := make(map[string]int)
+		for _, token := range Tokenize(idx.docs[id].text[f]) {
+			counts[token]++
+		}
+
+		dots := make(map[DocID]float64)
+		for term, tf := range counts {
+			postings := idx.postings[f][term]
+			idf := idx.similarIDF(len(postings))
+			if idf == 0 {
+				continue
+			}
+			w := float64(tf) * idf
+			for _, p := range postings {
+				dots[p.doc] += w * float64(p.tf) * idf
+			}
+		}
+		for doc, dot := range dots {
+			if other := idx.norms[f][doc]; other > 0 {
+				scores[doc] += dot / (norm * other)
+			}
+		}
+	}
+
+	var hits []Hit
+	for doc, score := range scores {
+		if DocID(doc) == id || score == 0 {
+			continue
+		}
+		shared := 0
+		for _, f := range similarFields {
+			if idx.norms[f][id] > 0 && idx.norms[f][doc] > 0 {
+				shared++
+			}
+		}
+		if score /= float64(shared); score < minSimilarity {
+			continue
+		}
+		d := idx.docs[doc]
+		hits = append(hits, Hit{Doc: DocID(doc), Collection: d.Collection, Offset: d.Offset, HadithNumber: d.HadithNumber, Score: score})
+	}
+	idx.sortHits(hits)
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
@@ -0,0 +1,159 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"hadith-bot/internal/models"
+)
+
+// HighlightTerms returns the normalized terms a result snippet should bold:
+// the tokens of every positive word and phrase in the query and their variants
+func (q *Query) HighlightTerms() []string {
+	var terms []string
+	for _, t := range q.Terms() {
+		terms = append(terms, Tokenize(t.Text)...)
+		for _, v := range t.Variants {
+			terms = append(terms, Tokenize(v)...)
+		}
+	}
+	return terms
+}
+
+// SnippetFor returns a short Telegram HTML excerpt of the hadith around the
+// first matched term, taken from the English text, then the Arabic, then the
+// narrator. When nothing matches (e.g. a filter-only query) it returns the
+// opening of the text without highlighting.
+func SnippetFor(h *models.Hadith, terms []string, width int) string {
+	for _, text := range []string{h.English, h.Arabic, h.Narrator} {
+		if snippet, ok := Snippet(text, terms, width); ok {
+			return snippet
+		}
+	}
+
+	text := h.English
+	if strings.TrimSpace(text) == "" {
+		text = h.Arabic
+	}
+	snippet, _ := Snippet(text, nil, width)
+	return snippet
+}
+
+// Snippet returns about width runes of text around the first term match,
+// HTML-escaped with every matching word wrapped in <b>. The boolean reports
+// whether any term matched; without a match the excerpt starts at the
+// beginning of the text.
+func Snippet(text string, terms []string, width int) (string, bool) {
+	type span struct {
+		start, end int
+		hit        bool
+	}
+
+	var spans []span
+	first := -1
+	forEachToken(text, func(token string, start, end int) {
+		hit := matchesAnyTerm(token, terms)
+		if hit && first < 0 {
+			first = start
+		}
+		spans = append(spans, span{start: start, end: end, hit: hit})
+	})
+
+	matched := first >= 0
+	from := 0
+	if matched {
+		// Show a little context before the match, starting on a word boundary
+		from = moveRunes(text, first, -width/3)
+		if from > 0 {
+			if i := strings.IndexFunc(text[from:first], unicode.IsSpace); i >= 0 {
+				from += i + 1
+			}
+		}
+	}
+	to := moveRunes(text, from, width)
+	if to < len(text) {
+		if i := strings.LastIndexFunc(text[from:to], unicode.IsSpace); i > 0 && from+i > first {
+			to = from + i
+		}
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	pos := from
+	for _, sp := range spans {
+		if sp.end <= from || sp.start >= to {
+			continue
+		}
+		if !sp.hit {
+			continue
+		}
+		// A word crossing an edge of the window, such as one longer than
+		// the window, is cut at the edge
+		start, end := max(sp.start, pos), min(sp.end, to)
+		b.WriteString(escapeSnippet(text[pos:start]))
+		b.WriteString("<b>")
+		b.WriteString(escapeSnippet(text[start:end]))
+		b.WriteString("</b>")
+		pos = end
+	}
+	b.WriteString(escapeSnippet(text[pos:to]))
+	if to < len(text) {
+		b.WriteString("…")
+	}
+
+	return strings.TrimSpace(b.String()), matched
+}
+
+// matchesAnyTerm reports whether a text token matches a query term, exactly or,
+// for long enough terms, by prefix as the index does
+func matchesAnyTerm(token string, terms []string) bool {
+	for _, term := range terms {
+		if token == term {
+			return true
+		}
+		if utf8.RuneCountInString(term) >= minPrefixRunes && strings.HasPrefix(token, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// moveRunes returns the byte offset n runes after (or, for negative n,
+// before) offset, clamped to the text
+func moveRunes(text string, offset, n int) int {
+	for ; n > 0 && offset < len(text); n-- {
+		_, size := utf8.DecodeRuneInString(text[offset:])
+		offset += size
+	}
+	for ; n < 0 && offset > 0; n++ {
+		_, size := utf8.DecodeLastRuneInString(text[:offset])
+		offset -= size
+	}
+	return offset
+}
+
+// escapeSnippet escapes text for Telegram HTML and collapses runs of
+// whitespace, including line breaks, so snippets stay compact in a list
+func escapeSnippet(text string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return html.EscapeString(b.String())
+}
@@ -0,0 +1,147 @@
+package search
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// maxSuggestDistance returns how many edits a term of n runes may be away
+// from a suggestion. Short words tolerate one typo, longer words two.
+func maxSuggestDistance(n int) int {
+	switch {
+	case n < 3:
+		return 0
+	case n < 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// Suggest returns a corrected copy of the query in which every word that
+// matches nothing in the index is replaced by the closest indexed term, and
+// whether anything was corrected. Words that already match, directly or
+// through a variant, are left alone. Corrected terms lose their variants, so
+// callers expanding synonyms should expand the suggestion again. Excluded
+// terms and filters are kept as they are.
+func (idx *Index) Suggest(q *Query) (*Query, bool) {
+	corrected := &Query{Exclude: q.Exclude, Filter: q.Filter}
+	changed := false
+
+	for _, group := range q.Groups {
+		var newGroup []Term
+		for _, term := range group {
+			fields := []Field{FieldEnglish, FieldArabic, FieldNarrator}
+			if term.Narrator {
+				fields = []Field{FieldNarrator}
+			}
+
+			if len(term.Variants) > 0 && idx.matchTerm(term).any() {
+				newGroup = append(newGroup, term)
+				continue
+			}
+
+			tokens := Tokenize(term.Text)
+			termChanged := false
+			for i, token := range tokens {
+				if idx.matchesAny(token, fields, term.Phrase) {
+					continue
+				}
+				if s := idx.closestTerm(token, fields); s != "" {
+					tokens[i] = s
+					termChanged = true
+				}
+			}
+			if termChanged {
+				term.Text = strings.Join(tokens, " ")
+				term.Variants = nil
+				changed = true
+			}
+			newGroup = append(newGroup, term)
+		}
+		corrected.Groups = append(corrected.Groups, newGroup)
+	}
+
+	return corrected, changed
+}
+
+// matchesAny reports whether a query token matches any indexed term in the
+// fields, the same way a search would
+func (idx *Index) matchesAny(token string, fields []Field, exact bool) bool {
+	for _, f := range fields {
+		if exact {
+			if _, ok := idx.postings[f][token]; ok {
+				return true
+			}
+			continue
+		}
+		if len(idx.expand(f, token)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// closestTerm returns the indexed term nearest to token by edit distance,
+// preferring terms found in more hadiths when distances tie, or "" when no
+// term is close enough
+func (idx *Index) closestTerm(token string, fields []Field) string {
+	runes := []rune(token)
+	maxDist := maxSuggestDistance(len(runes))
+	if maxDist == 0 {
+		return ""
+	}
+
+	best, bestDist, bestFreq := "", maxDist+1, 0
+	for _, f := range fields {
+		for _, candidate := range idx.vocab[f] {
+			n := utf8.RuneCountInString(candidate)
+			if n < len(runes)-maxDist || n > len(runes)+maxDist {
+				continue
+			}
+			d := editDistance(runes, []rune(candidate), bestDist)
+			if d > maxDist || d > bestDist {
+				continue
+			}
+			freq := len(idx.postings[f][candidate])
+			if d < bestDist || freq > bestFreq || (freq == bestFreq && candidate < best) {
+				best, bestDist, bestFreq = candidate, d, freq
+			}
+		}
+	}
+	return best
+}
+
+// editDistance returns the Damerau-Levenshtein (optimal string alignment)
+// distance between a and b, so a swapped pair of letters counts as one edit.
+// Once every alignment exceeds limit it stops early and returns limit+1.
+func editDistance(a, b []rune, limit int) int {
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d := min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d = min(d, prev2[j-2]+1)
+			}
+			cur[j] = d
+			rowMin = min(rowMin, d)
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,57 @@
+package search
+
+import "testing"
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"prayer", "prayer", 0},
+		{"prayr", "prayer", 1},
+		{"praeyr", "prayer", 1},
+		{"fastign", "fasting", 1},
+		{"huriara", "huraira", 1},
+		{"intention", "intentions", 1},
+		{"abc", "xyz", 3},
+	}
+
+	for _, tt := range tests {
+		if got := editDistance([]rune(tt.a), []rune(tt.b), 5); got != tt.expected {
+			t.Errorf("editDistance(%q, %q) = %d; want %d", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	idx := NewIndex(testData())
+
+	tests := []struct {
+		query    string
+		expected string // "" when no suggestion is expected
+	}{
+		{"prayr", "prayer"},
+		{"congregaton prayer", "congregation prayer"},
+		{`narrator:huriara`, "narrator:huraira"},
+		{"intentons -prayer collection:bukhari", "intentions -prayer collection:bukhari"},
+		{`"dawn prayr"`, `"dawn prayer"`},
+		{"prayer", ""},
+		{"zz", ""},
+		{"xylophone", ""},
+	}
+
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q) returned error: %v", tt.query, err)
+		}
+		suggested, ok := idx.Suggest(q)
+		got := ""
+		if ok {
+			got = suggested.String()
+		}
+		if got != tt.expected {
+			t.Errorf("Suggest(%q) = %q; want %q", tt.query, got, tt.expected)
+		}
+	}
+}
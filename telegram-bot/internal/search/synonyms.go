@@ -0,0 +1,168 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SynonymEntry is one word as users spell it: its Latin spelling variants
+// and, optionally, the Arabic term it transliterates
+type SynonymEntry struct {
+	Variants []string `json:"variants"`
+	Arabic   string   `json:"arabic,omitempty"`
+}
+
+// defaultSynonyms covers the transliterated terms and names people search
+// for most. Spellings that only differ in ways TranslitKey already folds
+// (wudu/wudhu, zakah/zakat) need not all be listed, but the spellings the
+// translations actually use must be, since those are what is searched.
+// Arabic terms are left out where the word is ambiguous (سنة is also "year").
+var defaultSynonyms = []SynonymEntry{
+	{Variants: []string{"wudu", "wudhu", "wuzu", "ablution"}, Arabic: "وضوء"},
+	{Variants: []string{"ghusl", "ghusal"}, Arabic: "غسل"},
+	{Variants: []string{"tayammum", "tayamum", "dry ablution"}, Arabic: "تيمم"},
+	{Variants: []string{"salah", "salat", "salaat", "namaz", "prayer"}, Arabic: "صلاة"},
+	{Variants: []string{"zakat", "zakah", "zakaat", "alms"}, Arabic: "زكاة"},
+	{Variants: []string{"sadaqa", "sadaqah", "sadaka", "charity"}, Arabic: "صدقة"},
+	{Variants: []string{"sawm", "siyam", "saum", "fasting"}, Arabic: "صيام"},
+	{Variants: []string{"hajj", "haj", "pilgrimage"}, Arabic: "حج"},
+	{Variants: []string{"umrah", "umra", "omra"}, Arabic: "عمرة"},
+	{Variants: []string{"jumuah", "jumua", "jumah", "jummah", "friday prayer"}, Arabic: "جمعة"},
+	{Variants: []string{"adhan", "azan", "athan", "call to prayer"}, Arabic: "أذان"},
+	{Variants: []string{"qiblah", "qibla", "kiblah"}, Arabic: "قبلة"},
+	{Variants: []string{"dua", "duaa", "du'a", "supplication"}, Arabic: "دعاء"},
+	{Variants: []string{"dhikr", "zikr", "thikr", "remembrance"}},
+	{Variants: []string{"jihad", "jehad"}, Arabic: "جهاد"},
+	{Variants: []string{"quran", "qur'an", "koran"}, Arabic: "قرآن"},
+	{Variants: []string{"sunnah", "sunna"}},
+	{Variants: []string{"janazah", "janaza", "funeral"}, Arabic: "جنازة"},
+	{Variants: []string{"ramadan", "ramadhan", "ramzan"}, Arabic: "رمضان"},
+	{Variants: []string{"aisha", "a'isha", "ayesha", "aysha", "aishah"}, Arabic: "عائشة"},
+	{Variants: []string{"huraira", "hurairah", "hurayrah", "hureira"}, Arabic: "هريرة"},
+	{Variants: []string{"umar", "omar"}, Arabic: "عمر"},
+	{Variants: []string{"uthman", "usman", "othman", "osman"}, Arabic: "عثمان"},
+	{Variants: []string{"abbas"}, Arabic: "عباس"},
+	{Variants: []string{"muadh", "mu'adh", "moaz", "muaz"}, Arabic: "معاذ"},
+	{Variants: []string{"anas"}, Arabic: "أنس"},
+}
+
+// synonymGroup is every spelling of one word
+type synonymGroup struct {
+	variants []string
+	arabic   []string
+}
+
+// Synonyms expands query terms to their spelling variants and Arabic terms.
+// Lookups go through TranslitKey, so a spelling that is not listed still
+// finds its group when it folds to the same key as one that is.
+type Synonyms struct {
+	byKey map[string]*synonymGroup
+}
+
+// NewSynonyms returns a dictionary of the given entries. Entries that share a
+// spelling are merged into one group.
+func NewSynonyms(entries []SynonymEntry) *Synonyms {
+	s := &Synonyms{byKey: make(map[string]*synonymGroup)}
+	for _, e := range entries {
+		s.Add(e)
+	}
+	return s
+}
+
+// DefaultSynonyms returns the built-in dictionary
+func DefaultSynonyms() *Synonyms {
+	return NewSynonyms(defaultSynonyms)
+}
+
+// LoadSynonymsFile reads a JSON array of entries, e.g.
+//
+//	[{"variants": ["wudu", "wudhu", "ablution"], "arabic": "وضوء"}]
+func LoadSynonymsFile(path string) ([]SynonymEntry, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []SynonymEntry
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i, e := range entries {
+		if len(e.Variants) == 0 {
+			return nil, fmt.Errorf("%s: entry %d has no variants", path, i)
+		}
+	}
+	return entries, nil
+}
+
+// Add adds an entry, merging it into any group that already has one of its
+// spellings
+func (s *Synonyms) Add(e SynonymEntry) {
+	var group *synonymGroup
+	for _, v := range e.Variants {
+		if g, ok := s.byKey[TranslitKey(v)]; ok {
+			group = g
+			break
+		}
+	}
+	if group == nil {
+		group = &synonymGroup{}
+	}
+
+	for _, v := range e.Variants {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		if !containsFold(group.variants, v) {
+			group.variants = append(group.variants, v)
+		}
+		if key := TranslitKey(v); key != "" {
+			s.byKey[key] = group
+		}
+	}
+	// The Arabic term is a key too, so Arabic queries find the translations
+	if a := strings.TrimSpace(e.Arabic); a != "" && !containsFold(group.arabic, a) {
+		group.arabic = append(group.arabic, a)
+		s.byKey[TranslitKey(a)] = group
+	}
+}
+
+// Variants returns the other spellings of text, Latin first and then
+// Arabic, or nil when text is not in the dictionary
+func (s *Synonyms) Variants(text string) []string {
+	if s == nil {
+		return nil
+	}
+	key := TranslitKey(text)
+	group, ok := s.byKey[key]
+	if !ok {
+		return nil
+	}
+
+	own := strings.Join(Tokenize(text), " ")
+	var variants []string
+	for _, v := range append(append([]string{}, group.variants...), group.arabic...) {
+		if strings.Join(Tokenize(v), " ") != own {
+			variants = append(variants, v)
+		}
+	}
+	return variants
+}
+
+// Expand sets the variants of every word, phrase and excluded term in the
+// query that the dictionary knows, so a search for "wudhu" also finds
+// "ablution" and "وضوء", and "-wudu" excludes them all
+func (s *Synonyms) Expand(q *Query) {
+	if s == nil {
+		return
+	}
+	for _, group := range q.Groups {
+		for i := range group {
+			group[i].Variants = s.Variants(group[i].Text)
+		}
+	}
+	for i := range q.Exclude {
+		q.Exclude[i].Variants = s.Variants(q.Exclude[i].Text)
+	}
+}
@@ -0,0 +1,158 @@
+package search
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"hadith-bot/internal/models"
+)
+
+func TestTranslitKey(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"wudu", "wudu"},
+		{"Wudhu", "wudu"},
+		{"wudoo", "wudu"},
+		{"zakah", "zaka"},
+		{"zakat", "zaka"},
+		{"zakaat", "zaka"},
+		{"sadaqa", "sadaqa"},
+		{"sadaqah", "sadaqa"},
+		{"A'isha", "aisha"},
+		{"ʿĀʾisha", "aisha"},
+		{"Ḥadīth", "hadith"},
+		{"Hurayrah", "huraira"},
+		{"Abu Hurairah", "abu huraira"},
+		{"وضوء", "وضوء"},
+	}
+
+	for _, tt := range tests {
+		if got := TranslitKey(tt.input); got != tt.expected {
+			t.Errorf("TranslitKey(%q) = %q; want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestSynonymsVariants(t *testing.T) {
+	syn := NewSynonyms([]SynonymEntry{
+		{Variants: []string{"wudu", "ablution"}, Arabic: "وضوء"},
+		{Variants: []string{"aisha", "ayesha"}},
+		{Variants: []string{"a'isha", "aishah"}}, // merged with the entry above
+	})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"wudhu", "[wudu ablution وضوء]"},
+		{"wudu", "[ablution وضوء]"},
+		{"الوضوء", "[wudu ablution]"},
+		{"Ayesha", "[aisha a'isha aishah]"},
+		{"prayer", "[]"},
+	}
+
+	for _, tt := range tests {
+		if got := fmt.Sprint(syn.Variants(tt.input)); got != tt.expected {
+			t.Errorf("Variants(%q) = %s; want %s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestSynonymSearch(t *testing.T) {
+	data := &models.CollectionData{
+		Collections: []models.Collection{{Name: "bukhari"}},
+		Hadiths: map[string][]models.Hadith{
+			"bukhari": {
+				{HadithNumber: 1, English: "Whoever performs ablution perfectly."},
+				{HadithNumber: 2, Arabic: "مَنْ تَوَضَّأَ فَأَحْسَنَ الْوُضُوءَ"},
+				{HadithNumber: 3, English: "Pay the zakat on your wealth.", Narrator: "Narrated 'Aishah:"},
+				{HadithNumber: 4, English: "The Ṣalāh is the pillar.", Narrator: "Narrated A'isha:"},
+			},
+		},
+	}
+	idx := NewIndex(data)
+	syn := NewSynonyms([]SynonymEntry{
+		{Variants: []string{"wudu", "ablution"}, Arabic: "وضوء"},
+		{Variants: []string{"zakat", "zakah"}},
+		{Variants: []string{"aisha", "aishah", "ayesha"}},
+	})
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"wudhu", "[bukhari:1 bukhari:2]"},
+		{"zakah", "[bukhari:3]"},
+		{"narrator:ayesha", "[bukhari:3 bukhari:4]"},
+		{"salah", "[bukhari:4]"},
+		{"wudu -ablution", "[]"},
+	}
+
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q) returned error: %v", tt.query, err)
+		}
+		syn.Expand(q)
+		if got := fmt.Sprint(hitRefs(idx.Search(q))); got != tt.expected {
+			t.Errorf("Search(%q) = %s; want %s", tt.query, got, tt.expected)
+		}
+	}
+}
+
+func TestLoadSynonymsFile(t *testing.T) {
+	dir := t.TempDir()
+
+	good := filepath.Join(dir, "good.json")
+	os.WriteFile(good, []byte(`[{"variants": ["tahajjud", "tahajud"], "arabic": "تهجد"}]`), 0644)
+	entries, err := LoadSynonymsFile(good)
+	if err != nil {
+		t.Fatalf("LoadSynonymsFile returned error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Arabic != "تهجد" {
+		t.Errorf("LoadSynonymsFile = %+v; want one entry for تهجد", entries)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	os.WriteFile(bad, []byte(`[{"arabic": "تهجد"}]`), 0644)
+	if _, err := LoadSynonymsFile(bad); err == nil {
+		t.Error("LoadSynonymsFile accepted an entry without variants")
+	}
+}
+
+func TestSynonymPhraseVariants(t *testing.T) {
+	idx := NewIndex(&models.CollectionData{
+		Collections: []models.Collection{{Name: "bukhari"}},
+		Hadiths: map[string][]models.Hadith{
+			"bukhari": {
+				{HadithNumber: 1, English: "He performed dry ablution with dust."},
+				{HadithNumber: 2, English: "The ground was dry, so he made ablution with water."},
+			},
+		},
+	})
+	syn := NewSynonyms([]SynonymEntry{{Variants: []string{"tayammum", "dry ablution"}}})
+
+	// The two words of the variant only match next to each other
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"tayammum", "[bukhari:1]"},
+		{"with -tayammum", "[bukhari:2]"},
+	}
+
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q) returned error: %v", tt.query, err)
+		}
+		syn.Expand(q)
+		if got := fmt.Sprint(hitRefs(idx.Search(q))); got != tt.expected {
+			t.Errorf("Search(%q) = %s; want %s", tt.query, got, tt.expected)
+		}
+	}
+}
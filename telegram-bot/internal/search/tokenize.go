@@ -0,0 +1,55 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Tokenize splits text into lowercase search terms. Letters, digits and
+// combining marks belong to a term; everything else separates terms. Arabic
+// terms are normalized (see NormalizeArabic) and lose their definite article,
+// and Latin terms lose transliteration accents and apostrophes ("A'isha" is
+// "aisha"), so the same call serves both indexed text and queries.
+func Tokenize(text string) []string {
+	var tokens []string
+	forEachToken(text, func(token string, _, _ int) {
+		tokens = append(tokens, token)
+	})
+	return tokens
+}
+
+// forEachToken calls fn with every term in text along with the byte offsets
+// of the raw text it came from
+func forEachToken(text string, fn func(token string, start, end int)) {
+	var b strings.Builder
+	start := -1
+
+	flush := func(end int) {
+		if start >= 0 && b.Len() > 0 {
+			if token := stripArabicArticle(NormalizeArabic(b.String())); token != "" {
+				fn(token, start, end)
+			}
+		}
+		b.Reset()
+		start = -1
+	}
+
+	for i, r := range text {
+		if isIgnorable(r) || isLatinMark(r) {
+			continue
+		}
+		if isTermRune(r) {
+			if start < 0 {
+				start = i
+			}
+			b.WriteRune(foldLatin(r))
+			continue
+		}
+		flush(i)
+	}
+	flush(len(text))
+}
+
+func isTermRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
@@ -0,0 +1,81 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// latinLetterForms maps the accented letters of scholarly transliteration
+// (ā, ḥ, ṣ, ...) to plain letters, so "Ḥadīth" and "hadith" are one term
+var latinLetterForms = map[rune]rune{
+	'ā': 'a', 'á': 'a', 'à': 'a', 'â': 'a', 'ä': 'a', 'ã': 'a',
+	'ī': 'i', 'í': 'i', 'ì': 'i', 'î': 'i', 'ï': 'i',
+	'ū': 'u', 'ú': 'u', 'ù': 'u', 'û': 'u', 'ü': 'u',
+	'ē': 'e', 'é': 'e', 'è': 'e', 'ê': 'e', 'ë': 'e',
+	'ō': 'o', 'ó': 'o', 'ò': 'o', 'ô': 'o', 'ö': 'o',
+	'ḥ': 'h', 'ḫ': 'h', 'ḍ': 'd', 'ḏ': 'd', 'ṣ': 's', 'š': 's',
+	'ṭ': 't', 'ṯ': 't', 'ẓ': 'z', 'ž': 'z', 'ġ': 'g', 'ğ': 'g',
+	'ç': 'c', 'ñ': 'n',
+}
+
+// isLatinMark reports whether r is dropped from Latin terms without splitting
+// them: apostrophes and the ayn/hamza signs written inside transliterated
+// words ("A'isha", "Muʿādh"), and combining accents
+func isLatinMark(r rune) bool {
+	switch r {
+	case '\'', '’', '‘', '`', '´', 'ʿ', 'ʾ', 'ʼ', 'ʻ':
+		return true
+	}
+	return r >= 0x0300 && r <= 0x036F
+}
+
+// foldLatin lowercases a rune and removes its transliteration accent
+func foldLatin(r rune) rune {
+	r = unicode.ToLower(r)
+	if plain, ok := latinLetterForms[r]; ok {
+		return plain
+	}
+	return r
+}
+
+// TranslitKey reduces a Latin transliteration to a loose spelling key, so the
+// common ways of writing one Arabic word share a key: doubled letters are
+// collapsed, "dh" becomes "d", "oo" and "ou" become "u", "ee" becomes "i",
+// "ay" becomes "ai", and a final "ah", "at" or "aa" becomes "a". Thus
+// "wudhu", "wudoo" and "wudu" all give "wudu", and "zakah", "zakat" and
+// "zakaat" give "zaka".
+// Words are tokenized first; non-Latin words are kept as tokenized.
+func TranslitKey(text string) string {
+	tokens := Tokenize(text)
+	for i, token := range tokens {
+		tokens[i] = translitToken(token)
+	}
+	return strings.Join(tokens, " ")
+}
+
+func translitToken(token string) string {
+	for _, r := range token {
+		if r > unicode.MaxASCII {
+			return token
+		}
+	}
+
+	token = strings.NewReplacer("dh", "d", "oo", "u", "ou", "u", "ee", "i", "ay", "ai").Replace(token)
+
+	var b strings.Builder
+	var last rune
+	for _, r := range token {
+		if r != last {
+			b.WriteRune(r)
+		}
+		last = r
+	}
+	token = b.String()
+
+	for _, ending := range []string{"ah", "at"} {
+		if len(token) > len(ending)+1 && strings.HasSuffix(token, ending) {
+			return strings.TrimSuffix(token, ending) + "a"
+		}
+	}
+	return token
+}
@@ -1,16 +1,37 @@
 package services
 
 import (
+	"fmt"
 	"hadith-bot/internal/data"
 	"hadith-bot/internal/logger"
 	"hadith-bot/internal/models"
+	"hadith-bot/internal/search"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
 
+// snippetWidth is the approximate length, in characters, of search result snippets
+const snippetWidth = 160
+
 // HadithService handles hadith-related operations
 type HadithService struct {
 	data       *models.CollectionData
+	index      *search.Index
+	synonyms   *search.Synonyms
+	narrators  *narratorIndex
+	topicDefs  *data.Topics
+	topics     *topicIndex
+	todayDefs  *data.Today
+	today      *todayIndex
+	todayLoc   *time.Location
+	rng        *rand.Rand // guarded by rngMu; see withRand
+	rngMu      sync.Mutex
+	weights    map[string]float64
+	report     *data.LoadReport
 	log        *logger.Logger
 	apiURL     string
 	apiKey     string
@@ -18,9 +39,14 @@ type HadithService struct {
 	mu         sync.RWMutex
 }
 
-// NewHadithService creates a new hadith service
-func NewHadithService(dataDir string, apiURL, apiKey string, apiTimeout time.Duration, log *logger.Logger) *HadithService {
+// NewHadithService creates a new hadith service. In strict mode any file that
+// fails to load is an error instead of being skipped, and there is no fallback
+// to the default data.
+func NewHadithService(dataDir string, strict bool, apiURL, apiKey string, apiTimeout time.Duration, log *logger.Logger) (*HadithService, error) {
 	s := &HadithService{
+		synonyms:   search.DefaultSynonyms(),
+		topicDefs:  data.DefaultTopics(),
+		todayDefs:  &data.Today{},
 		log:        log,
 		apiURL:     apiURL,
 		apiKey:     apiKey,
@@ -30,8 +56,40 @@ func NewHadithService(dataDir string, apiURL, apiKey string, apiTimeout time.Dur
 	// Try to load data from files first
 	if dataDir != "" {
 		s.log.Info("Attempting to load hadith data from: %s", dataDir)
-		if loadedData, err := data.LoadHadithData(dataDir); err == nil && loadedData != nil {
-			s.data = loadedData
+		topics, err := data.LoadTopics(dataDir)
+		if err != nil {
+			if strict {
+				return nil, err
+			}
+			s.log.Warn("Failed to load topics, using the built-in topics: %v", err)
+		} else {
+			s.topicDefs = topics
+		}
+		today, err := data.LoadToday(dataDir)
+		if err != nil {
+			if strict {
+				return nil, err
+			}
+			s.log.Warn("Failed to load the hadith of the day settings: %v", err)
+		} else {
+			s.todayDefs = today
+		}
+
+		loadedData, report, err := data.LoadHadithData(dataDir)
+		s.report = report
+		s.logLoadReport()
+
+		if strict {
+			if err != nil {
+				return nil, err
+			}
+			if report.HasErrors() {
+				return nil, fmt.Errorf("strict data load: %d file(s) failed to load from %s", report.ErrorCount(), dataDir)
+			}
+		}
+
+		if err == nil {
+			s.setData(loadedData)
 			// Log statistics
 			s.log.Info("Loaded hadith data - Collections: %d", len(s.data.Collections))
 			for _, c := range s.data.Collections {
@@ -40,17 +98,60 @@ func NewHadithService(dataDir string, apiURL, apiKey string, apiTimeout time.Dur
 				s.log.Info("Collection %s: Books=%d, Hadiths=%d", c.Name, len(books), len(hadiths))
 			}
 			s.log.Info("Loaded hadith data from files successfully")
-			return s
-		} else {
-			s.log.Warn("Failed to load hadith data: %v", err)
+			return s, nil
 		}
+		s.log.Warn("Failed to load hadith data: %v", err)
+	} else if strict {
+		return nil, fmt.Errorf("strict data load: no data directory found")
 	}
 
 	// Fall back to default data
-	s.data = data.GetDefaultCollectionData()
+	s.setData(data.GetDefaultCollectionData())
 	s.log.Info("Using default hadith data")
 
-	return s
+	return s, nil
+}
+
+// setData installs the collection data, tags it with topics and builds its
+// search index
+func (s *HadithService) setData(d *models.CollectionData) {
+	start := time.Now()
+	s.data = d
+	s.topics = buildTopicIndex(d, s.topicDefs)
+	s.index = search.NewIndex(d)
+	s.narrators = buildNarratorIndex(d)
+	s.today = s.buildToday(s.todayDefs)
+	s.log.Info("Built search index over %d hadiths in %v", s.index.Len(), time.Since(start))
+}
+
+// SetSynonyms replaces the spelling-variant dictionary used to expand search
+// terms. A nil dictionary turns expansion off.
+func (s *HadithService) SetSynonyms(synonyms *search.Synonyms) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.synonyms = synonyms
+}
+
+// LoadReport returns the report from loading the data directory, or nil when
+// no directory was loaded
+func (s *HadithService) LoadReport() *data.LoadReport {
+	return s.report
+}
+
+// logLoadReport writes one log line per data file, warning about failures
+// and entries with problems
+func (s *HadithService) logLoadReport() {
+	if s.report == nil {
+		return
+	}
+	for i := range s.report.Files {
+		f := &s.report.Files[i]
+		if f.Err != nil || len(f.Issues) > 0 {
+			s.log.Warn("Data file %s", f.SummaryLine())
+		} else {
+			s.log.Debug("Data file %s", f.SummaryLine())
+		}
+	}
 }
 
 // GetCollections returns all available collections
@@ -88,19 +189,40 @@ func (s *HadithService) GetHadiths(collection string, bookNumber int, page int,
 	return s.data.GetHadiths(collection, bookNumber, page, limit)
 }
 
-// SearchHadiths searches hadiths by keyword
-func (s *HadithService) SearchHadiths(query string, page int, limit int) models.SearchResult {
+// SearchHadiths searches hadiths using the query syntax described by
+// search.ParseQuery. A query that cannot be parsed returns a
+// *search.ParseError whose message can be shown to the user as is.
+func (s *HadithService) SearchHadiths(query string, page int, limit int) (models.SearchResult, error) {
+	return s.SearchHadithsFiltered(query, models.SearchFilter{}, page, limit)
+}
+
+// SearchHadithsFiltered is SearchHadiths restricted by a filter. Fields set
+// in the filter replace the same filters written in the query, and a
+// narrator is required on top of the query's words. The query may be empty
+// when the filter is not. The result includes facet counts per collection
+// and per grade over all matches.
+func (s *HadithService) SearchHadithsFiltered(query string, filter models.SearchFilter, page int, limit int) (models.SearchResult, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	// Validate input
-	if query == "" {
+	q, err := parseFilteredQuery(query, filter)
+	if err != nil {
+		return models.SearchResult{Hadiths: []models.Hadith{}, Page: page}, err
+	}
+	if q != nil {
+		if err := s.topics.resolveTopicFilter(q); err != nil {
+			return models.SearchResult{Hadiths: []models.Hadith{}, Page: page}, err
+		}
+		s.synonyms.Expand(q)
+	}
+	// Nothing to search for
+	if q == nil {
 		return models.SearchResult{
 			Hadiths:    []models.Hadith{},
 			Total:      0,
 			Page:       page,
 			TotalPages: 0,
-		}
+		}, nil
 	}
 
 	if page < 1 {
@@ -110,17 +232,292 @@ func (s *HadithService) SearchHadiths(query string, page int, limit int) models.
 		limit = 20
 	}
 
-	return s.data.SearchHadiths(query, page, limit)
+	hits := s.index.Search(q)
+
+	total := len(hits)
+	totalPages := (total + limit - 1) / limit
+	collectionFacets, gradeFacets := s.facets(hits)
+
+	start := (page - 1) * limit
+	if start >= total {
+		result := models.SearchResult{
+			Hadiths:          []models.Hadith{},
+			Total:            total,
+			Page:             page,
+			TotalPages:       totalPages,
+			CollectionFacets: collectionFacets,
+			GradeFacets:      gradeFacets,
+		}
+		if total == 0 {
+			result.Suggestion = s.suggest(q)
+		}
+		return result, nil
+	}
+
+	end := start + limit
+	if end > total {
+		end = total
+	}
+
+	terms := q.HighlightTerms()
+	hadiths := make([]models.Hadith, 0, end-start)
+	for _, hit := range hits[start:end] {
+		h := s.data.Hadiths[hit.Collection][hit.Offset]
+		h.CollectionName = hit.Collection // Save the collection name for the search result
+		h.Score = hit.Score
+		h.Snippet = search.SnippetFor(&h, terms, snippetWidth)
+		hadiths = append(hadiths, h)
+	}
+
+	return models.SearchResult{
+		Hadiths:          hadiths,
+		Total:            total,
+		Page:             page,
+		TotalPages:       totalPages,
+		CollectionFacets: collectionFacets,
+		GradeFacets:      gradeFacets,
+	}, nil
+}
+
+// NarrowQuery returns the query with the filter merged into it, written in
+// the query syntax, so a narrowed search can be carried in a button. A query
+// that cannot be parsed is returned unchanged.
+func (s *HadithService) NarrowQuery(query string, filter models.SearchFilter) string {
+	q, err := parseFilteredQuery(query, filter)
+	if err != nil || q == nil {
+		return query
+	}
+	return q.String()
+}
+
+// parseFilteredQuery parses a query and merges the filter into it. It
+// returns nil when there is nothing to search for.
+func parseFilteredQuery(query string, filter models.SearchFilter) (*search.Query, error) {
+	q := &search.Query{}
+	if strings.TrimSpace(query) != "" {
+		var err error
+		if q, err = search.ParseQuery(query); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(filter.Collections) > 0 {
+		q.Filter.Collections = lowerAll(filter.Collections)
+	}
+	if len(filter.Grades) > 0 {
+		q.Filter.Grades = lowerAll(filter.Grades)
+	}
+	if filter.BookFrom > 0 {
+		q.Filter.BookFrom, q.Filter.BookTo = filter.BookFrom, max(filter.BookTo, filter.BookFrom)
+	}
+	if len(filter.Topics) > 0 {
+		q.Filter.Topics = lowerAll(filter.Topics)
+	}
+	for _, word := range strings.Fields(filter.Narrator) {
+		term := search.Term{Text: word, Narrator: true}
+		if len(q.Groups) == 0 {
+			q.Groups = [][]search.Term{nil}
+		}
+		for i := range q.Groups {
+			q.Groups[i] = append(q.Groups[i], term)
+		}
+	}
+
+	if len(q.Groups) == 0 && len(q.Filter.Collections) == 0 && len(q.Filter.Grades) == 0 && q.Filter.BookFrom == 0 && len(q.Filter.Topics) == 0 {
+		return nil, nil
+	}
+	return q, nil
+}
+
+func lowerAll(values []string) []string {
+	lowered := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			lowered = append(lowered, v)
+		}
+	}
+	return lowered
+}
+
+// facets counts the hits per collection and per grade, most common first.
+// Hadiths without a grade are left out of the grade facets.
+func (s *HadithService) facets(hits []search.Hit) (collections, grades []models.Facet) {
+	collectionCounts := make(map[string]int)
+	gradeCounts := make(map[string]int)
+	for _, hit := range hits {
+		collectionCounts[hit.Collection]++
+		if grade := strings.TrimSpace(s.index.Doc(hit.Doc).Grade); grade != "" {
+			gradeCounts[grade]++
+		}
+	}
+	return sortedFacets(collectionCounts), sortedFacets(gradeCounts)
+}
+
+func sortedFacets(counts map[string]int) []models.Facet {
+	facets := make([]models.Facet, 0, len(counts))
+	for value, count := range counts {
+		facets = append(facets, models.Facet{Value: value, Count: count})
+	}
+	sort.Slice(facets, func(i, j int) bool {
+		if facets[i].Count != facets[j].Count {
+			return facets[i].Count > facets[j].Count
+		}
+		return facets[i].Value < facets[j].Value
+	})
+	return facets
 }
 
-// GetRandomHadith returns a random hadith
-func (s *HadithService) GetRandomHadith() models.RandomHadithResult {
+// GetRandomHadith returns a random hadith. Unless collection weights are set
+// it draws from the major collections, every hadith equally likely; see
+// defaultWeights. Hadiths for which exclude returns true are skipped;
+// exclude may be nil. The result is empty when there is no hadith left to
+// pick.
+func (s *HadithService) GetRandomHadith(exclude func(collection string, hadithNumber int) bool) models.RandomHadithResult {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return s.data.GetRandomHadith()
+
+	weights := s.weights
+	if weights == nil {
+		weights = s.defaultWeights()
+	}
+	var res models.RandomHadithResult
+	s.withRand(func(rng *rand.Rand) {
+		res = s.data.GetRandomHadith(rng, weights, exclude)
+	})
+	return res
+}
+
+// defaultWeights returns the collection weights random picks use when none
+// are set: 0 for every loaded collection outside MajorCollections, so the
+// others, whose translations are often missing, only come up when asked for
+// by name. With none of the major collections loaded it returns nil, leaving
+// every collection in. The caller holds s.mu.
+func (s *HadithService) defaultWeights() map[string]float64 {
+	if !s.hasMajorCollection() {
+		return nil
+	}
+	weights := make(map[string]float64)
+	for _, c := range s.data.Collections {
+		if !isMajorCollection(c.Name) {
+			weights[c.Name] = 0
+		}
+	}
+	return weights
+}
+
+// hasMajorCollection reports whether any of MajorCollections is loaded. The
+// caller holds s.mu.
+func (s *HadithService) hasMajorCollection() bool {
+	for _, c := range s.data.Collections {
+		if isMajorCollection(c.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+func isMajorCollection(name string) bool {
+	return slices.Contains(MajorCollections, name)
+}
+
+// GetRandomHadithMatching returns a random hadith the filter allows, as the
+// search filters do, skipping those for which exclude returns true; exclude
+// may be nil. An empty filter picks as GetRandomHadith does. It returns false
+// for an unknown topic or when no hadith is left to pick.
+func (s *HadithService) GetRandomHadithMatching(filter models.SearchFilter, exclude func(collection string, hadithNumber int) bool) (models.RandomHadithResult, bool) {
+	q, err := parseFilteredQuery("", filter)
+	if err != nil {
+		return models.RandomHadithResult{}, false
+	}
+	if q == nil {
+		res := s.GetRandomHadith(exclude)
+		return res, res.Hadith != nil && res.Collection != nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := s.topics.resolveTopicFilter(q); err != nil {
+		return models.RandomHadithResult{}, false
+	}
+	hits := s.index.Search(q)
+
+	// Hits come ranked; a seeded pick must not depend on ranking
+	sort.Slice(hits, func(i, j int) bool { return hits[i].Doc < hits[j].Doc })
+	refs := make([]hadithRef, len(hits))
+	for i, hit := range hits {
+		refs[i] = hadithRef{collection: hit.Collection, offset: hit.Offset}
+	}
+	return s.pickRef(refs, exclude)
+}
+
+// pickRef returns a random hadith of refs, skipping those for which exclude
+// returns true. The caller holds s.mu.
+func (s *HadithService) pickRef(refs []hadithRef, exclude func(collection string, hadithNumber int) bool) (models.RandomHadithResult, bool) {
+	if exclude != nil {
+		var left []hadithRef
+		for _, ref := range refs {
+			if !exclude(ref.collection, s.data.Hadiths[ref.collection][ref.offset].HadithNumber) {
+				left = append(left, ref)
+			}
+		}
+		refs = left
+	}
+	if len(refs) == 0 {
+		return models.RandomHadithResult{}, false
+	}
+	var ref hadithRef
+	s.withRand(func(rng *rand.Rand) {
+		ref = refs[rng.IntN(len(refs))]
+	})
+
+	hadith := s.data.Hadiths[ref.collection][ref.offset]
+	collection := s.data.GetCollection(ref.collection)
+	if collection == nil {
+		return models.RandomHadithResult{}, false
+	}
+	return models.RandomHadithResult{
+		Hadith:     &hadith,
+		Collection: collection,
+		Book:       s.data.GetBook(ref.collection, hadith.ChapterID),
+	}, true
+}
+
+// SetRandom replaces the source of random picks, e.g. with a seeded one so
+// picks can be reproduced
+func (s *HadithService) SetRandom(rng *rand.Rand) {
+	s.rngMu.Lock()
+	defer s.rngMu.Unlock()
+	s.rng = rng
+}
+
+// SetCollectionWeights makes GetRandomHadith favour some collections: each
+// hadith's chance is multiplied by its collection's weight, 1 by default.
+// A weight of 0 leaves a collection out of random picks. Any weights replace
+// the default of the major collections only.
+func (s *HadithService) SetCollectionWeights(weights map[string]float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.weights = weights
 }
 
-// CollectionNames returns display names for collections
+// withRand calls f with the random source, which is not safe for concurrent
+// use, creating an unseeded one on first use
+func (s *HadithService) withRand(f func(rng *rand.Rand)) {
+	s.rngMu.Lock()
+	defer s.rngMu.Unlock()
+	if s.rng == nil {
+		s.rng = rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	}
+	f(s.rng)
+}
+
+// MajorCollections are the six books. Random picks and the hadith of the day
+// draw from them by default when any of them is loaded.
+var MajorCollections = []string{"bukhari", "muslim", "abudawud", "tirmidhi", "nasai", "ibnmajah"}
+
+// CollectionNames holds fallback display names for collections whose data
+// files could not be loaded. Loaded collections use their own metadata title.
 var CollectionNames = map[string]string{
 	"bukhari":  "Sahih al-Bukhari",
 	"muslim":   "Sahih Muslim",
@@ -130,6 +527,20 @@ var CollectionNames = map[string]string{
 	"ibnmajah": "Sunan Ibn Majah",
 }
 
+// suggest returns a spelling-corrected version of a query that matched
+// nothing, or "" when there is no correction that finds anything
+func (s *HadithService) suggest(q *search.Query) string {
+	corrected, ok := s.index.Suggest(q)
+	if !ok {
+		return ""
+	}
+	s.synonyms.Expand(corrected)
+	if len(s.index.Search(corrected)) == 0 {
+		return ""
+	}
+	return corrected.String()
+}
+
 // GetCollectionDisplayName returns the display name for a collection
 func GetCollectionDisplayName(collection string) string {
 	if name, ok := CollectionNames[collection]; ok {
@@ -138,6 +549,15 @@ func GetCollectionDisplayName(collection string) string {
 	return collection
 }
 
+// GetCollectionDisplayName returns the display name for a collection,
+// preferring the title read from the collection's metadata
+func (s *HadithService) GetCollectionDisplayName(collection string) string {
+	if c := s.GetCollection(collection); c != nil && c.Title != "" {
+		return c.Title
+	}
+	return GetCollectionDisplayName(collection)
+}
+
 // FindHadithByNumber looks for a specific hadith by its number within a collection
 func (s *HadithService) FindHadithByNumber(collectionName string, hadithNum int) (*models.Hadith, *models.Book) {
 	s.mu.RLock()
@@ -167,3 +587,34 @@ func (s *HadithService) FindHadithByNumber(collectionName string, hadithNum int)
 
 	return foundHadith, book
 }
+
+// SimilarHadiths returns up to limit hadiths from any loaded collection whose
+// text is most like the given hadith's, most similar first, with
+// CollectionName and an opening Snippet set and Score holding the similarity
+// from 0 to 1
+func (s *HadithService) SimilarHadiths(collectionName string, hadithNum int, limit int) []models.Hadith {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	offset := -1
+	for i, h := range s.data.Hadiths[collectionName] {
+		if h.HadithNumber == hadithNum {
+			offset = i
+			break
+		}
+	}
+	id, ok := s.index.DocFor(collectionName, offset)
+	if !ok {
+		return nil
+	}
+
+	var similar []models.Hadith
+	for _, hit := range s.index.Similar(id, limit) {
+		h := s.data.Hadiths[hit.Collection][hit.Offset]
+		h.CollectionName = hit.Collection
+		h.Score = hit.Score
+		h.Snippet = search.SnippetFor(&h, nil, snippetWidth)
+		similar = append(similar, h)
+	}
+	return similar
+}
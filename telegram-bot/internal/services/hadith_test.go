@@ -0,0 +1,234 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"hadith-bot/internal/data"
+	"hadith-bot/internal/models"
+	"hadith-bot/internal/search"
+)
+
+func newSearchTestService() *HadithService {
+	s := &HadithService{
+		data: &models.CollectionData{
+			Collections: []models.Collection{{Name: "bukhari"}, {Name: "muslim"}},
+			Hadiths: map[string][]models.Hadith{
+				"bukhari": {
+					{HadithNumber: 1, ChapterID: 1, English: "Prayer at its time.", Narrator: "Abu Huraira", Grade: "Sahih"},
+					{HadithNumber: 2, ChapterID: 2, English: "Prayer in congregation.", Narrator: "Ibn Umar", Grade: "Sahih"},
+					{HadithNumber: 3, ChapterID: 3, English: "Prayer at night.", Narrator: "Aisha", Grade: "Hasan Sahih"},
+				},
+				"muslim": {
+					{HadithNumber: 10, ChapterID: 1, English: "The dawn prayer.", Narrator: "Abu Huraira", Grade: "Sahih"},
+					{HadithNumber: 11, ChapterID: 1, English: "Fasting is a shield.", Narrator: "Abu Huraira"},
+				},
+			},
+		},
+	}
+	s.topics = buildTopicIndex(s.data, &data.Topics{
+		Topics: []data.TopicDef{{ID: "prayer", Name: "Prayer"}, {ID: "fasting", Name: "Fasting"}, {ID: "night", Name: "Night Prayer"}},
+		Tags: map[string]map[string][]string{
+			"bukhari": {"1": {"prayer"}, "3": {"prayer", "night"}},
+			"muslim":  {"11": {"fasting"}},
+		},
+	})
+	s.index = search.NewIndex(s.data)
+	return s
+}
+
+// resultRefs lists the results as sorted collection:number references, so
+// tests check what matched rather than the ranking
+func resultRefs(res models.SearchResult) []string {
+	var refs []string
+	for _, h := range res.Hadiths {
+		refs = append(refs, fmt.Sprintf("%s:%d", h.CollectionName, h.HadithNumber))
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+func TestSearchHadithsFiltered(t *testing.T) {
+	s := newSearchTestService()
+
+	tests := []struct {
+		query    string
+		filter   models.SearchFilter
+		expected string
+	}{
+		{"prayer", models.SearchFilter{}, "[bukhari:1 bukhari:2 bukhari:3 muslim:10]"},
+		{"prayer", models.SearchFilter{Collections: []string{"Muslim"}}, "[muslim:10]"},
+		{"prayer collection:muslim", models.SearchFilter{Collections: []string{"bukhari"}}, "[bukhari:1 bukhari:2 bukhari:3]"},
+		{"prayer", models.SearchFilter{Grades: []string{"hasan sahih"}}, "[bukhari:3]"},
+		{"prayer", models.SearchFilter{BookFrom: 2, BookTo: 3}, "[bukhari:2 bukhari:3]"},
+		{"prayer", models.SearchFilter{Narrator: "abu huraira"}, "[bukhari:1 muslim:10]"},
+		{"", models.SearchFilter{Narrator: "huraira", Collections: []string{"muslim"}}, "[muslim:10 muslim:11]"},
+		{"", models.SearchFilter{}, "[]"},
+	}
+
+	for _, tt := range tests {
+		res, err := s.SearchHadithsFiltered(tt.query, tt.filter, 1, 10)
+		if err != nil {
+			t.Fatalf("SearchHadithsFiltered(%q, %+v) returned error: %v", tt.query, tt.filter, err)
+		}
+		if got := fmt.Sprint(resultRefs(res)); got != tt.expected {
+			t.Errorf("SearchHadithsFiltered(%q, %+v) = %s; want %s", tt.query, tt.filter, got, tt.expected)
+		}
+	}
+}
+
+func TestSearchFacets(t *testing.T) {
+	s := newSearchTestService()
+
+	res, err := s.SearchHadithsFiltered("prayer", models.SearchFilter{}, 1, 1)
+	if err != nil {
+		t.Fatalf("SearchHadithsFiltered returned error: %v", err)
+	}
+
+	if got, want := fmt.Sprint(res.CollectionFacets), "[{bukhari 3} {muslim 1}]"; got != want {
+		t.Errorf("CollectionFacets = %s; want %s", got, want)
+	}
+	if got, want := fmt.Sprint(res.GradeFacets), "[{Sahih 3} {Hasan Sahih 1}]"; got != want {
+		t.Errorf("GradeFacets = %s; want %s", got, want)
+	}
+}
+
+func TestNarrowQuery(t *testing.T) {
+	s := newSearchTestService()
+
+	tests := []struct {
+		query    string
+		filter   models.SearchFilter
+		expected string
+	}{
+		{"prayer", models.SearchFilter{Collections: []string{"bukhari"}}, "prayer collection:bukhari"},
+		{"prayer collection:bukhari,muslim", models.SearchFilter{Collections: []string{"muslim"}}, "prayer collection:muslim"},
+		{"prayer", models.SearchFilter{Grades: []string{"Hasan Sahih"}}, `prayer grade:"hasan sahih"`},
+		{"prayer OR fasting", models.SearchFilter{Narrator: "Aisha"}, "prayer narrator:Aisha OR fasting narrator:Aisha"},
+		{`"unclosed`, models.SearchFilter{Collections: []string{"bukhari"}}, `"unclosed`},
+	}
+
+	for _, tt := range tests {
+		if got := s.NarrowQuery(tt.query, tt.filter); got != tt.expected {
+			t.Errorf("NarrowQuery(%q, %+v) = %q; want %q", tt.query, tt.filter, got, tt.expected)
+		}
+	}
+}
+
+func TestSimilarHadiths(t *testing.T) {
+	s := &HadithService{
+		data: &models.CollectionData{
+			Collections: []models.Collection{{Name: "bukhari"}, {Name: "muslim"}, {Name: "darimi"}},
+			Hadiths: map[string][]models.Hadith{
+				"bukhari": {
+					{HadithNumber: 1, English: "Actions are judged by intentions, and every person gets what he intended.", Arabic: "إنما الأعمال بالنيات وإنما لكل امرئ ما نوى"},
+					{HadithNumber: 2, English: "Fasting is a shield.", Arabic: "الصيام جنة"},
+				},
+				"muslim": {
+					{HadithNumber: 1907, English: "Deeds are judged by intentions; every person gets what he intended.", Arabic: "إنما الأعمال بالنية وإنما لامرئ ما نوى"},
+					{HadithNumber: 5, English: "Cleanliness is half of faith.", Arabic: "الطهور شطر الإيمان"},
+				},
+				"darimi": {
+					{HadithNumber: 10, Arabic: "إنما الأعمال بالنيات ولكل امرئ ما نوى"},
+					{HadithNumber: 11, Arabic: "من صام رمضان إيمانا"},
+				},
+			},
+		},
+	}
+	for i := 0; i < 20; i++ {
+		// Filler so common words are rare enough to count
+		s.data.Hadiths["darimi"] = append(s.data.Hadiths["darimi"], models.Hadith{HadithNumber: 100 + i, English: fmt.Sprintf("Filler text number %d.", i), Arabic: "نص"})
+	}
+	s.index = search.NewIndex(s.data)
+
+	got := fmt.Sprint(resultRefs(models.SearchResult{Hadiths: s.SimilarHadiths("bukhari", 1, 5)}))
+	if want := "[darimi:10 muslim:1907]"; got != want {
+		t.Errorf("SimilarHadiths(bukhari, 1) = %s; want %s", got, want)
+	}
+
+	if similar := s.SimilarHadiths("bukhari", 999, 5); similar != nil {
+		t.Errorf("SimilarHadiths(bukhari, 999) = %v; want nil", similar)
+	}
+}
+
+func TestGetRandomHadithDefaultPool(t *testing.T) {
+	newService := func(names ...string) *HadithService {
+		d := &models.CollectionData{Hadiths: make(map[string][]models.Hadith)}
+		for _, name := range names {
+			d.Collections = append(d.Collections, models.Collection{Name: name})
+			d.Hadiths[name] = []models.Hadith{{HadithNumber: 1}, {HadithNumber: 2}}
+		}
+		return &HadithService{data: d}
+	}
+
+	tests := []struct {
+		name        string
+		collections []string
+		weights     map[string]float64
+		expected    string // the collections picked from, sorted
+	}{
+		{"major collections only", []string{"bukhari", "darimi", "muslim", "ahmad"}, nil, "[bukhari muslim]"},
+		{"none major", []string{"darimi", "ahmad"}, nil, "[ahmad darimi]"},
+		{"weights replace the default", []string{"bukhari", "darimi"}, map[string]float64{"bukhari": 2}, "[bukhari darimi]"},
+	}
+
+	for _, tt := range tests {
+		s := newService(tt.collections...)
+		if tt.weights != nil {
+			s.SetCollectionWeights(tt.weights)
+		}
+		picked := make(map[string]bool)
+		for i := 0; i < 200; i++ {
+			picked[s.GetRandomHadith(nil).Collection.Name] = true
+		}
+		var names []string
+		for name := range picked {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if got := fmt.Sprint(names); got != tt.expected {
+			t.Errorf("%s: picked from %s; want %s", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestGetRandomHadithMatching(t *testing.T) {
+	s := newSearchTestService()
+
+	tests := []struct {
+		filter   models.SearchFilter
+		exclude  int    // hadith number to skip, 0 for none
+		expected string // the hadiths that may be picked, sorted; "[]" for none
+	}{
+		{models.SearchFilter{Collections: []string{"Muslim"}}, 0, "[muslim:10 muslim:11]"},
+		{models.SearchFilter{Collections: []string{"bukhari"}, BookFrom: 2, BookTo: 3}, 0, "[bukhari:2 bukhari:3]"},
+		{models.SearchFilter{Collections: []string{"bukhari"}, BookFrom: 2, BookTo: 3}, 2, "[bukhari:3]"},
+		{models.SearchFilter{Topics: []string{"Prayer"}, Grades: []string{"sahih"}}, 0, "[bukhari:1 bukhari:3]"},
+		{models.SearchFilter{Topics: []string{"zakat"}}, 0, "[]"},
+		{models.SearchFilter{Collections: []string{"muslim"}, Topics: []string{"night"}}, 0, "[]"},
+	}
+
+	for _, tt := range tests {
+		var exclude func(string, int) bool
+		if tt.exclude != 0 {
+			exclude = func(_ string, number int) bool { return number == tt.exclude }
+		}
+		picked := make(map[string]bool)
+		for i := 0; i < 50; i++ {
+			res, ok := s.GetRandomHadithMatching(tt.filter, exclude)
+			if !ok {
+				break
+			}
+			picked[fmt.Sprintf("%s:%d", res.Collection.Name, res.Hadith.HadithNumber)] = true
+		}
+		var refs []string
+		for ref := range picked {
+			refs = append(refs, ref)
+		}
+		sort.Strings(refs)
+		if got := fmt.Sprint(refs); got != tt.expected {
+			t.Errorf("GetRandomHadithMatching(%+v) picked %s; want %s", tt.filter, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"hadith-bot/internal/models"
+	"hadith-bot/internal/search"
+)
+
+// narratorOpening matches the boilerplate before the name in a narrator
+// line: "It was narrated that", "It was narrated from", "Narrated", "On the
+// authority of" and the like, tolerating the OCR slips in the source texts
+// ("lt was", "narrâted", "thal", "frorn")
+var narratorOpening = regexp.MustCompile(`(?i)^(?:[il]t (?:was|is) (?:narr|rep)\S* (?:(?:that|thal|from|frorn|on the authority of)\b ?)?|(?:narrated|reported)(?: by)?\b:? ?|on the authority of\b ?)`)
+
+// narratorParentheticals matches asides such as "(رضي الله عنه)"
+var narratorParentheticals = regexp.MustCompile(`\([^)]*\)`)
+
+// nameConnectors are the lowercase words that may appear inside a name
+var nameConnectors = map[string]bool{
+	"bin": true, "ibn": true, "bint": true, "abi": true, "abu": true, "umm": true,
+	"al": true, "as": true, "az": true, "ad": true, "an": true, "ar": true,
+	"ash": true, "at": true, "ath": true, "adh": true, "ud": true, "ul": true,
+}
+
+// notNames are capitalized words that start a sentence rather than a name
+var notNames = map[string]bool{
+	"the": true, "a": true, "an": true, "he": true, "she": true, "they": true,
+	"his": true, "her": true, "we": true, "i": true, "one": true, "when": true,
+	"some": true, "that": true, "this": true, "it": true,
+}
+
+// nameQuotes are the ayn and hamza signs written at the start of a name
+// ("‘Ali", "`Umar")
+const nameQuotes = "'‘’`ʿʾʼ\""
+
+// ExtractNarrator returns the name of the narrator from a hadith's narrator
+// line, without boilerplate: "It was narrated that ‘Ali (رضي الله عنه) said:"
+// gives "‘Ali". It returns "" when the line does not start with a name, as in
+// "It was narrated that a man came to ...".
+func ExtractNarrator(line string) string {
+	line = strings.ReplaceAll(line, "\u00ad", "") // soft hyphens
+	line = narratorParentheticals.ReplaceAllString(line, " ")
+	line = strings.Join(strings.Fields(line), " ")
+
+	line = narratorOpening.ReplaceAllString(line, "")
+
+	// The name is the leading run of capitalized words and connectors, up to
+	// the first punctuation
+	var words []string
+	for _, word := range strings.Fields(line) {
+		end := strings.IndexAny(word, ",:;")
+		if end >= 0 {
+			word = word[:end]
+		}
+
+		bare := strings.TrimLeft(word, nameQuotes)
+		if bare == "" {
+			if end >= 0 {
+				break
+			}
+			continue
+		}
+		if !isNameWord(bare, len(words) == 0) {
+			break
+		}
+		words = append(words, word)
+		if end >= 0 {
+			break
+		}
+	}
+
+	// Names do not end in a connector ("Ali or", "Ibn")
+	for len(words) > 0 && nameConnectors[strings.ToLower(strings.TrimLeft(words[len(words)-1], nameQuotes))] {
+		words = words[:len(words)-1]
+	}
+	return strings.Join(words, " ")
+}
+
+// isNameWord reports whether a word can be part of a name
+func isNameWord(word string, first bool) bool {
+	lower := strings.ToLower(word)
+	if first && notNames[lower] {
+		return false
+	}
+	if i := strings.Index(lower, "-"); i > 0 && nameConnectors[lower[:i]] {
+		return true
+	}
+	if nameConnectors[lower] {
+		return !first || lower == "abu" || lower == "ibn" || lower == "umm"
+	}
+	r := []rune(word)[0]
+	return unicode.IsUpper(r) && !notNames[lower]
+}
+
+// narratorKey reduces a name to a key shared by its spelling variants:
+// transliteration is folded (see search.TranslitKey), "ibn" is "bin", the
+// article is dropped and spacing is ignored, so "‘Umar bin al-Khattab",
+// "`Umar ibn Khattab" and "Umar bin Al-Khaṭṭāb" are one narrator
+func narratorKey(name string) string {
+	var b strings.Builder
+	for i, word := range search.Tokenize(name) {
+		switch {
+		case word == "ibn":
+			word = "bin"
+		case i > 0 && nameConnectors[word] && word != "bin" && word != "bint" && word != "abi" && word != "abu" && word != "umm":
+			continue
+		}
+		b.WriteString(word)
+	}
+	return search.TranslitKey(b.String())
+}
+
+// hadithRef locates a hadith in CollectionData.Hadiths
+type hadithRef struct {
+	collection string
+	offset     int
+}
+
+// narratorIndex lists every narrator with the hadiths they narrate, most
+// prolific first. A narrator's ID is their position in the list.
+type narratorIndex struct {
+	narrators []models.Narrator
+	hadiths   [][]hadithRef
+}
+
+func buildNarratorIndex(data *models.CollectionData) *narratorIndex {
+	type group struct {
+		refs      []hadithRef
+		spellings map[string]int
+	}
+	groups := make(map[string]*group)
+
+	for _, c := range data.Collections {
+		for offset, h := range data.Hadiths[c.Name] {
+			name := ExtractNarrator(h.Narrator)
+			key := narratorKey(name)
+			if key == "" {
+				continue
+			}
+			g, ok := groups[key]
+			if !ok {
+				g = &group{spellings: make(map[string]int)}
+				groups[key] = g
+			}
+			g.refs = append(g.refs, hadithRef{collection: c.Name, offset: offset})
+			g.spellings[name]++
+		}
+	}
+
+	type entry struct {
+		name string
+		refs []hadithRef
+	}
+	entries := make([]entry, 0, len(groups))
+	for _, g := range groups {
+		// The narrator is shown under their most common spelling
+		best := ""
+		for name, n := range g.spellings {
+			if best == "" || n > g.spellings[best] || (n == g.spellings[best] && name < best) {
+				best = name
+			}
+		}
+		entries = append(entries, entry{name: best, refs: g.refs})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if len(entries[i].refs) != len(entries[j].refs) {
+			return len(entries[i].refs) > len(entries[j].refs)
+		}
+		return entries[i].name < entries[j].name
+	})
+
+	idx := &narratorIndex{
+		narrators: make([]models.Narrator, len(entries)),
+		hadiths:   make([][]hadithRef, len(entries)),
+	}
+	for i, e := range entries {
+		idx.narrators[i] = models.Narrator{ID: i, Name: e.name, Count: len(e.refs)}
+		idx.hadiths[i] = e.refs
+	}
+	return idx
+}
+
+// GetNarrators returns every narrator in the loaded collections, most
+// hadiths first
+func (s *HadithService) GetNarrators() []models.Narrator {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.narrators.narrators
+}
+
+// GetNarratorHadiths returns a page of the hadiths narrated by the narrator
+// with the given ID, with CollectionName set. It returns false for an
+// unknown ID.
+func (s *HadithService) GetNarratorHadiths(id int, page int, limit int) (models.Narrator, models.HadithResponse, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if id < 0 || id >= len(s.narrators.narrators) {
+		return models.Narrator{}, models.HadithResponse{}, false
+	}
+	refs := s.narrators.hadiths[id]
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10
+	}
+	total := len(refs)
+	start := (page - 1) * limit
+	end := start + limit
+	if start > total {
+		start = total
+	}
+	if end > total {
+		end = total
+	}
+
+	hadiths := make([]models.Hadith, 0, end-start)
+	for _, ref := range refs[start:end] {
+		h := s.data.Hadiths[ref.collection][ref.offset]
+		h.CollectionName = ref.collection
+		hadiths = append(hadiths, h)
+	}
+
+	return s.narrators.narrators[id], models.HadithResponse{
+		Hadiths:    hadiths,
+		Total:      total,
+		Page:       page,
+		TotalPages: (total + limit - 1) / limit,
+	}, true
+}
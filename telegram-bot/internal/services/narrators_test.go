@@ -0,0 +1,93 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+
+	"hadith-bot/internal/models"
+)
+
+func TestExtractNarrator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"It was narrated that ‘Ali (رضي الله عنه) said:", "‘Ali"},
+		{"It was narrated that `Ali bin Abi Talib (رضي الله عنه) said:", "`Ali bin Abi Talib"},
+		{"It was narrated from ‘Ali (رضي الله عنه) that The Prophet (ﷺ) said:", "‘Ali"},
+		{"It was narrated that ‘Umar bin al-Khattab said:", "‘Umar bin al-Khattab"},
+		{"It was narrated that ‘Abdur-Rahman bin Abi Laila said:", "‘Abdur-Rahman bin Abi Laila"},
+		{"lt was narrated that Abu Matar said:", "Abu Matar"},
+		{"It was narrated thal Abu Bakr as-Siddeeq said:", "Abu Bakr as-Siddeeq"},
+		{"It was narrated that Abu Firas suid:", "Abu Firas"},
+		{"It was narrated that ‘Ali or az-Zubair said:", "‘Ali"},
+		{"Narrated Abu Huraira:", "Abu Huraira"},
+		{"Abu Huraira reported:", "Abu Huraira"},
+		{"`Ubaidullah bin Abi Rafi’ said:", "`Ubaidullah bin Abi Rafi’"},
+		{"Ibn Abi Khalid told us, from Abu Bakr bin Abi Zuhair - I think he said:", "Ibn Abi Khalid"},
+		{"It was narrated that a woman who had committed zina was brought to `Umar", ""},
+		{"It was narrated that The Prophet (ﷺ) said:", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := ExtractNarrator(tt.input); got != tt.expected {
+			t.Errorf("ExtractNarrator(%q) = %q; want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestNarratorKey(t *testing.T) {
+	same := [][]string{
+		{"‘Umar bin al-Khattab", "`Umar ibn Khattab", "Umar bin Al-Khaṭṭāb"},
+		{"Abu Hurairah", "Abu Huraira", "Abū Hurayrah"},
+		{"‘Abdur-Rahman bin ‘Awf", "AbdurRahman bin Awf"},
+	}
+	for _, names := range same {
+		for _, name := range names[1:] {
+			if narratorKey(name) != narratorKey(names[0]) {
+				t.Errorf("narratorKey(%q) = %q; want %q, the key of %q", name, narratorKey(name), narratorKey(names[0]), names[0])
+			}
+		}
+	}
+
+	if narratorKey("‘Ali") == narratorKey("‘Ali bin Abi Talib") {
+		t.Error("narratorKey merged ‘Ali with ‘Ali bin Abi Talib")
+	}
+}
+
+func TestNarratorIndex(t *testing.T) {
+	s := &HadithService{}
+	s.data = &models.CollectionData{
+		Collections: []models.Collection{{Name: "bukhari"}, {Name: "muslim"}},
+		Hadiths: map[string][]models.Hadith{
+			"bukhari": {
+				{HadithNumber: 1, Narrator: "Narrated ‘Umar bin al-Khattab:"},
+				{HadithNumber: 2, Narrator: "Narrated Abu Huraira:"},
+				{HadithNumber: 3, Narrator: "Narrated Abu Hurairah:"},
+				{HadithNumber: 4, Narrator: "It was narrated that a man said:"},
+			},
+			"muslim": {
+				{HadithNumber: 10, Narrator: "Abu Huraira reported:"},
+				{HadithNumber: 11, Narrator: "It was narrated that `Umar ibn Khattab said:"},
+			},
+		},
+	}
+	s.narrators = buildNarratorIndex(s.data)
+
+	if got, want := fmt.Sprint(s.GetNarrators()), "[{0 Abu Huraira 3} {1 `Umar ibn Khattab 2}]"; got != want {
+		t.Errorf("GetNarrators() = %s; want %s", got, want)
+	}
+
+	narrator, res, ok := s.GetNarratorHadiths(0, 2, 2)
+	if !ok || narrator.Name != "Abu Huraira" || res.Total != 3 || res.TotalPages != 2 {
+		t.Fatalf("GetNarratorHadiths(0, 2, 2) = %+v, %+v, %v", narrator, res, ok)
+	}
+	if got, want := fmt.Sprint(resultRefs(models.SearchResult{Hadiths: res.Hadiths})), "[muslim:10]"; got != want {
+		t.Errorf("GetNarratorHadiths(0, 2, 2) hadiths = %s; want %s", got, want)
+	}
+
+	if _, _, ok := s.GetNarratorHadiths(5, 1, 10); ok {
+		t.Error("GetNarratorHadiths(5) found a narrator that does not exist")
+	}
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"hadith-bot/internal/models"
+	"hadith-bot/internal/search"
+)
+
+// MaxReferenceRange caps how many hadiths a range reference such as
+// "nasai 100-105" may open at once
+const MaxReferenceRange = 20
+
+// Reference identifies a hadith, or a range of hadiths, by collection and
+// number. When Book is set, From and To count hadiths within that book
+// ("bukhari 2:15" is the 15th hadith of book 2); otherwise they are hadith
+// numbers in the collection.
+type Reference struct {
+	Collection string
+	Book       int
+	From       int
+	To         int
+}
+
+// collectionAliases maps the ways people write collection names, reduced by
+// aliasKey, to the collection file names
+var collectionAliases = map[string]string{
+	"bukhari":          "bukhari",
+	"bokhari":          "bukhari",
+	"muslim":           "muslim",
+	"abudawud":         "abudawud",
+	"abudawood":        "abudawud",
+	"abidawud":         "abudawud",
+	"abudaud":          "abudawud",
+	"dawud":            "abudawud",
+	"tirmidhi":         "tirmidhi",
+	"tirmizi":          "tirmidhi",
+	"nasai":            "nasai",
+	"nisai":            "nasai",
+	"ibnmajah":         "ibnmajah",
+	"ibnmaja":          "ibnmajah",
+	"majah":            "ibnmajah",
+	"ahmad":            "ahmad",
+	"ahmed":            "ahmad",
+	"ahmadibnhanbal":   "ahmad",
+	"darimi":           "darimi",
+	"muwatta":          "malik",
+	"muwattamalik":     "malik",
+	"malik":            "malik",
+	"riyadussaliheen":  "riyadussaliheen",
+	"riyadussalihin":   "riyadussaliheen",
+	"riyadhussaliheen": "riyadussaliheen",
+	"riyad":            "riyadussaliheen",
+}
+
+// aliasFillerWords are dropped from collection names before lookup, so
+// "Sahih al-Bukhari", "al-bukhari" and "bukhari" all reduce to "bukhari"
+var aliasFillerWords = map[string]bool{
+	"sahih": true, "sunan": true, "jami": true, "musnad": true, "imam": true,
+	"al": true, "an": true, "at": true, "ad": true, "as": true, "ar": true, "us": true,
+}
+
+// referencePattern matches "<collection> <number>" with optional "book:"
+// qualifier and range: "bukhari 1", "bukhari:1", "muslim #2564",
+// "bukhari 2:15", "nasai 100-105"
+var referencePattern = regexp.MustCompile(`(?i)^([\p{L}'’\-\s]+?)\s*(?::|#|\bno\.?)?\s*(\d+)(?:\s*[:.]\s*(\d+))?(?:\s*[-–]\s*(\d+))?$`)
+
+// aliasKey reduces a collection name to lowercase letters with filler words
+// and punctuation removed
+func aliasKey(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\'' && r != '’'
+	})
+
+	var b strings.Builder
+	for i, w := range words {
+		w = strings.NewReplacer("'", "", "’", "").Replace(w)
+		// Keep a lone filler word ("Musnad" on its own still means Ahmad
+		// once looked up), but drop fillers in front of a name
+		if aliasFillerWords[w] && i < len(words)-1 {
+			continue
+		}
+		b.WriteString(w)
+	}
+	return b.String()
+}
+
+// ResolveCollection returns the loaded collection a user-typed name refers
+// to, or "" when the name is not recognised
+func (s *HadithService) ResolveCollection(name string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.resolveCollection(name)
+}
+
+func (s *HadithService) resolveCollection(name string) string {
+	key := aliasKey(name)
+	if key == "" {
+		return ""
+	}
+
+	// Names and titles of the loaded collections take precedence, so new
+	// collections are recognised without adding aliases
+	for _, c := range s.data.Collections {
+		if key == aliasKey(c.Name) || key == aliasKey(c.Title) {
+			return c.Name
+		}
+	}
+
+	if name, ok := collectionAliases[key]; ok && s.data.GetCollection(name) != nil {
+		return name
+	}
+	if key == "musnad" && s.data.GetCollection("ahmad") != nil {
+		return "ahmad"
+	}
+	return ""
+}
+
+// ParseReference parses a hadith reference such as "bukhari 1",
+// "sahih muslim:2564", "abu dawud 2:15" or "nasai 100-105". It returns false
+// when the text is not a reference to a loaded collection.
+func (s *HadithService) ParseReference(text string) (Reference, bool) {
+	m := referencePattern.FindStringSubmatch(strings.TrimSpace(text))
+	if m == nil {
+		return Reference{}, false
+	}
+
+	s.mu.RLock()
+	collection := s.resolveCollection(m[1])
+	s.mu.RUnlock()
+	if collection == "" {
+		return Reference{}, false
+	}
+
+	ref := Reference{Collection: collection}
+	first, _ := strconv.Atoi(m[2])
+	if m[3] != "" {
+		ref.Book = first
+		ref.From, _ = strconv.Atoi(m[3])
+	} else {
+		ref.From = first
+	}
+	ref.To = ref.From
+	if m[4] != "" {
+		ref.To, _ = strconv.Atoi(m[4])
+	}
+
+	if ref.From < 1 || ref.To < ref.From {
+		return Reference{}, false
+	}
+	if ref.To-ref.From >= MaxReferenceRange {
+		ref.To = ref.From + MaxReferenceRange - 1
+	}
+	return ref, true
+}
+
+// LookupReference returns the hadiths a reference points to, with
+// CollectionName and an opening Snippet set, skipping numbers that do not
+// exist
+func (s *HadithService) LookupReference(ref Reference) []models.Hadith {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hadiths := s.data.Hadiths[ref.Collection]
+	var found []models.Hadith
+
+	if ref.Book > 0 {
+		n := 0
+		for _, h := range hadiths {
+			if h.ChapterID != ref.Book {
+				continue
+			}
+			n++
+			if n >= ref.From && n <= ref.To {
+				h.CollectionName = ref.Collection
+				h.Snippet = search.SnippetFor(&h, nil, snippetWidth)
+				found = append(found, h)
+			}
+		}
+		return found
+	}
+
+	for num := ref.From; num <= ref.To; num++ {
+		for _, h := range hadiths {
+			if h.HadithNumber == num {
+				h.CollectionName = ref.Collection
+				h.Snippet = search.SnippetFor(&h, nil, snippetWidth)
+				found = append(found, h)
+				break
+			}
+		}
+	}
+	return found
+}
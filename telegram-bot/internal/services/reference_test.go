@@ -0,0 +1,89 @@
+package services
+
+import (
+	"testing"
+
+	"hadith-bot/internal/models"
+)
+
+func newTestService() *HadithService {
+	return &HadithService{
+		data: &models.CollectionData{
+			Collections: []models.Collection{
+				{Name: "bukhari", Title: "Sahih al-Bukhari"},
+				{Name: "muslim", Title: "Sahih Muslim"},
+				{Name: "abudawud", Title: "Sunan Abu Dawood"},
+				{Name: "nasai", Title: "Sunan an-Nasa'i"},
+				{Name: "darimi", Title: "Sunan al-Darimi"},
+			},
+			Hadiths: map[string][]models.Hadith{
+				"bukhari": {
+					{HadithNumber: 1, ChapterID: 1},
+					{HadithNumber: 2, ChapterID: 2},
+					{HadithNumber: 3, ChapterID: 2},
+				},
+				"nasai": {
+					{HadithNumber: 100, ChapterID: 1, Arabic: "إنما الأعمال بالنيات"},
+					{HadithNumber: 101, ChapterID: 1, English: "Actions are by intentions.", Arabic: "إنما الأعمال بالنيات"},
+					{HadithNumber: 103, ChapterID: 1},
+				},
+			},
+		},
+	}
+}
+
+func TestParseReference(t *testing.T) {
+	s := newTestService()
+
+	tests := []struct {
+		input    string
+		expected Reference
+	}{
+		{"bukhari 1", Reference{Collection: "bukhari", From: 1, To: 1}},
+		{"Al-Bukhari 7", Reference{Collection: "bukhari", From: 7, To: 7}},
+		{"bukhari:12", Reference{Collection: "bukhari", From: 12, To: 12}},
+		{"Sahih Muslim 2564", Reference{Collection: "muslim", From: 2564, To: 2564}},
+		{"muslim #2564", Reference{Collection: "muslim", From: 2564, To: 2564}},
+		{"abu dawud 4", Reference{Collection: "abudawud", From: 4, To: 4}},
+		{"Sunan Abi Dawud 4", Reference{Collection: "abudawud", From: 4, To: 4}},
+		{"nasa'i 100-105", Reference{Collection: "nasai", From: 100, To: 105}},
+		{"bukhari 2:15", Reference{Collection: "bukhari", Book: 2, From: 15, To: 15}},
+		{"sunan al-darimi 3", Reference{Collection: "darimi", From: 3, To: 3}},
+		{"nasai 1-500", Reference{Collection: "nasai", From: 1, To: MaxReferenceRange}},
+	}
+
+	for _, tt := range tests {
+		got, ok := s.ParseReference(tt.input)
+		if !ok {
+			t.Errorf("ParseReference(%q) failed", tt.input)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("ParseReference(%q) = %+v; want %+v", tt.input, got, tt.expected)
+		}
+	}
+
+	for _, input := range []string{"hello 5", "bukhari", "tirmidhi 5", "prayer times", "nasai 10-5", "bukhari 0"} {
+		if ref, ok := s.ParseReference(input); ok {
+			t.Errorf("ParseReference(%q) = %+v; want no match", input, ref)
+		}
+	}
+}
+
+func TestLookupReference(t *testing.T) {
+	s := newTestService()
+
+	got := s.LookupReference(Reference{Collection: "nasai", From: 100, To: 103})
+	if len(got) != 3 || got[2].HadithNumber != 103 || got[0].CollectionName != "nasai" {
+		t.Errorf("range lookup returned %+v", got)
+	}
+	// The snippet falls back to the Arabic for hadiths without English
+	if len(got) == 3 && (got[0].Snippet != "إنما الأعمال بالنيات" || got[1].Snippet != "Actions are by intentions.") {
+		t.Errorf("snippets %q and %q; want the Arabic, then the English", got[0].Snippet, got[1].Snippet)
+	}
+
+	got = s.LookupReference(Reference{Collection: "bukhari", Book: 2, From: 2, To: 2})
+	if len(got) != 1 || got[0].HadithNumber != 3 {
+		t.Errorf("book lookup returned %+v", got)
+	}
+}
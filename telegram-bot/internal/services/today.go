@@ -0,0 +1,152 @@
+package services
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+	"sort"
+	"time"
+
+	"hadith-bot/internal/data"
+	"hadith-bot/internal/models"
+	"hadith-bot/internal/search"
+)
+
+// todayIndex is the pool the hadith of the day is drawn from, in load order,
+// and the hadiths pinned to dates
+type todayIndex struct {
+	pool []hadithRef
+	pins map[string]hadithRef
+}
+
+// buildToday resolves the hadith of the day settings against the loaded
+// data, warning about references that match no hadith. It runs from
+// setData, after the index and topics are built.
+func (s *HadithService) buildToday(t *data.Today) *todayIndex {
+	idx := &todayIndex{pins: make(map[string]hadithRef)}
+
+	for date, text := range t.Dates {
+		if ref, ok := s.resolveTodayRef(text); ok {
+			idx.pins[date] = ref
+		} else {
+			s.log.Warn("%s: no hadith %q for %s", data.TodayFile, text, date)
+		}
+	}
+
+	if len(t.Hadiths) > 0 {
+		for _, text := range t.Hadiths {
+			if ref, ok := s.resolveTodayRef(text); ok {
+				idx.pool = append(idx.pool, ref)
+			} else {
+				s.log.Warn("%s: no hadith %q", data.TodayFile, text)
+			}
+		}
+		return idx
+	}
+
+	if len(t.Collections) == 0 && len(t.Grades) == 0 && len(t.Topics) == 0 {
+		// The major collections, as for random picks
+		majorOnly := s.hasMajorCollection()
+		for _, c := range s.data.Collections {
+			if majorOnly && !isMajorCollection(c.Name) {
+				continue
+			}
+			for offset := range s.data.Hadiths[c.Name] {
+				idx.pool = append(idx.pool, hadithRef{collection: c.Name, offset: offset})
+			}
+		}
+		return idx
+	}
+
+	q := &search.Query{Filter: search.Filter{
+		Collections: lowerAll(t.Collections),
+		Grades:      lowerAll(t.Grades),
+		Topics:      lowerAll(t.Topics),
+	}}
+	if err := s.topics.resolveTopicFilter(q); err != nil {
+		s.log.Warn("%s: %v", data.TodayFile, err)
+		return idx
+	}
+	hits := s.index.Search(q)
+
+	// Hits come ranked; the pool must not depend on ranking
+	sort.Slice(hits, func(i, j int) bool { return hits[i].Doc < hits[j].Doc })
+	for _, hit := range hits {
+		idx.pool = append(idx.pool, hadithRef{collection: hit.Collection, offset: hit.Offset})
+	}
+	if len(idx.pool) == 0 {
+		s.log.Warn("%s: the filter allows no hadiths", data.TodayFile)
+	}
+	return idx
+}
+
+// resolveTodayRef finds the hadith a single reference points to
+func (s *HadithService) resolveTodayRef(text string) (hadithRef, bool) {
+	ref, ok := s.ParseReference(text)
+	if !ok {
+		return hadithRef{}, false
+	}
+	found := s.LookupReference(ref)
+	if len(found) == 0 {
+		return hadithRef{}, false
+	}
+	for offset, h := range s.data.Hadiths[ref.Collection] {
+		if h.HadithNumber == found[0].HadithNumber {
+			return hadithRef{collection: ref.Collection, offset: offset}, true
+		}
+	}
+	return hadithRef{}, false
+}
+
+// SetTodayLocation sets the timezone whose calendar days the hadith of the
+// day follows; UTC by default
+func (s *HadithService) SetTodayLocation(loc *time.Location) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.todayLoc = loc
+}
+
+// HadithOfTheDay returns the hadith of the day for the day t falls on in
+// the configured timezone. It is the same for everyone on that day and
+// across restarts: a hadith pinned to the date, or else one picked from the
+// pool with the date as seed. It returns false when the pool is empty.
+func (s *HadithService) HadithOfTheDay(t time.Time) (models.DailyHadith, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	loc := s.todayLoc
+	if loc == nil {
+		loc = time.UTC
+	}
+	date := t.In(loc).Format(data.TodayDateLayout)
+
+	ref, pinned := s.today.pins[date]
+	if !pinned {
+		if len(s.today.pool) == 0 {
+			return models.DailyHadith{}, false
+		}
+		ref = s.today.pool[dailyIndex(date, len(s.today.pool))]
+	}
+
+	hadith := s.data.Hadiths[ref.collection][ref.offset]
+	collection := s.data.GetCollection(ref.collection)
+	if collection == nil {
+		return models.DailyHadith{}, false
+	}
+	return models.DailyHadith{
+		Date:   date,
+		Pinned: pinned,
+		RandomHadithResult: models.RandomHadithResult{
+			Hadith:     &hadith,
+			Collection: collection,
+			Book:       s.data.GetBook(ref.collection, hadith.ChapterID),
+		},
+	}, true
+}
+
+// dailyIndex picks a position in a pool of n from a date. PCG's output is
+// fixed by its seed, so the pick only changes if the pool does.
+func dailyIndex(date string, n int) int {
+	h := fnv.New64a()
+	h.Write([]byte(date))
+	return int(rand.NewPCG(h.Sum64(), 0).Uint64() % uint64(n))
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"testing"
+	"time"
+
+	"hadith-bot/internal/data"
+	"hadith-bot/internal/logger"
+	"hadith-bot/internal/models"
+)
+
+func TestHadithOfTheDay(t *testing.T) {
+	s := newSearchTestService()
+	s.today = s.buildToday(&data.Today{})
+
+	day := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	first, ok := s.HadithOfTheDay(day)
+	if !ok {
+		t.Fatal("no hadith of the day")
+	}
+	if first.Date != "2026-10-17" || first.Pinned {
+		t.Errorf("unexpected daily hadith: date %s, pinned %v", first.Date, first.Pinned)
+	}
+
+	// The same all day, and picked again from the date after a restart
+	again, _ := s.HadithOfTheDay(day.Add(11 * time.Hour))
+	restarted := newSearchTestService()
+	restarted.today = restarted.buildToday(&data.Today{})
+	other, _ := restarted.HadithOfTheDay(day.Add(-12 * time.Hour))
+	for _, d := range []struct {
+		name   string
+		number int
+	}{{"later that day", again.Hadith.HadithNumber}, {"after a restart", other.Hadith.HadithNumber}} {
+		if d.number != first.Hadith.HadithNumber {
+			t.Errorf("%s: got hadith %d; want %d", d.name, d.number, first.Hadith.HadithNumber)
+		}
+	}
+
+	// Different days do not all get the same hadith
+	seen := make(map[string]bool)
+	for i := 0; i < 30; i++ {
+		daily, _ := s.HadithOfTheDay(day.AddDate(0, 0, i))
+		seen[fmt.Sprintf("%s:%d", daily.Collection.Name, daily.Hadith.HadithNumber)] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("30 days gave only %v", seen)
+	}
+
+	// The day follows the configured timezone
+	s.SetTodayLocation(time.FixedZone("UTC+3", 3*60*60))
+	if daily, _ := s.HadithOfTheDay(time.Date(2026, 10, 17, 22, 0, 0, 0, time.UTC)); daily.Date != "2026-10-18" {
+		t.Errorf("22:00 UTC at UTC+3 is dated %s; want 2026-10-18", daily.Date)
+	}
+}
+
+func TestHadithOfTheDayPool(t *testing.T) {
+	tests := []struct {
+		today    *data.Today
+		expected map[string]bool // the hadiths allowed, nil for none
+	}{
+		{&data.Today{Collections: []string{"Muslim"}}, map[string]bool{"muslim:10": true, "muslim:11": true}},
+		{&data.Today{Topics: []string{"prayer"}, Grades: []string{"hasan sahih"}}, map[string]bool{"bukhari:3": true}},
+		{&data.Today{Hadiths: []string{"bukhari 2", "muslim 99"}}, map[string]bool{"bukhari:2": true}},
+		{&data.Today{Collections: []string{"tirmidhi"}}, nil},
+		{&data.Today{Topics: []string{"zakat"}}, nil},
+	}
+
+	day := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		s := newSearchTestService()
+		s.log = logger.New(io.Discard, logger.ErrorLevel, false)
+		s.today = s.buildToday(tt.today)
+		for i := 0; i < 20; i++ {
+			daily, ok := s.HadithOfTheDay(day.AddDate(0, 0, i))
+			if !ok {
+				if tt.expected != nil {
+					t.Errorf("%+v: no hadith of the day", tt.today)
+				}
+				break
+			}
+			if ref := fmt.Sprintf("%s:%d", daily.Collection.Name, daily.Hadith.HadithNumber); !tt.expected[ref] {
+				t.Errorf("%+v: picked %s", tt.today, ref)
+			}
+		}
+	}
+}
+
+func TestHadithOfTheDayDefaultPool(t *testing.T) {
+	tests := []struct {
+		name     string
+		major    bool   // whether the service keeps its major collections
+		expected string // the collections picked from over the days, sorted
+	}{
+		{"major collections only", true, "[bukhari muslim]"},
+		{"none major", false, "[darimi]"},
+	}
+
+	day := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		s := newSearchTestService()
+		if !tt.major {
+			s.data.Collections = nil
+		}
+		s.data.Collections = append(s.data.Collections, models.Collection{Name: "darimi"})
+		s.data.Hadiths["darimi"] = []models.Hadith{{HadithNumber: 1}, {HadithNumber: 2}, {HadithNumber: 3}}
+		s.today = s.buildToday(&data.Today{})
+
+		picked := make(map[string]bool)
+		for i := 0; i < 60; i++ {
+			daily, ok := s.HadithOfTheDay(day.AddDate(0, 0, i))
+			if !ok {
+				t.Fatalf("%s: no hadith of the day", tt.name)
+			}
+			picked[daily.Collection.Name] = true
+		}
+		var names []string
+		for name := range picked {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if got := fmt.Sprint(names); got != tt.expected {
+			t.Errorf("%s: picked from %s; want %s", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestHadithOfTheDayPinned(t *testing.T) {
+	s := newSearchTestService()
+	s.log = logger.New(io.Discard, logger.ErrorLevel, false)
+	s.today = s.buildToday(&data.Today{
+		Collections: []string{"bukhari"},
+		Dates:       map[string]string{"2027-02-08": "muslim 11", "2027-02-09": "muslim 99"},
+	})
+
+	daily, ok := s.HadithOfTheDay(time.Date(2027, 2, 8, 9, 0, 0, 0, time.UTC))
+	if !ok || !daily.Pinned || daily.Collection.Name != "muslim" || daily.Hadith.HadithNumber != 11 {
+		t.Errorf("expected the pinned muslim 11, got %+v", daily)
+	}
+
+	// A pin that matches no hadith falls back to the pool
+	daily, ok = s.HadithOfTheDay(time.Date(2027, 2, 9, 9, 0, 0, 0, time.UTC))
+	if !ok || daily.Pinned || daily.Collection.Name != "bukhari" {
+		t.Errorf("expected a pick from the pool, got %+v", daily)
+	}
+}
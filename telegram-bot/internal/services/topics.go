@@ -0,0 +1,153 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"hadith-bot/internal/data"
+	"hadith-bot/internal/models"
+	"hadith-bot/internal/search"
+)
+
+// topicIndex lists the topics of the taxonomy with the hadiths tagged with
+// each, in the order the topics are defined
+type topicIndex struct {
+	topics  []models.Topic
+	hadiths map[string][]hadithRef
+}
+
+func buildTopicIndex(d *models.CollectionData, t *data.Topics) *topicIndex {
+	idx := &topicIndex{
+		topics:  data.ApplyTopics(d, t),
+		hadiths: make(map[string][]hadithRef),
+	}
+	for _, c := range d.Collections {
+		for offset, h := range d.Hadiths[c.Name] {
+			for _, id := range h.Topics {
+				idx.hadiths[id] = append(idx.hadiths[id], hadithRef{collection: c.Name, offset: offset})
+			}
+		}
+	}
+	return idx
+}
+
+// GetTopics returns the topics that have at least one hadith, in taxonomy
+// order
+func (s *HadithService) GetTopics() []models.Topic {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var topics []models.Topic
+	for _, t := range s.topics.topics {
+		if t.Count > 0 {
+			topics = append(topics, t)
+		}
+	}
+	return topics
+}
+
+// ResolveTopic finds a topic by its ID or name, ignoring case, or by the
+// start of either when only one topic matches: "prayer", "Prayer" and "pray"
+// all find the prayer topic
+func (s *HadithService) ResolveTopic(text string) (models.Topic, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.topics.resolve(text)
+}
+
+func (idx *topicIndex) resolve(text string) (models.Topic, bool) {
+	text = strings.ToLower(strings.TrimSpace(text))
+	if text == "" {
+		return models.Topic{}, false
+	}
+
+	var prefixMatches []models.Topic
+	for _, t := range idx.topics {
+		id, name := strings.ToLower(t.ID), strings.ToLower(t.Name)
+		if text == id || text == name {
+			return t, true
+		}
+		if strings.HasPrefix(id, text) || strings.HasPrefix(name, text) {
+			prefixMatches = append(prefixMatches, t)
+		}
+	}
+	if len(prefixMatches) == 1 {
+		return prefixMatches[0], true
+	}
+	return models.Topic{}, false
+}
+
+// resolveTopicFilter replaces the topics of a query's filter, as typed, with
+// topic IDs. An unknown topic is a *search.ParseError.
+func (idx *topicIndex) resolveTopicFilter(q *search.Query) error {
+	for i, text := range q.Filter.Topics {
+		t, ok := idx.resolve(text)
+		if !ok {
+			return &search.ParseError{Msg: fmt.Sprintf("Unknown topic %q. Send /topics to see them all.", text)}
+		}
+		q.Filter.Topics[i] = t.ID
+	}
+	return nil
+}
+
+// GetTopicHadiths returns a page of the hadiths tagged with a topic, with
+// CollectionName set. It returns false for an unknown topic ID.
+func (s *HadithService) GetTopicHadiths(id string, page int, limit int) (models.Topic, models.HadithResponse, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	topic, ok := s.topics.get(id)
+	if !ok {
+		return models.Topic{}, models.HadithResponse{}, false
+	}
+	refs := s.topics.hadiths[id]
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10
+	}
+	total := len(refs)
+	start := (page - 1) * limit
+	end := start + limit
+	if start > total {
+		start = total
+	}
+	if end > total {
+		end = total
+	}
+
+	hadiths := make([]models.Hadith, 0, end-start)
+	for _, ref := range refs[start:end] {
+		h := s.data.Hadiths[ref.collection][ref.offset]
+		h.CollectionName = ref.collection
+		hadiths = append(hadiths, h)
+	}
+
+	return topic, models.HadithResponse{
+		Hadiths:    hadiths,
+		Total:      total,
+		Page:       page,
+		TotalPages: (total + limit - 1) / limit,
+	}, true
+}
+
+// GetRandomHadithInTopic returns a random hadith tagged with a topic,
+// skipping those for which exclude returns true; exclude may be nil. It
+// returns false for an unknown topic or one without hadiths left to pick.
+func (s *HadithService) GetRandomHadithInTopic(id string, exclude func(collection string, hadithNumber int) bool) (models.RandomHadithResult, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.pickRef(s.topics.hadiths[id], exclude)
+}
+
+func (idx *topicIndex) get(id string) (models.Topic, bool) {
+	for _, t := range idx.topics {
+		if t.ID == id {
+			return t, true
+		}
+	}
+	return models.Topic{}, false
+}
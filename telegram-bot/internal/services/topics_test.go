@@ -0,0 +1,103 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"hadith-bot/internal/models"
+	"hadith-bot/internal/search"
+)
+
+func TestResolveTopic(t *testing.T) {
+	s := newSearchTestService()
+
+	tests := []struct {
+		text     string
+		expected string // topic ID, "" for none
+	}{
+		{"prayer", "prayer"},
+		{" FASTING ", "fasting"},
+		{"Night Prayer", "night"},
+		{"fast", "fasting"},
+		{"ni", "night"},
+		{"p", "prayer"},
+		{"zakat", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		topic, ok := s.ResolveTopic(tt.text)
+		if ok != (tt.expected != "") || topic.ID != tt.expected {
+			t.Errorf("ResolveTopic(%q) = %q, %v; want %q", tt.text, topic.ID, ok, tt.expected)
+		}
+	}
+}
+
+func TestTopicHadiths(t *testing.T) {
+	s := newSearchTestService()
+
+	if got := len(s.GetTopics()); got != 3 {
+		t.Errorf("GetTopics returned %d topics; want 3", got)
+	}
+
+	topic, res, ok := s.GetTopicHadiths("prayer", 1, 10)
+	if !ok || topic.Count != 2 || res.Total != 2 || res.Hadiths[0].CollectionName != "bukhari" {
+		t.Errorf("GetTopicHadiths(prayer) = %+v, %+v, %v", topic, res, ok)
+	}
+	if _, _, ok := s.GetTopicHadiths("zakat", 1, 10); ok {
+		t.Error("GetTopicHadiths found an unknown topic")
+	}
+
+	for i := 0; i < 10; i++ {
+		r, ok := s.GetRandomHadithInTopic("fasting", nil)
+		if !ok || r.Collection.Name != "muslim" || r.Hadith.HadithNumber != 11 {
+			t.Fatalf("GetRandomHadithInTopic(fasting) = %+v, %v", r, ok)
+		}
+	}
+	if _, ok := s.GetRandomHadithInTopic("", nil); ok {
+		t.Error("GetRandomHadithInTopic found hadiths without a topic")
+	}
+
+	notOne := func(collection string, hadithNumber int) bool { return hadithNumber == 1 }
+	for i := 0; i < 10; i++ {
+		r, ok := s.GetRandomHadithInTopic("prayer", notOne)
+		if !ok || r.Hadith.HadithNumber != 3 {
+			t.Fatalf("GetRandomHadithInTopic(prayer) without hadith 1 = %+v, %v", r.Hadith, ok)
+		}
+	}
+	if _, ok := s.GetRandomHadithInTopic("fasting", func(string, int) bool { return true }); ok {
+		t.Error("GetRandomHadithInTopic picked an excluded hadith")
+	}
+}
+
+func TestSearchTopicFilter(t *testing.T) {
+	s := newSearchTestService()
+
+	tests := []struct {
+		query    string
+		filter   models.SearchFilter
+		expected string
+	}{
+		{"prayer topic:prayer", models.SearchFilter{}, "[bukhari:1 bukhari:3]"},
+		{"topic:night", models.SearchFilter{}, "[bukhari:3]"},
+		{"topic:fast", models.SearchFilter{}, "[muslim:11]"},
+		{"", models.SearchFilter{Topics: []string{"Fasting"}}, "[muslim:11]"},
+		{"prayer topic:fasting", models.SearchFilter{Topics: []string{"prayer"}}, "[bukhari:1 bukhari:3]"},
+	}
+	for _, tt := range tests {
+		res, err := s.SearchHadithsFiltered(tt.query, tt.filter, 1, 10)
+		if err != nil {
+			t.Errorf("SearchHadithsFiltered(%q, %+v) returned error: %v", tt.query, tt.filter, err)
+			continue
+		}
+		if got := fmt.Sprint(resultRefs(res)); got != tt.expected {
+			t.Errorf("SearchHadithsFiltered(%q, %+v) = %s; want %s", tt.query, tt.filter, got, tt.expected)
+		}
+	}
+
+	_, err := s.SearchHadiths("prayer topic:zakat", 1, 10)
+	var parseErr *search.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Errorf("unknown topic returned %v; want a *search.ParseError", err)
+	}
+}